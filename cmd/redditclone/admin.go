@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"redditclone/pkg/generator"
+	"redditclone/pkg/session"
+	"redditclone/pkg/user"
+)
+
+// generatedPasswordLen is long enough to be handed out without a forced
+// change.
+const generatedPasswordLen = 20
+
+func userCreate(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	email := fs.String("email", "", "address of the account, counts as verified")
+	role := fs.String("role", user.RoleUser, "user, moderator or admin")
+	password := fs.String("password", "", "password, generated when empty")
+	args, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	pw, generated, err := passwordOrGenerate(*password)
+	if err != nil {
+		return err
+	}
+	u, err := e.users().Create(ctx, args[0], pw, *email, *role)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(e.out, "created %s (%s) with role %s\n", u.Username, u.ID, u.Role)
+	if generated {
+		fmt.Fprintf(e.out, "password: %s\n", pw)
+	}
+	return nil
+}
+
+func userDelete(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	args, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if err := e.users().Delete(ctx, args[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "deleted %s, the posts keep their author\n", args[0])
+	return nil
+}
+
+func userSetRole(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	args, err := parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	if err := e.users().SetRole(ctx, args[0], args[1]); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "%s is now %s, roles are not enforced yet\n", args[0], args[1])
+	return nil
+}
+
+func userResetPassword(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	password := fs.String("password", "", "new password, generated when empty")
+	args, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	pw, generated, err := passwordOrGenerate(*password)
+	if err != nil {
+		return err
+	}
+	if err := e.users().ResetPassword(ctx, args[0], pw); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(e.out, "password of %s replaced, its sessions are closed\n", args[0])
+	if generated {
+		fmt.Fprintf(e.out, "password: %s\n", pw)
+	}
+	return nil
+}
+
+func passwordOrGenerate(password string) (pw string, generated bool, err error) {
+	if password != "" {
+		return password, false, nil
+	}
+	pw, err = generator.GenerateRandomID(generatedPasswordLen)
+	if err != nil {
+		return "", false, fmt.Errorf("password gen error: %s", err)
+	}
+	return pw, true, nil
+}
+
+func sessionPurge(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	username := fs.String("user", "", "log this user out everywhere instead")
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	if *username != "" {
+		if err := e.users().InvalidateSessions(ctx, *username); err != nil {
+			return err
+		}
+		fmt.Fprintf(e.out, "closed the sessions of %s\n", *username)
+		return nil
+	}
+
+	_, purger := e.sessions()
+	if purger == nil {
+		fmt.Fprintf(e.out, "the %s session store expires sessions itself\n", e.cfg.Sessions.Store)
+		return nil
+	}
+	n, err := session.NewJanitor(purger, 0, e.cfg.Sessions.CleanupBatch, e.logger).Purge(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "purged %d expired sessions\n", n)
+	return nil
+}
+
+func postDelete(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	args, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if err := e.posts().Delete(ctx, args[0]); err != nil {
+		return err
+	}
+	e.logger.Info("post deleted", "post", args[0])
+	fmt.Fprintf(e.out, "deleted post %s, post restore brings it back\n", args[0])
+	return nil
+}
+
+func postRestore(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	args, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if err := e.posts().Restore(ctx, args[0]); err != nil {
+		return err
+	}
+	e.logger.Info("post restored", "post", args[0])
+	fmt.Fprintf(e.out, "restored post %s\n", args[0])
+	return nil
+}
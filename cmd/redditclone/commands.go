@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"redditclone/internal/config"
+	"redditclone/internal/mongo"
+	"redditclone/internal/mysql"
+	"redditclone/pkg/audit"
+	"redditclone/pkg/post"
+	"redditclone/pkg/session"
+	"redditclone/pkg/user"
+
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+)
+
+// command is a subcommand, name includes its group, e.g. "user create".
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error
+}
+
+var commands = []command{
+	{"serve", "", "run the server, the default command", serve},
+	{"migrate", "", "create or update the MySQL tables", migrate},
+	{"user create", "[--email address] [--role role] [--password password] <username>", "open an account, the password is generated unless given", userCreate},
+	{"user delete", "<username>", "delete an account with its sessions, tokens and blocks", userDelete},
+	{"user set-role", "<username> <user|moderator|admin>", "record the role of an account, roles grant nothing yet", userSetRole},
+	{"user reset-password", "[--password password] <username>", "replace a password and log the user out", userResetPassword},
+	{"session purge", "[--user username]", "delete expired sessions, or every session of a user", sessionPurge},
+	{"post delete", "<post id>", "hide a post", postDelete},
+	{"post restore", "<post id>", "bring back a deleted post", postRestore},
+	{"seed", "[--password password]", "create sample users and posts, the password is generated unless given", seed},
+}
+
+// errUsage is returned once the usage of a command has been printed.
+var errUsage = errors.New("usage")
+
+// lookup finds the command named by the first args and returns it with the
+// remaining ones. No args means serve.
+func lookup(args []string) (*command, []string, error) {
+	if len(args) == 0 {
+		return &commands[0], nil, nil
+	}
+	for i := range commands {
+		words := strings.Fields(commands[i].name)
+		if len(args) >= len(words) && slices.Equal(args[:len(words)], words) {
+			return &commands[i], args[len(words):], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("unknown command %q, see redditclone help", strings.Join(args, " "))
+}
+
+func printCommands(w io.Writer) {
+	fmt.Fprintln(w, "Usage: redditclone [flags] [command]")
+	fmt.Fprintln(w, "\nThe flags are listed by redditclone --help. Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "\n  %s\n    \t%s\n", strings.TrimSpace(c.name+" "+c.usage), c.summary)
+	}
+}
+
+// env is what the commands share. The stores are opened on first use and
+// closed by close.
+type env struct {
+	cfg    *config.Config
+	logger *slog.Logger
+	out    io.Writer
+
+	db      *sql.DB
+	mongoDB *mongodriver.Database
+}
+
+func (e *env) mysql() *sql.DB {
+	if e.db == nil {
+		e.db = mysql.LoadDB(e.cfg.MySQLDSN)
+	}
+	return e.db
+}
+
+func (e *env) mongo() *mongodriver.Database {
+	if e.mongoDB == nil {
+		e.mongoDB = mongo.LoadDB(e.cfg.MongoURI, e.cfg.MongoDBName)
+	}
+	return e.mongoDB
+}
+
+func (e *env) close() {
+	if e.db != nil {
+		e.db.Close()
+	}
+	if e.mongoDB != nil {
+		e.mongoDB.Client().Disconnect(context.Background())
+	}
+}
+
+func (c *command) flags() *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: redditclone %s %s\n", c.name, c.usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse reads the flags defined on fs and checks that n arguments are left.
+func parse(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, errUsage
+	}
+	if fs.NArg() != n {
+		fs.Usage()
+		return nil, errUsage
+	}
+	return fs.Args(), nil
+}
+
+func (e *env) sessions() (session.Repository, session.Purger) {
+	return session.NewStore(e.cfg.Sessions, e.mysql())
+}
+
+func (e *env) users() *user.AdminService {
+	sessions, _ := e.sessions()
+	return user.NewAdminService(user.NewMySQLRepo(e.mysql()), sessions, audit.NewSlogLogger(e.logger))
+}
+
+func (e *env) posts() *post.PostService {
+	return post.NewService(post.NewMongoRepo(e.mongo()))
+}
+
+func migrate(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	db, err := mysql.Open(e.cfg.MySQLDSN)
+	if err != nil {
+		return err
+	}
+	e.db = db
+	if err := mysql.Migrate(db); err != nil {
+		return err
+	}
+	fmt.Fprintln(e.out, "schema up to date")
+	return nil
+}
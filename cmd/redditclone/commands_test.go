@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	cmd, args, err := lookup(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "serve", cmd.name)
+	assert.Empty(t, args)
+
+	cmd, args, err = lookup([]string{"user", "set-role", "bob", "admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, "user set-role", cmd.name)
+	assert.Equal(t, []string{"bob", "admin"}, args)
+
+	_, _, err = lookup([]string{"user"})
+	assert.EqualError(t, err, `unknown command "user", see redditclone help`)
+}
+
+func TestParse(t *testing.T) {
+	cmd, _, _ := lookup([]string{"user", "create"})
+	fs := cmd.flags()
+	role := fs.String("role", "user", "")
+	fs.SetOutput(io.Discard)
+
+	args, err := parse(fs, []string{"--role=admin", "bob"}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bob"}, args)
+	assert.Equal(t, "admin", *role)
+
+	fs = cmd.flags()
+	fs.SetOutput(io.Discard)
+	_, err = parse(fs, []string{"bob", "alice"}, 1)
+	assert.ErrorIs(t, err, errUsage)
+}
@@ -1,34 +1,75 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"redditclone/internal/config"
 	"redditclone/internal/logger"
-	"redditclone/internal/mongo"
-	"redditclone/internal/mysql"
-	"redditclone/internal/routing"
-	"redditclone/pkg/middleware"
-	"redditclone/pkg/session"
-
-	"github.com/gorilla/mux"
+	"redditclone/pkg/dbcall"
 )
 
 func main() {
-	config.Load() // load env var from .env
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if cfg != nil && len(cfg.Args) > 0 && cfg.Args[0] == "help" {
+		printCommands(os.Stdout)
+		return
+	}
+	if cfg != nil && cfg.PrintConfig {
+		// printed even when invalid, it helps to find the culprit
+		if printErr := cfg.Print(os.Stdout); printErr != nil {
+			fatal(printErr)
+		}
+		if err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if err != nil {
+		fatal(err)
+	}
 
-	db := mysql.LoadDB()
-	defer db.Close()
+	cmd, args, err := lookup(cfg.Args)
+	if err != nil {
+		fatal(err)
+	}
 
-	mongoDB := mongo.LoadDB()
+	// the output of the other commands goes to stdout, their logs aside
+	logOut := os.Stderr
+	if cmd.name == "serve" {
+		logOut = os.Stdout
+	}
+	logger, err := logger.New(logOut, cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		fatal(err)
+	}
+	slog.SetDefault(logger)
+	dbcall.Configure(cfg.DB)
 
-	logger := logger.Load()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	e := &env{cfg: cfg, logger: logger, out: os.Stdout}
+	err = cmd.run(ctx, e, cmd.flags(), args)
+	e.close()
+	stop()
 
-	r := mux.NewRouter()
-	api := r.PathPrefix("/api").Subrouter()
-	api.Use(middleware.Panic)
-	api.Use(middleware.CheckJWT(session.NewMySQLSessionRepo(db)))
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	default:
+		logger.Error(cmd.name+" failed", "error", err)
+		os.Exit(1)
+	}
+}
 
-	routing.InitRoutes(api, db, mongoDB, logger)
-	routing.ServeStaticFiles(r)
-	routing.ServeFallback(r, logger)
-	routing.StartServer(r) // start sever on localhost:8082
+func fatal(err error) {
+	logger.Fatal("startup failed", "error", err)
 }
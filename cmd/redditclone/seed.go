@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"redditclone/pkg/claims"
+	"redditclone/pkg/post"
+	"redditclone/pkg/user"
+)
+
+type seedPost struct {
+	author   string
+	category string
+	title    string
+	text     string
+	url      string
+	comments []seedComment
+	votes    map[string]string
+}
+
+type seedComment struct {
+	author string
+	body   string
+}
+
+var (
+	seedUsers = []struct{ name, role string }{
+		{"alice", user.RoleAdmin},
+		{"bob", user.RoleModerator},
+		{"carol", user.RoleUser},
+	}
+
+	seedPosts = []seedPost{
+		{
+			author: "alice", category: "programming", title: "What got you into Go?",
+			text: "For me it was the standard library, tell us your story.",
+			comments: []seedComment{
+				{"bob", "The tooling, gofmt ended every style argument."},
+				{"carol", "A coworker rewrote a slow script in an afternoon."},
+			},
+			votes: map[string]string{"bob": "upvote", "carol": "upvote"},
+		},
+		{
+			author: "bob", category: "music", title: "Albums to code to",
+			text:  "Instrumental only, lyrics break my focus.",
+			votes: map[string]string{"carol": "upvote"},
+		},
+		{
+			author: "carol", category: "news", title: "The Go blog",
+			url:      "https://go.dev/blog/",
+			comments: []seedComment{{"alice", "The release notes are worth a read too."}},
+		},
+		{
+			author: "carol", category: "funny", title: "It works on my machine",
+			text:  "Then we ship your machine.",
+			votes: map[string]string{"alice": "upvote", "bob": "downvote"},
+		},
+		{author: "alice", category: "videos", title: "Conference talks", url: "https://www.youtube.com/@GoogleDevelopers"},
+		{author: "bob", category: "fashion", title: "Hoodie or flannel?", text: "The eternal developer question."},
+	}
+)
+
+// seed fills an empty instance with sample content through the services, so
+// the data looks like what the API creates. Users that already exist are left
+// alone, together with their posts, which makes running it twice harmless.
+func seed(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	password := fs.String("password", "", "password of the sample users, generated when empty")
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	pw, generated, err := passwordOrGenerate(*password)
+	if err != nil {
+		return err
+	}
+
+	users := e.users()
+	ids := map[string]string{}
+	created := map[string]bool{}
+	for _, su := range seedUsers {
+		u, err := users.Create(ctx, su.name, pw, su.name+"@example.com", su.role)
+		if err != nil && !errors.Is(err, user.ErrExists) {
+			return fmt.Errorf("user %s: %w", su.name, err)
+		}
+		if err == nil {
+			created[su.name] = true
+		} else if u, err = users.Repo.FindByUsername(ctx, su.name); err != nil {
+			return err
+		}
+		ids[su.name] = u.ID
+	}
+
+	posts := e.posts()
+	n := 0
+	for _, sp := range seedPosts {
+		if !created[sp.author] {
+			continue
+		}
+		p := &post.Post{Title: sp.title, Category: sp.category, Type: "text", Text: sp.text}
+		if sp.url != "" {
+			url := sp.url
+			p.Type, p.URL = "link", &url
+		}
+		if err := posts.CreatePost(ctx, p, sp.author, ids[sp.author]); err != nil {
+			return fmt.Errorf("post %q: %w", sp.title, err)
+		}
+		for _, c := range sp.comments {
+			if _, err := posts.AddComment(ctx, p.ID, c.body, seedClaims(c.author, ids[c.author])); err != nil {
+				return fmt.Errorf("comment on %q: %w", sp.title, err)
+			}
+		}
+		for voter, action := range sp.votes {
+			if _, err := posts.AddVote(ctx, p.ID, ids[voter], action); err != nil {
+				return fmt.Errorf("vote on %q: %w", sp.title, err)
+			}
+		}
+		n++
+	}
+
+	fmt.Fprintf(e.out, "seeded %d users and %d posts\n", len(created), n)
+	if generated && len(created) > 0 {
+		fmt.Fprintf(e.out, "password: %s\n", pw)
+	}
+	return nil
+}
+
+func seedClaims(username, id string) *claims.Claims {
+	c := &claims.Claims{}
+	c.User.Username, c.User.ID = username, id
+	return c
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"time"
+
+	"redditclone/internal/mongo"
+	"redditclone/internal/mysql"
+	"redditclone/internal/routing"
+	"redditclone/internal/server"
+	"redditclone/pkg/apitoken"
+	"redditclone/pkg/audit"
+	"redditclone/pkg/health"
+	"redditclone/pkg/jwtkeys"
+	"redditclone/pkg/metrics"
+	"redditclone/pkg/middleware"
+	"redditclone/pkg/ratelimit"
+	"redditclone/pkg/session"
+	"redditclone/pkg/token"
+	"redditclone/pkg/tracing"
+	"redditclone/pkg/user"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// serve runs the HTTP server until ctx is cancelled. It opens its own stores
+// as the server closes them in its shutdown order.
+func serve(ctx context.Context, e *env, fs *flag.FlagSet, args []string) error {
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	cfg, logger := e.cfg, e.logger
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	db := mysql.LoadDB(cfg.MySQLDSN)
+	mongoDB := mongo.LoadDB(cfg.MongoURI, cfg.MongoDBName)
+
+	keys, err := jwtkeys.New(ctx, cfg.Keys, db)
+	if err != nil {
+		return err
+	}
+	stopRotation := keys.Start(time.Minute, logger)
+
+	sessions, purger := session.NewStore(cfg.Sessions, db)
+	stopJanitor := func() {}
+	if purger != nil {
+		stopJanitor = session.NewJanitor(purger, cfg.Sessions.CleanupInterval, cfg.Sessions.CleanupBatch, logger).Start()
+	}
+	if counter, ok := purger.(session.Counter); ok {
+		if err := metrics.RegisterActiveSessions(counter.CountActive); err != nil {
+			return err
+		}
+	}
+
+	tokens := token.New(keys, cfg.Tokens)
+	// a token never outlives the session it was issued for
+	tokens.TTL = cfg.Sessions.TTL
+
+	apiTokens := apitoken.NewService(apitoken.NewMySQLRepo(db), user.NewMySQLRepo(db), audit.NewSlogLogger(logger))
+
+	r := mux.NewRouter()
+	r.Use(middleware.Tracing)
+	r.Use(middleware.RequestID(logger))
+	r.Use(middleware.AccessLog)
+	r.Use(middleware.Metrics)
+	// v2 goes first, the /api prefix covers it too
+	v2 := r.PathPrefix("/api/v2").Subrouter()
+	api := r.PathPrefix("/api").Subrouter()
+	limits := ratelimit.NewMemoryStore()
+	for _, version := range []*mux.Router{api, v2} {
+		version.Use(middleware.Panic)
+		version.Use(middleware.CheckJWT(sessions, tokens, apiTokens))
+		version.Use(middleware.SlidingSession(sessions, tokens, cfg.Sessions.TTL, logger))
+		version.Use(middleware.RateLimit(limits, cfg.RateLimits))
+	}
+
+	routing.InitRoutes(cfg, api, v2, db, mongoDB, logger, sessions, tokens, apiTokens)
+	routing.ServeOpenAPI(api, v2, logger)
+	routing.ServeJWKS(r, keys, logger)
+	routing.ServeMetrics(r, cfg.MetricsToken)
+
+	checker := health.NewChecker()
+	checker.Logger = logger
+	checker.Add("mysql", db.PingContext)
+	checker.Add("mongo", func(ctx context.Context) error {
+		return mongoDB.Client().Ping(ctx, readpref.Primary())
+	})
+	routing.ServeHealth(r, checker)
+	routing.ServeStaticFiles(r, cfg.StaticPath)
+	routing.ServeFallback(r, cfg.StaticPath)
+
+	srv := server.New(cfg.HTTP, routing.AliasV1(r), logger)
+	srv.OnDrain(checker.Drain)
+	// workers first, they still use the stores closed after them
+	srv.OnShutdown("session janitor", func(context.Context) error {
+		stopJanitor()
+		return nil
+	})
+	srv.OnShutdown("jwt key rotation", func(context.Context) error {
+		stopRotation()
+		return nil
+	})
+	srv.OnShutdown("mysql", func(context.Context) error { return db.Close() })
+	srv.OnShutdown("mongo", mongoDB.Client().Disconnect)
+	srv.OnShutdown("tracing", shutdownTracing)
+
+	return srv.Run(ctx)
+}
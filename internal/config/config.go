@@ -1,32 +1,233 @@
+// Package config gathers the settings of the server. Every setting has a
+// default that an env file, the environment and command-line flags override,
+// in that order.
 package config
 
 import (
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
 	"os"
 
+	"redditclone/internal/logger"
+	"redditclone/internal/server"
+	"redditclone/pkg/dbcall"
+	"redditclone/pkg/jwtkeys"
+	"redditclone/pkg/mailer"
+	"redditclone/pkg/middleware"
+	"redditclone/pkg/oidc"
+	"redditclone/pkg/ratelimit"
+	"redditclone/pkg/secretbox"
+	"redditclone/pkg/session"
+	"redditclone/pkg/token"
+	"redditclone/pkg/tracing"
+
+	"github.com/go-sql-driver/mysql"
 	"github.com/joho/godotenv"
 )
 
-func Load() {
-	/*
-		.env-local для реальной бд, .env.docker для докера
-		в скрипте в START пишется или .env-local или .env.docker
-		в зависимости от парметров запуска, ./start.sh или ./start.sh docker
-	*/
-	if err := godotenv.Load(os.Getenv("START")); err != nil {
-		log.Fatalf("Env file not found")
+const (
+	fromDefault = "default"
+	fromFile    = "file"
+	fromEnv     = "env"
+	fromFlag    = "flag"
+)
+
+type Config struct {
+	// EnvFile is the env file that was read, empty if there was none.
+	EnvFile string
+	// PrintConfig asks to print the settings instead of serving.
+	PrintConfig bool
+	// Args are the arguments after the flags, the command to run.
+	Args []string
+
+	LogFormat string
+	LogLevel  string
+
+	MySQLDSN    string
+	MongoURI    string
+	MongoDBName string
+
+	PublicURL         string
+	StaticPath        string
+	MetricsToken      string
+	TOTPEncryptionKey string
+	// UnverifiedBlockedRoutes are the route names closed to users without a
+	// verified email.
+	UnverifiedBlockedRoutes map[string]bool
+
+	HTTP       server.Config
+	Tracing    tracing.Config
+	DB         dbcall.Config
+	Sessions   session.Config
+	Keys       jwtkeys.Config
+	Tokens     token.Config
+	RateLimits map[string]ratelimit.Policy
+	Mailer     mailer.Config
+	// OIDC is nil when single sign-on is off.
+	OIDC *oidc.Config
+
+	values map[string]value
+}
+
+type value struct {
+	v      string
+	source string
+}
+
+// Load reads the settings, args are the command-line arguments without the
+// program name. Parsing stops at the first argument that is not a flag. The
+// env file is named by --env-file or START, its entries are also exported to
+// the environment for libraries reading it themselves.
+// The error lists every bad setting, the settings are still returned with it
+// so that they can be printed.
+func Load(args []string) (*Config, error) {
+	cfg := &Config{values: make(map[string]value, len(keys))}
+
+	fs := flag.NewFlagSet("redditclone", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: redditclone [flags] [command]\n\nredditclone help lists the commands. Flags:")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&cfg.EnvFile, "env-file", os.Getenv("START"), "env file to read, optional")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "print the settings with secrets redacted and exit")
+	flags := make(map[string]string)
+	for _, k := range keys {
+		name := k.name
+		fs.Func(k.flag(), k.usage+" ("+name+")", func(v string) error {
+			flags[name] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
 	}
+	cfg.Args = fs.Args()
 
-	if os.Getenv("JWT_SECRET") == "" {
-		log.Fatalf("JWT_SECRET is not set in environment")
+	file := map[string]string{}
+	if cfg.EnvFile != "" {
+		var err error
+		if file, err = godotenv.Read(cfg.EnvFile); err != nil {
+			return nil, fmt.Errorf("env file: %w", err)
+		}
 	}
-	if os.Getenv("MYSQL_DSN") == "" {
-		log.Fatalf("MySQLDSN is not set in environment")
+
+	for _, k := range keys {
+		val := value{v: k.def, source: fromDefault}
+		if v, ok := file[k.name]; ok {
+			val = value{v: v, source: fromFile}
+		}
+		if v, ok := os.LookupEnv(k.name); ok {
+			val = value{v: v, source: fromEnv}
+		}
+		if v, ok := flags[k.name]; ok {
+			val = value{v: v, source: fromFlag}
+		}
+		cfg.values[k.name] = val
+	}
+	for name, v := range file {
+		if _, ok := os.LookupEnv(name); !ok {
+			os.Setenv(name, v)
+		}
+	}
+
+	if err := cfg.parse(); err != nil {
+		return cfg, fmt.Errorf("invalid configuration:\n%w", err)
 	}
-	if os.Getenv("MONGO_URI") == "" {
-		log.Fatalf("MongoURI is not set in environment")
+	return cfg, nil
+}
+
+func (c *Config) get(name string) string {
+	return c.values[name].v
+}
+
+// parse fills the typed settings and collects every problem.
+func (c *Config) parse() error {
+	var errs []error
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	c.LogFormat, c.LogLevel = c.get("LOG_FORMAT"), c.get("LOG_LEVEL")
+	_, err := logger.New(io.Discard, c.LogFormat, c.LogLevel)
+	check(err)
+
+	c.MySQLDSN = c.get("MYSQL_DSN")
+	if c.MySQLDSN == "" {
+		check(errors.New("MYSQL_DSN is not set"))
+	} else if _, err := mysql.ParseDSN(c.MySQLDSN); err != nil {
+		check(fmt.Errorf("bad MYSQL_DSN: %w", err))
+	}
+	c.MongoURI, c.MongoDBName = c.get("MONGO_URI"), c.get("MONGO_DB_NAME")
+	if c.MongoURI == "" {
+		check(errors.New("MONGO_URI is not set"))
+	}
+	if c.MongoDBName == "" {
+		check(errors.New("MONGO_DB_NAME is not set"))
+	}
+
+	c.PublicURL = c.get("PUBLIC_URL")
+	if u, err := url.Parse(c.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		check(fmt.Errorf("bad PUBLIC_URL %q", c.PublicURL))
+	}
+	c.StaticPath = c.get("STATIC_PATH")
+	c.MetricsToken = c.get("METRICS_TOKEN")
+	c.TOTPEncryptionKey = c.get("TOTP_ENCRYPTION_KEY")
+	if c.TOTPEncryptionKey != "" {
+		if _, err := secretbox.New(c.TOTPEncryptionKey); err != nil {
+			check(fmt.Errorf("TOTP_ENCRYPTION_KEY: %w", err))
+		}
+	}
+	c.UnverifiedBlockedRoutes = middleware.ParseRoutes(c.get("UNVERIFIED_BLOCKED_ROUTES"))
+
+	c.HTTP, err = server.ConfigFrom(c.get)
+	check(err)
+	c.Tracing, err = tracing.ConfigFrom(c.get)
+	check(err)
+	c.DB, err = dbcall.ConfigFrom(c.get)
+	check(err)
+	c.Sessions, err = session.ConfigFrom(c.get)
+	check(err)
+	c.Keys, err = jwtkeys.ConfigFrom(c.get)
+	check(err)
+	// a token lives up to SESSION_TTL and must still verify after its key
+	// was rotated out
+	if (c.Keys.Alg == jwtkeys.AlgRS256 || c.Keys.Alg == jwtkeys.AlgEdDSA) && c.Keys.Retain < c.Sessions.TTL {
+		check(fmt.Errorf("JWT_KEY_RETAIN %s is shorter than SESSION_TTL %s", c.Keys.Retain, c.Sessions.TTL))
+	}
+	c.Tokens, err = token.ConfigFrom(c.get)
+	check(err)
+	c.RateLimits, err = ratelimit.PoliciesFrom(c.get)
+	check(err)
+	c.Mailer, err = mailer.ConfigFrom(c.get)
+	check(err)
+	c.OIDC, err = oidc.ConfigFrom(c.get)
+	check(err)
+
+	return errors.Join(errs...)
+}
+
+// Print writes the settings in env file format with where each one came
+// from. Secrets are redacted.
+func (c *Config) Print(w io.Writer) error {
+	if c.EnvFile != "" {
+		if _, err := fmt.Fprintf(w, "# env file %s\n", c.EnvFile); err != nil {
+			return err
+		}
 	}
-	if os.Getenv("MONGO_DB_NAME") == "" {
-		log.Fatalf("MongoDB is not set in environment")
+	for _, k := range keys {
+		val := c.values[k.name]
+		v := val.v
+		if k.secret && v != "" {
+			v = "REDACTED"
+		}
+		if _, err := fmt.Fprintf(w, "%s=%q # %s\n", k.name, v, val.source); err != nil {
+			return err
+		}
 	}
+	return nil
 }
@@ -0,0 +1,141 @@
+package config_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"redditclone/internal/config"
+	"redditclone/internal/server"
+	"redditclone/pkg/dbcall"
+	"redditclone/pkg/ratelimit"
+	"redditclone/pkg/session"
+	"redditclone/pkg/token"
+	"redditclone/pkg/tracing"
+)
+
+var required = []string{
+	"--mysql-dsn=app:secret@tcp(127.0.0.1:3306)/redditclone",
+	"--mongo-uri=mongodb://localhost:27017",
+	"--mongo-db-name=redditclone",
+	"--jwt-secret=hmac",
+	"--totp-encryption-key=MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=",
+	"--mailer=memory",
+}
+
+// unset clears names for the test and restores them afterwards, Load
+// exports the env file.
+func unset(t *testing.T, names ...string) {
+	for _, name := range names {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	unset(t, "START")
+	cfg, err := config.Load(required)
+	assert.NoError(t, err)
+
+	// the defaults of the registry are the ones of the packages
+	none := func(string) string { return "" }
+	httpCfg, _ := server.ConfigFrom(none)
+	assert.Equal(t, httpCfg, cfg.HTTP)
+	tracingCfg, _ := tracing.ConfigFrom(none)
+	assert.Equal(t, tracingCfg, cfg.Tracing)
+	dbCfg, _ := dbcall.ConfigFrom(none)
+	assert.Equal(t, dbCfg, cfg.DB)
+	sessionCfg, _ := session.ConfigFrom(none)
+	assert.Equal(t, sessionCfg, cfg.Sessions)
+	tokenCfg, _ := token.ConfigFrom(none)
+	assert.Equal(t, tokenCfg, cfg.Tokens)
+	limits, _ := ratelimit.PoliciesFrom(none)
+	assert.Equal(t, limits, cfg.RateLimits)
+
+	assert.Equal(t, "./static", cfg.StaticPath)
+	assert.Nil(t, cfg.OIDC)
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), ".env")
+	assert.NoError(t, os.WriteFile(envFile, []byte(
+		"HTTP_ADDR=:9000\nLOG_LEVEL=debug\nJWT_ISSUER=file\nOTEL_EXPORTER_OTLP_ENDPOINT=http://collector:4318\n",
+	), 0o600))
+	unset(t, "START", "HTTP_ADDR", "OTEL_EXPORTER_OTLP_ENDPOINT")
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("JWT_ISSUER", "env")
+
+	cfg, err := config.Load(append([]string{"--env-file", envFile, "--jwt-issuer=flag"}, required...))
+	assert.NoError(t, err)
+
+	assert.Equal(t, ":9000", cfg.HTTP.Addr)
+	assert.Equal(t, "warn", cfg.LogLevel)
+	assert.Equal(t, "flag", cfg.Tokens.Issuer)
+	assert.Equal(t, "http://collector:4318", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"))
+}
+
+func TestLoad_LeavesTheCommand(t *testing.T) {
+	unset(t, "START")
+	cfg, err := config.Load(append(required, "user", "create", "--role=admin", "bob"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"user", "create", "--role=admin", "bob"}, cfg.Args)
+}
+
+func TestLoad_ListsEveryProblem(t *testing.T) {
+	unset(t, "START")
+	t.Setenv("SESSION_TTL", "forever")
+	t.Setenv("HTTP_ADDR", "8082")
+
+	cfg, err := config.Load([]string{"--rate-limit-vote=lots", "--log-level=loud"})
+
+	assert.NotNil(t, cfg)
+	assert.EqualError(t, err, `invalid configuration:
+bad LOG_LEVEL "loud"
+MYSQL_DSN is not set
+MONGO_URI is not set
+MONGO_DB_NAME is not set
+bad HTTP_ADDR "8082"
+bad SESSION_TTL "forever"
+JWT_SECRET is not set
+RATE_LIMIT_VOTE: bad rate limit policy "lots"
+MAILER is not set`)
+}
+
+func TestLoad_KeyRetainCoversSessions(t *testing.T) {
+	unset(t, "START")
+	args := append([]string{
+		"--jwt-alg=EdDSA",
+		"--jwt-key-encryption-key=MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=",
+		"--session-ttl=3h",
+	}, required...)
+
+	_, err := config.Load(args)
+	assert.ErrorContains(t, err, "JWT_KEY_RETAIN 2h0m0s is shorter than SESSION_TTL 3h0m0s")
+
+	_, err = config.Load(append(args, "--jwt-key-retain=3h"))
+	assert.NoError(t, err)
+}
+
+func TestLoad_MissingEnvFile(t *testing.T) {
+	_, err := config.Load([]string{"--env-file", filepath.Join(t.TempDir(), "missing")})
+	assert.ErrorContains(t, err, "env file")
+}
+
+func TestPrint_RedactsSecrets(t *testing.T) {
+	unset(t, "START")
+	cfg, err := config.Load(append([]string{"--http-addr=:9000"}, required...))
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, cfg.Print(&buf))
+
+	assert.Contains(t, buf.String(), "HTTP_ADDR=\":9000\" # flag\n")
+	assert.Contains(t, buf.String(), "SESSION_TTL=\"1h\" # default\n")
+	assert.Contains(t, buf.String(), "JWT_SECRET=\"REDACTED\" # flag\n")
+	assert.Contains(t, buf.String(), "MYSQL_DSN=\"REDACTED\" # flag\n")
+	assert.Contains(t, buf.String(), "REDIS_PASSWORD=\"\" # default\n")
+	assert.NotContains(t, buf.String(), "secret@tcp")
+	assert.NotContains(t, buf.String(), "hmac")
+}
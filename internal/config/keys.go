@@ -0,0 +1,105 @@
+package config
+
+import (
+	"sort"
+	"strings"
+
+	"redditclone/pkg/ratelimit"
+)
+
+// key is a setting, read as an environment variable or as the flag
+// --<name in lower case with dashes>, e.g. --http-addr for HTTP_ADDR.
+type key struct {
+	name  string
+	def   string
+	usage string
+	// secret values are redacted by --print-config
+	secret bool
+}
+
+func (k key) flag() string {
+	return strings.ReplaceAll(strings.ToLower(k.name), "_", "-")
+}
+
+var keys = append([]key{
+	{name: "HTTP_ADDR", def: ":8082", usage: "listen address"},
+	{name: "HTTP_READ_TIMEOUT", def: "15s", usage: "time to read a whole request"},
+	{name: "HTTP_READ_HEADER_TIMEOUT", def: "5s", usage: "time to read the request headers"},
+	{name: "HTTP_WRITE_TIMEOUT", def: "30s", usage: "time to write a response"},
+	{name: "HTTP_IDLE_TIMEOUT", def: "2m", usage: "keep-alive time of idle connections"},
+	{name: "HTTP_MAX_HEADER_BYTES", def: "1048576", usage: "largest accepted request header"},
+	{name: "HTTP_SHUTDOWN_TIMEOUT", def: "20s", usage: "time in-flight requests get to finish on shutdown"},
+	{name: "HTTP_DRAIN_DELAY", def: "0s", usage: "time /readyz fails before the server stops accepting requests"},
+	{name: "TLS_CERT_FILE", usage: "certificate, turns on HTTPS and HTTP/2"},
+	{name: "TLS_KEY_FILE", usage: "private key of TLS_CERT_FILE"},
+	{name: "HTTP_REDIRECT_ADDR", usage: "plain HTTP address redirecting to HTTPS"},
+	{name: "PUBLIC_URL", def: "http://localhost:8082", usage: "base URL of links in mails"},
+	{name: "STATIC_PATH", def: "./static", usage: "directory of the frontend"},
+
+	{name: "MYSQL_DSN", usage: "MySQL data source name", secret: true},
+	{name: "MONGO_URI", usage: "MongoDB connection string", secret: true},
+	{name: "MONGO_DB_NAME", usage: "MongoDB database"},
+	{name: "DB_TIMEOUT", def: "3s", usage: "deadline of each database call"},
+	{name: "DB_TIMEOUTS", usage: "per call deadlines as repo.Method=duration,..."},
+
+	{name: "LOG_FORMAT", def: "json", usage: "json or text"},
+	{name: "LOG_LEVEL", def: "info", usage: "debug, info, warn or error"},
+	{name: "METRICS_TOKEN", usage: "bearer token required on /metrics", secret: true},
+	{name: "OTEL_TRACES_EXPORTER", def: "none", usage: "none, otlp or stdout"},
+	{name: "OTEL_SERVICE_NAME", def: "redditclone", usage: "service name of the spans"},
+	{name: "OTEL_TRACES_SAMPLER_ARG", def: "1", usage: "share of new traces that are sampled"},
+
+	{name: "JWT_ALG", def: "HS256", usage: "HS256, RS256 or EdDSA"},
+	{name: "JWT_SECRET", usage: "HS256 signing secret", secret: true},
+	{name: "JWT_KEY_ENCRYPTION_KEY", usage: "base64 key sealing the stored signing keys", secret: true},
+	{name: "JWT_ROTATE_EVERY", def: "720h", usage: "lifetime of a signing key"},
+	{name: "JWT_KEY_RETAIN", def: "2h", usage: "time a replaced key still verifies"},
+	{name: "JWT_ISSUER", def: "redditclone", usage: "iss of the tokens"},
+	{name: "JWT_AUDIENCE", def: "redditclone-api", usage: "aud of the tokens"},
+	{name: "JWT_LEEWAY", def: "30s", usage: "tolerated clock skew"},
+
+	{name: "SESSION_STORE", def: "mysql", usage: "mysql or redis"},
+	{name: "SESSION_TTL", def: "1h", usage: "session lifetime without activity"},
+	{name: "SESSION_MAX_LIFETIME", def: "168h", usage: "session lifetime after login"},
+	{name: "SESSION_CLEANUP_INTERVAL", def: "10m", usage: "how often expired sessions are purged"},
+	{name: "SESSION_CLEANUP_BATCH", def: "500", usage: "sessions deleted per purge query"},
+	{name: "SESSION_CACHE_SIZE", def: "10000", usage: "cached session checks, 0 turns the cache off"},
+	{name: "SESSION_CACHE_TTL", def: "5s", usage: "lifetime of a cached valid session"},
+	{name: "SESSION_CACHE_NEGATIVE_TTL", def: "1s", usage: "lifetime of a cached invalid session"},
+	{name: "REDIS_ADDR", usage: "Redis address of the redis session store"},
+	{name: "REDIS_PASSWORD", usage: "Redis password", secret: true},
+	{name: "REDIS_DB", def: "0", usage: "Redis database"},
+	{name: "TOTP_ENCRYPTION_KEY", usage: "base64 key encrypting TOTP secrets, empty turns 2FA off", secret: true},
+
+	{name: "MAILER", usage: "log, file, smtp or memory"},
+	{name: "MAILER_FILE", def: "mail.log", usage: "file of the file mailer"},
+	{name: "SMTP_ADDR", usage: "SMTP server"},
+	{name: "SMTP_USERNAME", usage: "SMTP user"},
+	{name: "SMTP_PASSWORD", usage: "SMTP password", secret: true},
+	{name: "MAIL_FROM", usage: "sender of mails"},
+	{name: "UNVERIFIED_BLOCKED_ROUTES", usage: "route names closed to unverified users, e.g. posts,comment"},
+
+	{name: "OIDC_ISSUER", usage: "single sign-on issuer, empty turns it off"},
+	{name: "OIDC_CLIENT_ID", usage: "single sign-on client"},
+	{name: "OIDC_CLIENT_SECRET", usage: "single sign-on client secret", secret: true},
+	{name: "OIDC_REDIRECT_URL", usage: "single sign-on callback URL"},
+	{name: "OIDC_SCOPES", def: "openid profile email", usage: "single sign-on scopes"},
+}, rateLimitKeys()...)
+
+func rateLimitKeys() []key {
+	routes := make([]string, 0, len(ratelimit.DefaultPolicies))
+	for route := range ratelimit.DefaultPolicies {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	limits := make([]key, 0, len(routes))
+	for _, route := range routes {
+		limits = append(limits, key{
+			name:  ratelimit.EnvKey(route),
+			def:   ratelimit.DefaultPolicies[route],
+			usage: "rate limit of " + route + " as <burst>/<period>",
+		})
+	}
+	return limits
+}
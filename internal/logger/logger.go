@@ -1,11 +1,36 @@
 package logger
 
 import (
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"strings"
 )
 
-func Load() *slog.Logger {
-	opts := &slog.HandlerOptions{}
-	return slog.New(slog.NewTextHandler(os.Stdout, opts))
+// New builds the app logger, format is json or text, json by default, and
+// level debug, info, warn or error, info by default.
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("bad LOG_LEVEL %q", level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	switch strings.ToLower(format) {
+	case "", "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown LOG_FORMAT %q", format)
+	}
+}
+
+// Fatal logs msg with the default logger and exits, for startup errors.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
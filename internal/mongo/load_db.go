@@ -2,18 +2,18 @@ package mongo
 
 import (
 	"context"
-	"log"
-	"os"
+
+	"redditclone/internal/logger"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func LoadDB() *mongo.Database {
+func LoadDB(uri, name string) *mongo.Database {
 	ctx := context.Background()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(os.Getenv("MONGO_URI")))
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
 	if err != nil {
-		log.Fatal("Mongo connection failed:", err)
+		logger.Fatal("mongo connection failed", "error", err)
 	}
-	return client.Database(os.Getenv("MONGO_DB_NAME"))
+	return client.Database(name)
 }
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS api_tokens (
+	id CHAR(12) PRIMARY KEY,
+	user_id CHAR(24) NOT NULL,
+	name VARCHAR(64) NOT NULL,
+	token_hash CHAR(64) NOT NULL UNIQUE,
+	scopes VARCHAR(255) NOT NULL,
+	created_at DATETIME NOT NULL,
+	last_used_at DATETIME NULL,
+	expires_at DATETIME NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
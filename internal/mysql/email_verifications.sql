@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS email_verifications (
+	token_hash CHAR(64) PRIMARY KEY,
+	user_id CHAR(24) NOT NULL,
+	email VARCHAR(254) NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	expires_at DATETIME NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
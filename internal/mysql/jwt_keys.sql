@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS jwt_keys (
+	kid VARCHAR(32) PRIMARY KEY,
+	alg VARCHAR(16) NOT NULL,
+	private_key TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	expires_at DATETIME NOT NULL
+);
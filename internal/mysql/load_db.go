@@ -2,40 +2,85 @@ package mysql
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
 	"os"
 
-	_ "github.com/go-sql-driver/mysql"
+	"redditclone/internal/logger"
+
+	"github.com/go-sql-driver/mysql"
 )
 
-func LoadDB() *sql.DB {
-	db, err := sql.Open("mysql", os.Getenv("MYSQL_DSN"))
+// LoadDB connects and brings the schema up to date, exiting on failure.
+func LoadDB(dsn string) *sql.DB {
+	db, err := Open(dsn)
 	if err != nil {
-		log.Fatal(err)
-	}
-	if err := db.Ping(); err != nil {
-		log.Fatal("Cannot connect to DB:", err)
+		logger.Fatal("cannot connect to mysql", "error", err)
 	}
-	if err := exec(db); err != nil {
-		log.Fatal("Cannot create tables:", err)
+	if err := Migrate(db); err != nil {
+		logger.Fatal("cannot create tables", "error", err)
 	}
 	return db
 }
 
-func exec(db *sql.DB) error {
+// Open connects without touching the schema.
+func Open(dsn string) (*sql.DB, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("bad MYSQL_DSN: %w", err)
+	}
+	// DATETIME columns are scanned into time.Time
+	cfg.ParseTime = true
+
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Migrate runs every schema file, the files are safe to run again.
+func Migrate(db *sql.DB) error {
 	files := []string{
 		"./internal/mysql/users.sql",
+		"./internal/mysql/users_email.sql",
+		"./internal/mysql/users_totp.sql",
 		"./internal/mysql/sessions.sql",
+		"./internal/mysql/user_blocks.sql",
+		"./internal/mysql/login_attempts.sql",
+		"./internal/mysql/password_resets.sql",
+		"./internal/mysql/email_verifications.sql",
+		"./internal/mysql/recovery_codes.sql",
+		"./internal/mysql/login_challenges.sql",
+		"./internal/mysql/user_identities.sql",
+		"./internal/mysql/oidc_states.sql",
+		"./internal/mysql/jwt_keys.sql",
+		"./internal/mysql/api_tokens.sql",
+		"./internal/mysql/sessions_expiry.sql",
+		"./internal/mysql/users_role.sql",
 	}
 	for _, file := range files {
 		query, err := os.ReadFile(file)
 		if err != nil {
 			return fmt.Errorf("failed to read %s: %w", file, err)
 		}
-		if _, err := db.Exec(string(query)); err != nil {
+		if _, err := db.Exec(string(query)); err != nil && !alreadyApplied(err) {
 			return fmt.Errorf("failed to execute %s: %w", file, err)
 		}
 	}
 	return nil
 }
+
+// alreadyApplied reports errors of ALTER scripts that ran on a previous start.
+func alreadyApplied(err error) bool {
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) {
+		return false
+	}
+	// 1060 duplicate column name, 1061 duplicate key name
+	return myErr.Number == 1060 || myErr.Number == 1061
+}
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS login_attempts (
+	attempt_key VARCHAR(96) PRIMARY KEY,
+	failures INT NOT NULL DEFAULT 0,
+	last_failure DATETIME,
+	locked_until DATETIME NULL
+);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS login_challenges (
+	token_hash CHAR(64) PRIMARY KEY,
+	user_id CHAR(24) NOT NULL,
+	expires_at DATETIME NOT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS oidc_states (
+	state_hash CHAR(64) PRIMARY KEY,
+	verifier VARCHAR(128) NOT NULL,
+	nonce VARCHAR(64) NOT NULL,
+	expires_at DATETIME NOT NULL
+);
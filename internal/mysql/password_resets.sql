@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS password_resets (
+	token_hash CHAR(64) PRIMARY KEY,
+	user_id CHAR(24) NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	expires_at DATETIME NOT NULL,
+	used_at DATETIME NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
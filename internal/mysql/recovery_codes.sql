@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS recovery_codes (
+	code_hash CHAR(64) PRIMARY KEY,
+	user_id CHAR(24) NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
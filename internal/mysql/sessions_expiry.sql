@@ -0,0 +1 @@
+ALTER TABLE sessions ADD INDEX idx_sessions_expires_at (expires_at);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS user_blocks (
+	blocker_id CHAR(24) NOT NULL,
+	blocked_id CHAR(24) NOT NULL,
+	kind VARCHAR(8) NOT NULL DEFAULT 'block',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (blocker_id, blocked_id),
+	FOREIGN KEY (blocker_id) REFERENCES users(id),
+	FOREIGN KEY (blocked_id) REFERENCES users(id)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS user_identities (
+	provider VARCHAR(255) NOT NULL,
+	subject VARCHAR(255) NOT NULL,
+	user_id CHAR(24) NOT NULL,
+	email VARCHAR(254) NULL,
+	created_at DATETIME,
+	PRIMARY KEY (provider, subject),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
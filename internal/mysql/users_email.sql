@@ -0,0 +1,4 @@
+ALTER TABLE users
+	ADD COLUMN email VARCHAR(254) NULL,
+	ADD COLUMN verified BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD UNIQUE INDEX users_email (email);
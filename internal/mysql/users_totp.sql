@@ -0,0 +1,4 @@
+ALTER TABLE users
+	ADD COLUMN totp_secret VARCHAR(255) NULL,
+	ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,399 @@
+package routing
+
+import (
+	"cmp"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"redditclone/pkg/apitoken"
+	"redditclone/pkg/apperr"
+	"redditclone/pkg/block"
+	"redditclone/pkg/handlers"
+	v2handlers "redditclone/pkg/handlers/v2"
+	"redditclone/pkg/openapi"
+	"redditclone/pkg/user"
+)
+
+//go:embed docs.html
+var docsPage []byte
+
+// docsScript is the one script docs.html loads. The Content-Security-Policy
+// of the page allows nothing else, so a changed page cannot pull in code from
+// elsewhere; bump both together.
+const docsScript = "https://cdn.redoc.ly/redoc/v2.1.5/bundles/redoc.standalone.js"
+
+// operation documents a route of the API.
+type operation struct {
+	summary string
+	tag     string
+	// public operations need no token.
+	public bool
+	query  []string
+	// body and result are values of the JSON request and success bodies, nil
+	// when there is none.
+	body   any
+	result any
+	// status of a success, 200 when zero.
+	status int
+	// errors are the statuses of failures beside 400 for a bad body, 401
+	// without a token and 500.
+	errors []int
+}
+
+var (
+	messageResult = struct {
+		Message string `json:"message"`
+	}{}
+	tokenResult = struct {
+		Token string `json:"token"`
+	}{}
+	// loginResult has a token, or asks for the second factor of the challenge.
+	loginResult = struct {
+		Token                string `json:"token,omitempty"`
+		SecondFactorRequired bool   `json:"second_factor_required,omitempty"`
+		Challenge            string `json:"challenge,omitempty"`
+	}{}
+)
+
+// accountOperations documents the routes both versions of the API share by
+// method and OpenAPI path below the root of the version. A route missing from
+// the operations of its version is an error of apiSpec.
+var accountOperations = map[string]operation{
+	"POST /register": {
+		summary: "Create an account and log in", tag: "auth", public: true,
+		body: handlers.LoginForm{}, result: tokenResult, errors: []int{409, 422, 429},
+	},
+	"POST /login": {
+		summary: "Log in, accounts with 2FA get a challenge", tag: "auth", public: true,
+		body: handlers.LoginForm{}, result: loginResult, errors: []int{401, 429},
+	},
+	"POST /login/2fa": {
+		summary: "Answer the challenge of a login with a TOTP or recovery code", tag: "auth", public: true,
+		body: handlers.CompleteLoginForm{}, result: tokenResult, errors: []int{401},
+	},
+
+	"POST /user/password": {
+		summary: "Change the password, other sessions are closed", tag: "account",
+		body: handlers.ChangePasswordForm{}, result: tokenResult, errors: []int{422},
+	},
+	"POST /user/email": {
+		summary: "Set the email and send a verification", tag: "account",
+		body: handlers.EmailForm{}, result: messageResult, errors: []int{422},
+	},
+	"POST /user/2fa/enroll": {
+		summary: "Start enrolling a TOTP second factor", tag: "account",
+		result: user.Enrollment{}, errors: []int{409},
+	},
+	"POST /user/2fa/confirm": {
+		summary: "Confirm the second factor, answers the recovery codes", tag: "account",
+		body: handlers.CodeForm{}, errors: []int{409},
+		result: struct {
+			RecoveryCodes []string `json:"recovery_codes"`
+		}{},
+	},
+	"POST /user/2fa/disable": {
+		summary: "Turn the second factor off", tag: "account",
+		body: handlers.DisableTwoFactorForm{}, result: messageResult, errors: []int{409},
+	},
+	"POST /password/reset": {
+		summary: "Send a password reset link", tag: "account", public: true,
+		body: handlers.ResetRequestForm{}, result: messageResult,
+	},
+	"POST /password/reset/confirm": {
+		summary: "Set a new password with a reset token", tag: "account", public: true,
+		body: handlers.ResetForm{}, result: messageResult, errors: []int{422},
+	},
+	"POST /email/verify": {
+		summary: "Verify the email with the token of the link", tag: "account", public: true,
+		body: handlers.VerifyForm{}, result: messageResult,
+	},
+	"POST /email/resend": {
+		summary: "Send the verification again", tag: "account",
+		result: messageResult, errors: []int{409},
+	},
+
+	"GET /blocks": {
+		summary: "List blocked and muted users", tag: "blocks",
+		result: []block.Entry{},
+	},
+	"POST /blocks/{login}": {
+		summary: "Block a user", tag: "blocks",
+		result: messageResult, errors: []int{404},
+	},
+	"DELETE /blocks/{login}": {
+		summary: "Unblock a user", tag: "blocks",
+		result: messageResult, errors: []int{404},
+	},
+	"POST /mutes/{login}": {
+		summary: "Mute a user", tag: "blocks",
+		result: messageResult, errors: []int{404},
+	},
+	"DELETE /mutes/{login}": {
+		summary: "Unmute a user", tag: "blocks",
+		result: messageResult, errors: []int{404},
+	},
+
+	"GET /tokens": {
+		summary: "List personal API tokens", tag: "tokens",
+		result: []apitoken.Token{}, errors: []int{403},
+	},
+	"POST /tokens": {
+		summary: "Create a personal API token, the secret is only shown once", tag: "tokens",
+		body: handlers.APITokenForm{}, status: http.StatusCreated, errors: []int{403, 409, 422},
+		result: struct {
+			Token   string         `json:"token"`
+			Details apitoken.Token `json:"details"`
+		}{},
+	},
+	"DELETE /tokens/{token_id}": {
+		summary: "Revoke a personal API token", tag: "tokens",
+		result: messageResult, errors: []int{403, 404},
+	},
+}
+
+// v1Operations documents /api.
+var v1Operations = merge(accountOperations, map[string]operation{
+	"GET /oidc/login": {
+		summary: "Redirect to the single sign-on provider", tag: "auth", public: true,
+		status: http.StatusFound,
+	},
+	"GET /oidc/callback": {
+		summary: "Log in and redirect to PUBLIC_URL/sso#token=… or #challenge=…", tag: "auth", public: true,
+		query: []string{"state", "code", "error"}, status: http.StatusFound, errors: []int{400, 401},
+	},
+
+	"GET /posts/": {
+		summary: "List posts", tag: "posts", public: true,
+		result: []handlers.Post{},
+	},
+	"GET /posts/{category}": {
+		summary: "List the posts of a category", tag: "posts", public: true,
+		result: []handlers.Post{},
+	},
+	"POST /posts": {
+		summary: "Create a post", tag: "posts",
+		body: handlers.PostForm{}, result: handlers.Post{},
+	},
+	"GET /user/{login}": {
+		summary: "List the posts of a user", tag: "posts", public: true,
+		result: []handlers.Post{},
+	},
+	"GET /post/{post_id}": {
+		summary: "Get a post and count a view", tag: "posts", public: true,
+		result: handlers.Post{}, errors: []int{404},
+	},
+	"POST /post/{post_id}": {
+		summary: "Comment on a post", tag: "posts",
+		body: handlers.CommentForm{}, result: handlers.Post{}, errors: []int{403, 404},
+	},
+	"DELETE /post/{post_id}": {
+		summary: "Delete a post", tag: "posts",
+		result: messageResult, errors: []int{404},
+	},
+	"DELETE /post/{post_id}/{comm_id}": {
+		summary: "Delete a comment", tag: "posts",
+		result: handlers.Post{}, errors: []int{404},
+	},
+	"GET /post/{post_id}/{action}": {
+		summary: "Vote on a post", tag: "posts",
+		result: handlers.Post{}, errors: []int{403, 404},
+	},
+
+	"GET /openapi.json": {
+		summary: "This document", tag: "docs", public: true,
+		result: map[string]any{},
+	},
+	"GET /docs": {
+		summary: "A page rendering this document", tag: "docs", public: true,
+	},
+})
+
+// v2Operations documents /api/v2.
+var v2Operations = merge(accountOperations, map[string]operation{
+	"GET /posts": {
+		summary: "List a page of posts, or of the posts of a category", tag: "posts", public: true,
+		query: []string{"category", "page", "per_page"}, result: v2handlers.PostPage{}, errors: []int{422},
+	},
+	"POST /posts": {
+		summary: "Create a post", tag: "posts",
+		body: v2handlers.PostForm{}, result: v2handlers.Post{}, status: http.StatusCreated, errors: []int{422},
+	},
+	"GET /users/{login}/posts": {
+		summary: "List a page of the posts of a user", tag: "posts", public: true,
+		query: []string{"page", "per_page"}, result: v2handlers.PostPage{}, errors: []int{422},
+	},
+	"GET /posts/{post_id}": {
+		summary: "Get a post and count a view", tag: "posts", public: true,
+		result: v2handlers.Post{}, errors: []int{404},
+	},
+	"DELETE /posts/{post_id}": {
+		summary: "Delete a post of the caller", tag: "posts",
+		status: http.StatusNoContent, errors: []int{403, 404},
+	},
+	"POST /posts/{post_id}/comments": {
+		summary: "Comment on a post", tag: "posts",
+		body: v2handlers.CommentForm{}, result: v2handlers.Post{}, status: http.StatusCreated, errors: []int{403, 404, 422},
+	},
+	"DELETE /posts/{post_id}/comments/{comm_id}": {
+		summary: "Delete a comment of the caller", tag: "posts",
+		result: v2handlers.Post{}, errors: []int{403, 404},
+	},
+	"POST /posts/{post_id}/{action}": {
+		summary: "Vote on a post", tag: "posts",
+		result: v2handlers.Post{}, errors: []int{403, 404},
+	},
+})
+
+func merge(tables ...map[string]operation) map[string]operation {
+	merged := map[string]operation{}
+	for _, table := range tables {
+		maps.Copy(merged, table)
+	}
+	return merged
+}
+
+// apiVersion is a router of a version of the API and the operations of its
+// routes.
+type apiVersion struct {
+	router     *mux.Router
+	prefix     string
+	operations map[string]operation
+}
+
+// ServeOpenAPI publishes the OpenAPI document of the routes of api and v2 at
+// /api/openapi.json and a page rendering it at /api/docs. The document is
+// built from the routes already there, so it must be added after InitRoutes.
+func ServeOpenAPI(api, v2 *mux.Router, logger *slog.Logger) {
+	var spec []byte
+	api.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	}).Methods("GET")
+	api.HandleFunc("/docs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		// redoc runs its search index in a worker started from a blob
+		w.Header().Set("Content-Security-Policy", "script-src "+docsScript+" blob:; object-src 'none'; base-uri 'none'")
+		w.Write(docsPage)
+	}).Methods("GET")
+
+	doc, err := apiSpec(
+		apiVersion{router: api, prefix: "/api", operations: v1Operations},
+		apiVersion{router: v2, prefix: "/api/v2", operations: v2Operations},
+	)
+	if err != nil {
+		logger.Warn("openapi document is incomplete", "error", err)
+	}
+	if spec, err = json.Marshal(doc); err != nil {
+		fatal("openapi", err)
+	}
+}
+
+// apiSpec builds the document of the routes of versions from their
+// operations.
+func apiSpec(versions ...apiVersion) (*openapi.Document, error) {
+	schemas := openapi.Schemas{}
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:   "redditclone API",
+			Version: "2",
+			Description: "Every /api path is also served under /api/v1, /api/v2 lists posts in pages. " +
+				"Errors are answered with the envelope of apperr.Body. " +
+				"Any route may answer 429 when a rate limit is configured for it, " +
+				"and 503 or 504 when the database does not answer in time.",
+		},
+		Paths: map[string]openapi.PathItem{},
+		Components: openapi.Components{
+			Schemas: schemas,
+			SecuritySchemes: map[string]openapi.SecurityScheme{
+				"bearer": {
+					Type: "http", Scheme: "bearer", BearerFormat: "JWT",
+					Description: "The token of a login, or a personal API token limited to its scopes.",
+				},
+			},
+		},
+	}
+	errorBody := schemas.Of(apperr.Body{})
+
+	var errs []error
+	for _, version := range versions {
+		err := version.router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			// the prefixes of subrouters
+			if route.GetHandler() == nil {
+				return nil
+			}
+			template, err := route.GetPathTemplate()
+			if err != nil {
+				return err
+			}
+			methods, err := route.GetMethods()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s has no methods", template))
+				return nil
+			}
+
+			path, params := openapi.Path(template)
+			for _, method := range methods {
+				op, ok := version.operations[method+" "+strings.TrimPrefix(path, version.prefix)]
+				if !ok {
+					errs = append(errs, fmt.Errorf("%s %s is not documented", method, path))
+					continue
+				}
+				if doc.Paths[path] == nil {
+					doc.Paths[path] = openapi.PathItem{}
+				}
+				doc.Paths[path][strings.ToLower(method)] = op.build(schemas, errorBody, params)
+			}
+			return nil
+		})
+		errs = append(errs, err)
+	}
+	return doc, errors.Join(errs...)
+}
+
+func (o operation) build(schemas openapi.Schemas, errorBody *openapi.Schema, params []openapi.Parameter) *openapi.Operation {
+	op := &openapi.Operation{
+		Summary:    o.summary,
+		Tags:       []string{o.tag},
+		Parameters: params,
+		Responses:  map[string]openapi.Response{},
+	}
+	for _, name := range o.query {
+		op.Parameters = append(op.Parameters, openapi.Parameter{Name: name, In: "query", Schema: &openapi.Schema{Type: "string"}})
+	}
+
+	status := cmp.Or(o.status, http.StatusOK)
+	success := openapi.Response{Description: http.StatusText(status)}
+	if o.result != nil {
+		success.Content = openapi.JSON(schemas.Of(o.result))
+	}
+	op.Responses[strconv.Itoa(status)] = success
+
+	failures := slices.Clone(o.errors)
+	if o.body != nil {
+		op.RequestBody = &openapi.RequestBody{Required: true, Content: openapi.JSON(schemas.Of(o.body))}
+		failures = append(failures, http.StatusBadRequest)
+	}
+	if !o.public {
+		op.Security = []openapi.SecurityRequirement{{"bearer": {}}}
+		failures = append(failures, http.StatusUnauthorized)
+	}
+	failures = append(failures, http.StatusInternalServerError)
+	for _, status := range failures {
+		op.Responses[strconv.Itoa(status)] = openapi.Response{
+			Description: http.StatusText(status),
+			Content:     openapi.JSON(errorBody),
+		}
+	}
+	return op
+}
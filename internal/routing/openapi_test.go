@@ -0,0 +1,123 @@
+package routing
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"redditclone/internal/config"
+	"redditclone/pkg/middleware"
+	"redditclone/pkg/openapi"
+)
+
+// apiRouters builds the routes of both versions of the API, nothing connects
+// to the databases before a request.
+func apiRouters(t *testing.T) (api, v2 *mux.Router) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	cfg := &config.Config{TOTPEncryptionKey: base64.StdEncoding.EncodeToString(make([]byte, 32))}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	root := mux.NewRouter()
+	v2 = root.PathPrefix("/api/v2").Subrouter()
+	api = root.PathPrefix("/api").Subrouter()
+	InitRoutes(cfg, api, v2, db, client.Database("test"), logger, nil, nil, nil)
+	ServeOpenAPI(api, v2, logger)
+	return api, v2
+}
+
+func versions(api, v2 *mux.Router) []apiVersion {
+	return []apiVersion{
+		{router: api, prefix: "/api", operations: v1Operations},
+		{router: v2, prefix: "/api/v2", operations: v2Operations},
+	}
+}
+
+func TestAPISpec_DocumentsEveryRoute(t *testing.T) {
+	api, v2 := apiRouters(t)
+
+	doc, err := apiSpec(versions(api, v2)...)
+	assert.NoError(t, err)
+
+	vote := doc.Paths["/api/post/{post_id}/{action}"]["get"]
+	if assert.NotNil(t, vote) {
+		assert.Equal(t, "^(?:upvote|downvote|unvote)$", vote.Parameters[1].Schema.Pattern)
+		assert.Contains(t, vote.Responses, "401")
+	}
+	login := doc.Paths["/api/login"]["post"]
+	if assert.NotNil(t, login) {
+		assert.Empty(t, login.Security)
+		assert.Equal(t, "#/components/schemas/handlers.LoginForm", login.RequestBody.Content["application/json"].Schema.Ref)
+	}
+	assert.Contains(t, doc.Paths["/api/v2/login"], "post")
+	list := doc.Paths["/api/v2/posts"]["get"]
+	if assert.NotNil(t, list) {
+		assert.Empty(t, list.Security)
+		assert.Equal(t, "#/components/schemas/v2.PostPage", list.Responses["200"].Content["application/json"].Schema.Ref)
+	}
+	assert.NotContains(t, doc.Paths, "/api/v2/oidc/login")
+	assert.Contains(t, doc.Components.Schemas, "handlers.Post")
+	assert.Contains(t, doc.Components.Schemas, "v2.Post")
+	assert.Contains(t, doc.Components.Schemas, "apperr.Body")
+
+	api.HandleFunc("/undocumented", func(http.ResponseWriter, *http.Request) {}).Methods("PUT")
+	_, err = apiSpec(versions(api, v2)...)
+	assert.ErrorContains(t, err, "PUT /api/undocumented is not documented")
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	api, _ := apiRouters(t)
+
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var doc map[string]any
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.3", doc["openapi"])
+
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest("GET", "/api/docs", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `spec-url="/api/openapi.json"`)
+	assert.Contains(t, w.Body.String(), `<script src="`+docsScript+`" crossorigin="anonymous">`)
+	assert.Contains(t, w.Header().Get("Content-Security-Policy"), "script-src "+docsScript+" blob:;")
+}
+
+func TestPublicRoutesMatchOperations(t *testing.T) {
+	documented := map[string]bool{}
+	for prefix, operations := range map[string]map[string]operation{"/api": v1Operations, "/api/v2": v2Operations} {
+		for key, op := range operations {
+			if op.public {
+				method, path, _ := strings.Cut(key, " ")
+				documented[method+" "+prefix+path] = true
+			}
+		}
+	}
+
+	open := map[string]bool{}
+	for template, method := range middleware.PublicRoutes() {
+		path, _ := openapi.Path(template)
+		open[method+" "+path] = true
+	}
+
+	assert.Equal(t, documented, open)
+}
@@ -1,86 +1,315 @@
 package routing
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"log"
 	"log/slog"
 	"net/http"
+	"path"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/mongo"
 
+	"redditclone/internal/config"
+	"redditclone/internal/logger"
+	"redditclone/pkg/apitoken"
+	"redditclone/pkg/apperr"
+	"redditclone/pkg/audit"
+	"redditclone/pkg/block"
 	"redditclone/pkg/handlers"
+	v2handlers "redditclone/pkg/handlers/v2"
+	"redditclone/pkg/health"
+	"redditclone/pkg/jwtkeys"
+	"redditclone/pkg/mailer"
+	"redditclone/pkg/metrics"
+	"redditclone/pkg/middleware"
+	"redditclone/pkg/oidc"
 	"redditclone/pkg/post"
+	"redditclone/pkg/secretbox"
 	"redditclone/pkg/session"
+	"redditclone/pkg/token"
 	"redditclone/pkg/user"
 )
 
-const (
-	staticPath   = "./static"
-	postCategory = "music|funny|videos|programming|news|fashion"
-)
+var postCategory = strings.Join(post.Categories, "|")
+
+func InitRoutes(cfg *config.Config, api, v2 *mux.Router, db *sql.DB, mongoDB *mongo.Database, logger *slog.Logger, sessionRepo session.Repository, tokens token.Issuer, apiTokens *apitoken.Service) {
+
+	auditLog := audit.NewSlogLogger(logger)
+
+	mail := mailer.New(cfg.Mailer, logger)
 
-func InitRoutes(api *mux.Router, db *sql.DB, mongoDB *mongo.Database, logger *slog.Logger) {
+	userRepo := user.NewMySQLRepo(db)
+
+	emailService := user.NewEmailService(userRepo, user.NewMySQLVerificationRepo(db), mail, auditLog)
+	emailService.VerifyURL = cfg.PublicURL + "/verify-email?token="
+	emailHandler := handlers.NewEmailHandler(emailService, logger)
+
+	// Without TOTP_ENCRYPTION_KEY the box stays nil and 2FA cannot be enrolled.
+	var box *secretbox.Box
+	if cfg.TOTPEncryptionKey != "" {
+		b, err := secretbox.New(cfg.TOTPEncryptionKey)
+		if err != nil {
+			fatal("TOTP_ENCRYPTION_KEY", err)
+		}
+		box = b
+	}
+	throttle := user.NewThrottle(user.NewMySQLAttemptRepo(db), auditLog)
+	twoFactorService := user.NewTwoFactorService(user.NewMySQLTwoFactorRepo(db), userRepo, sessionRepo, box, auditLog)
+	twoFactorService.Throttle = throttle
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService, tokens, logger)
 
-	sessionRepo := session.NewMySQLSessionRepo(db)
+	userService := user.NewService(userRepo, sessionRepo)
+	userService.Email = emailService
+	userService.TwoFactor = twoFactorService
+	userService.Throttle = throttle
+	userHandler := handlers.NewUserHandler(userService, tokens, logger)
 
-	userService := user.NewService(user.NewMySQLRepo(db), sessionRepo)
-	userHandler := handlers.NewUserHandler(userService, logger)
+	identityService := user.NewIdentityService(userRepo, user.NewMySQLIdentityRepo(db), sessionRepo, auditLog)
+	identityService.TwoFactor = twoFactorService
 
-	postService := &post.PostService{Repo: post.NewMongoRepo(mongoDB)}
+	var oidcHandler *handlers.OIDCHandler
+	if cfg.OIDC != nil {
+		provider, err := oidc.NewProvider(context.Background(), cfg.OIDC, oidc.NewMySQLStateRepo(db))
+		if err != nil {
+			fatal("oidc", err)
+		}
+		oidcHandler = handlers.NewOIDCHandler(provider, identityService, tokens, logger)
+		oidcHandler.SecureCookie = strings.HasPrefix(cfg.PublicURL, "https://")
+		oidcHandler.FrontendURL = cfg.PublicURL + "/sso"
+	}
+
+	passwordService := user.NewPasswordService(userRepo, sessionRepo, user.NewMySQLResetRepo(db), mail, auditLog)
+	passwordService.ResetURL = cfg.PublicURL + "/reset-password?token="
+	passwordHandler := handlers.NewPasswordHandler(passwordService, tokens, logger)
+
+	blockRepo := block.NewMySQLRepo(db)
+	blockService := block.NewService(blockRepo, userRepo)
+	blockHandler := handlers.NewBlockHandler(blockService, logger)
+
+	apiTokenHandler := handlers.NewAPITokenHandler(apiTokens, logger)
+
+	postService := &post.PostService{Repo: post.NewMongoRepo(mongoDB), Blocks: blockRepo}
 	postHandler := handlers.NewPostHandler(postService, logger)
+	postHandlerV2 := v2handlers.NewPostHandler(postService, logger)
 
 	/* -+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+ */
 
-	authRouter := api.PathPrefix("").Subrouter()
-	postsRouter := api.PathPrefix("/posts").Subrouter()
-	userRouter := api.PathPrefix("/user").Subrouter()
-	postRouter := api.PathPrefix("/post").Subrouter()
+	for _, r := range []*mux.Router{api, v2} {
+		r.Use(middleware.RequireVerified(emailService, cfg.UnverifiedBlockedRoutes))
+		r.Use(middleware.RequireScope(map[string]string{
+			"posts":          apitoken.ScopePosts,
+			"post_delete":    apitoken.ScopePosts,
+			"comment":        apitoken.ScopeComment,
+			"comment_delete": apitoken.ScopeComment,
+			"vote":           apitoken.ScopeVotes,
+		}, apitoken.ScopeRead))
+	}
 
-	/* auth routers */
-	authRouter.HandleFunc("/register", userHandler.Register).Methods("POST").Name("register")
-	authRouter.HandleFunc("/login", userHandler.Login).Methods("POST").Name("login")
+	/* auth routers, the identity provider only knows the callback of v1 */
+	if oidcHandler != nil {
+		api.HandleFunc("/oidc/login", oidcHandler.Login).Methods("GET").Name("oidc_login")
+		api.HandleFunc("/oidc/callback", oidcHandler.Callback).Methods("GET").Name("oidc_callback")
+	}
+
+	/* the account routes are the same in every version */
+	account := accountHandlers{
+		user:      userHandler,
+		twoFactor: twoFactorHandler,
+		password:  passwordHandler,
+		email:     emailHandler,
+		block:     blockHandler,
+		apiToken:  apiTokenHandler,
+	}
+	account.routes(api)
+	account.routes(v2)
 
 	/* posts routers */
-	postsRouter.HandleFunc("", postHandler.CreatePost).Methods("POST")
+	postsRouter := api.PathPrefix("/posts").Subrouter()
+	postsRouter.HandleFunc("", postHandler.CreatePost).Methods("POST").Name("posts")
 	postsRouter.HandleFunc("/", postHandler.GetAllPosts).Methods("GET")
 	postsRouter.HandleFunc("/{category:(?:"+postCategory+")}", postHandler.GetPostsByCategory).Methods("GET")
 
-	/* user routers */
-	userRouter.HandleFunc("/{login:[a-zA-Z0-9]+}", postHandler.GetPostsByUser).Methods("GET")
+	api.HandleFunc("/user/{login:[a-zA-Z0-9]+}", postHandler.GetPostsByUser).Methods("GET")
 
-	/* posts routers */
+	postRouter := api.PathPrefix("/post").Subrouter()
 	postRouter.HandleFunc("/{post_id:[a-zA-Z0-9]+}", postHandler.GetPostByID).Methods("GET")
-	postRouter.HandleFunc("/{post_id:[a-zA-Z0-9]+}", postHandler.AddComment).Methods("POST")
-	postRouter.HandleFunc("/{post_id:[a-zA-Z0-9]+}", postHandler.DeletePost).Methods("DELETE")
-	postRouter.HandleFunc("/{post_id:[a-zA-Z0-9]+}/{comm_id:[a-zA-Z0-9]+}", postHandler.RemoveComment).Methods("DELETE")
-	postRouter.HandleFunc("/{post_id:[a-zA-Z0-9]+}/{action:(?:upvote|downvote|unvote)}", postHandler.AddVote).Methods("GET")
+	postRouter.HandleFunc("/{post_id:[a-zA-Z0-9]+}", postHandler.AddComment).Methods("POST").Name("comment")
+	postRouter.HandleFunc("/{post_id:[a-zA-Z0-9]+}", postHandler.DeletePost).Methods("DELETE").Name("post_delete")
+	postRouter.HandleFunc("/{post_id:[a-zA-Z0-9]+}/{comm_id:[a-zA-Z0-9]+}", postHandler.RemoveComment).Methods("DELETE").Name("comment_delete")
+	postRouter.HandleFunc("/{post_id:[a-zA-Z0-9]+}/{action:(?:upvote|downvote|unvote)}", postHandler.AddVote).Methods("GET").Name("vote")
+
+	/* v2 posts routers, the names match v1 so scopes and limits apply alike */
+	v2.HandleFunc("/posts", postHandlerV2.List).Methods("GET")
+	v2.HandleFunc("/posts", postHandlerV2.Create).Methods("POST").Name("posts")
+	v2.HandleFunc("/posts/{post_id:[0-9a-f]{24}}", postHandlerV2.Get).Methods("GET")
+	v2.HandleFunc("/posts/{post_id:[0-9a-f]{24}}", postHandlerV2.Delete).Methods("DELETE").Name("post_delete")
+	v2.HandleFunc("/posts/{post_id:[0-9a-f]{24}}/comments", postHandlerV2.AddComment).Methods("POST").Name("comment")
+	v2.HandleFunc("/posts/{post_id:[0-9a-f]{24}}/comments/{comm_id:[a-zA-Z0-9]+}", postHandlerV2.RemoveComment).Methods("DELETE").Name("comment_delete")
+	v2.HandleFunc("/posts/{post_id:[0-9a-f]{24}}/{action:(?:upvote|downvote|unvote)}", postHandlerV2.Vote).Methods("POST").Name("vote")
+	v2.HandleFunc("/users/{login:[a-zA-Z0-9]+}/posts", postHandlerV2.ListByUser).Methods("GET")
 }
 
-func ServeStaticFiles(r *mux.Router) {
+type accountHandlers struct {
+	user      *handlers.Handler
+	twoFactor *handlers.TwoFactorHandler
+	password  *handlers.PasswordHandler
+	email     *handlers.EmailHandler
+	block     *handlers.BlockHandler
+	apiToken  *handlers.APITokenHandler
+}
+
+// routes adds the routes of logins and account settings to the router of a
+// version.
+func (h accountHandlers) routes(r *mux.Router) {
+	/* auth routers */
+	r.HandleFunc("/register", h.user.Register).Methods("POST").Name("register")
+	r.HandleFunc("/login", h.user.Login).Methods("POST").Name("login")
+	r.HandleFunc("/login/2fa", h.twoFactor.Complete).Methods("POST").Name("login_2fa")
+
+	/* user routers */
+	userRouter := r.PathPrefix("/user").Subrouter()
+	userRouter.HandleFunc("/password", h.password.ChangePassword).Methods("POST").Name("password")
+	userRouter.HandleFunc("/email", h.email.SetEmail).Methods("POST").Name("email")
+	userRouter.HandleFunc("/2fa/enroll", h.twoFactor.Enroll).Methods("POST").Name("2fa_enroll")
+	userRouter.HandleFunc("/2fa/confirm", h.twoFactor.Confirm).Methods("POST").Name("2fa_confirm")
+	userRouter.HandleFunc("/2fa/disable", h.twoFactor.Disable).Methods("POST").Name("2fa_disable")
+
+	/* password routers */
+	passwordRouter := r.PathPrefix("/password").Subrouter()
+	passwordRouter.HandleFunc("/reset", h.password.RequestReset).Methods("POST").Name("password_reset")
+	passwordRouter.HandleFunc("/reset/confirm", h.password.Reset).Methods("POST").Name("password_reset_confirm")
+
+	/* email routers */
+	emailRouter := r.PathPrefix("/email").Subrouter()
+	emailRouter.HandleFunc("/verify", h.email.Verify).Methods("POST").Name("email_verify")
+	emailRouter.HandleFunc("/resend", h.email.Resend).Methods("POST").Name("email_resend")
+
+	/* block routers */
+	blocksRouter := r.PathPrefix("/blocks").Subrouter()
+	blocksRouter.HandleFunc("", h.block.List).Methods("GET")
+	blocksRouter.HandleFunc("/{login:[a-zA-Z0-9]+}", h.block.Block).Methods("POST")
+	blocksRouter.HandleFunc("/{login:[a-zA-Z0-9]+}", h.block.Unblock).Methods("DELETE")
+	mutesRouter := r.PathPrefix("/mutes").Subrouter()
+	mutesRouter.HandleFunc("/{login:[a-zA-Z0-9]+}", h.block.Mute).Methods("POST")
+	mutesRouter.HandleFunc("/{login:[a-zA-Z0-9]+}", h.block.Unmute).Methods("DELETE")
+
+	/* api token routers */
+	tokensRouter := r.PathPrefix("/tokens").Subrouter()
+	tokensRouter.HandleFunc("", h.apiToken.List).Methods("GET")
+	tokensRouter.HandleFunc("", h.apiToken.Create).Methods("POST").Name("tokens")
+	tokensRouter.HandleFunc("/{token_id:[a-zA-Z0-9]+}", h.apiToken.Revoke).Methods("DELETE")
+}
+
+// AliasV1 serves /api/v1 as /api, which stays the version of the Asperitas
+// frontend. The path is rewritten before routing, so both share every route,
+// middleware and route template.
+func AliasV1(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rest, ok := strings.CutPrefix(r.URL.Path, "/api/v1"); ok && (rest == "" || rest[0] == '/') {
+			u := *r.URL
+			u.Path, u.RawPath = "/api"+rest, ""
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = &u
+			r = r2
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func ServeStaticFiles(r *mux.Router, staticPath string) {
 	fs := http.FileServer(http.Dir(staticPath))
 	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/", fs))
 }
 
-func ServeFallback(r *mux.Router, logger *slog.Logger) {
-	r.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/static/") {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			if _, err := w.Write([]byte("[]")); err != nil { 
-				logger.Error("failed to write fallback JSON", slog.String("path", r.URL.Path), slog.Any("error", err))
+// ServeJWKS publishes the public token keys for other services.
+func ServeJWKS(r *mux.Router, keys *jwtkeys.Keyring, logger *slog.Logger) {
+	r.Handle("/.well-known/jwks.json", handlers.NewJWKSHandler(keys, logger)).Methods("GET")
+}
+
+// ServeMetrics exposes Prometheus metrics, behind the bearer token
+// METRICS_TOKEN when it is set.
+func ServeMetrics(r *mux.Router, bearer string) {
+	r.Handle("/metrics", metrics.Handler(bearer)).Methods("GET")
+}
+
+// ServeHealth adds the liveness and readiness probes. They are on the root
+// router, outside of the JWT check, and must be added before the fallback.
+func ServeHealth(r *mux.Router, checker *health.Checker) {
+	r.HandleFunc("/healthz", health.Live).Methods("GET")
+	r.HandleFunc("/readyz", checker.Ready).Methods("GET")
+}
+
+// fallbackRoute names the route of ServeFallback, which allowedMethods must
+// not count.
+const fallbackRoute = "fallback"
+
+// probeMethods are tried on a path to tell an unknown path from a known one
+// requested with the wrong method.
+var probeMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// ServeFallback answers the requests no other route took, it must be added
+// last. Under /api that is a JSON 404, or a 405 listing the methods in Allow
+// when the path exists with other methods. OPTIONS is answered with the same
+// list. Missing files, under /static or with an extension, are a plain 404,
+// any other GET is a deep link of the single page app and gets index.html.
+func ServeFallback(r *mux.Router, staticPath string) {
+	index := filepath.Join(staticPath, "html", "index.html")
+	r.PathPrefix("/").Name(fallbackRoute).HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		api := strings.HasPrefix(req.URL.Path, "/api/")
+		page := !api && !strings.HasPrefix(req.URL.Path, "/static/") && path.Ext(req.URL.Path) == ""
+
+		allowed := allowedMethods(r, req)
+		if len(allowed) == 0 && page {
+			allowed = []string{http.MethodGet, http.MethodHead}
+		}
+
+		switch {
+		case len(allowed) == 0:
+			if api {
+				apperr.Write(w, http.StatusNotFound, "not found")
+				return
+			}
+			http.NotFound(w, req)
+		case req.Method == http.MethodOptions:
+			w.Header().Set("Allow", strings.Join(append(allowed, http.MethodOptions), ", "))
+			w.WriteHeader(http.StatusNoContent)
+		case !slices.Contains(allowed, req.Method):
+			w.Header().Set("Allow", strings.Join(append(allowed, http.MethodOptions), ", "))
+			if api {
+				apperr.Write(w, http.StatusMethodNotAllowed, "method not allowed")
+				return
 			}
-			return
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		default:
+			http.ServeFile(w, req, index)
 		}
-		http.ServeFile(w, r, "static/html/index.html")
 	})
 }
 
-func StartServer(r *mux.Router) {
-	fmt.Println("\n\033[32m", "The server is running on http://localhost:8082", "\033[0m")
-	if err := http.ListenAndServe(":8082", r); err != nil {
-		log.Fatal("Server failed:", err)
+// allowedMethods returns the methods the routes of router accept for the path
+// of req.
+func allowedMethods(router *mux.Router, req *http.Request) []string {
+	var allowed []string
+	for _, method := range probeMethods {
+		probe := *req
+		probe.Method = method
+		var match mux.RouteMatch
+		if router.Match(&probe, &match) && match.MatchErr == nil && match.Route.GetName() != fallbackRoute {
+			allowed = append(allowed, method)
+		}
 	}
+	return allowed
+}
+
+// fatal reports a startup error and exits. It goes through the default
+// logger, which main points at the app logger.
+func fatal(msg string, err error) {
+	logger.Fatal(msg, "error", err)
 }
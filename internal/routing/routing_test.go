@@ -0,0 +1,87 @@
+package routing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"redditclone/internal/routing"
+)
+
+func TestServeFallback(t *testing.T) {
+	static := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(static, "html"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(static, "html", "index.html"), []byte("<html>app</html>"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(static, "app.js"), []byte("app()"), 0o644))
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("route")) }
+	r := mux.NewRouter()
+	api := r.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/login", ok).Methods("POST")
+	api.HandleFunc("/post/{post_id:[a-z0-9]+}", ok).Methods("GET")
+	api.HandleFunc("/post/{post_id:[a-z0-9]+}", ok).Methods("DELETE")
+	r.HandleFunc("/healthz", ok).Methods("GET")
+	routing.ServeStaticFiles(r, static)
+	routing.ServeFallback(r, static)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		allow  string
+		body   string
+	}{
+		{"route", "POST", "/api/login", http.StatusOK, "", "route"},
+		{"unknown api path", "GET", "/api/nope", http.StatusNotFound, "", `{"code":"not_found","message":"not found"}` + "\n"},
+		{"wrong api method", "GET", "/api/login", http.StatusMethodNotAllowed, "POST, OPTIONS", `{"code":"method_not_allowed","message":"method not allowed"}` + "\n"},
+		{"methods of a path", "PUT", "/api/post/abc", http.StatusMethodNotAllowed, "GET, DELETE, OPTIONS", ""},
+		{"options", "OPTIONS", "/api/post/abc", http.StatusNoContent, "GET, DELETE, OPTIONS", ""},
+		{"options of an unknown path", "OPTIONS", "/api/nope", http.StatusNotFound, "", ""},
+		{"static file", "GET", "/static/app.js", http.StatusOK, "", "app()"},
+		{"missing static file", "GET", "/static/gone.js", http.StatusNotFound, "", ""},
+		{"missing file at the root", "GET", "/favicon.ico", http.StatusNotFound, "", ""},
+		{"deep link", "GET", "/u/alice", http.StatusOK, "", "<html>app</html>"},
+		{"post to a page", "POST", "/u/alice", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS", ""},
+		{"wrong method of a root route", "POST", "/healthz", http.StatusMethodNotAllowed, "GET, OPTIONS", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.allow, w.Header().Get("Allow"))
+			if tt.body != "" {
+				assert.Equal(t, tt.body, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAliasV1(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api/v1/posts/", "/api/posts/"},
+		{"/api/v1", "/api"},
+		{"/api/v2/posts", "/api/v2/posts"},
+		{"/api/v10/posts", "/api/v10/posts"},
+		{"/static/api/v1", "/static/api/v1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			var got string
+			h := routing.AliasV1(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = r.URL.Path }))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tt.path+"?page=2", nil))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Config struct {
+	Addr              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// ShutdownTimeout bounds the drain of in-flight requests, connections
+	// still open after it are closed.
+	ShutdownTimeout time.Duration
+	// DrainDelay keeps accepting requests after the shutdown signal while
+	// the service reports not ready, until load balancers stop routing to it.
+	DrainDelay time.Duration
+
+	// TLSCertFile and TLSKeyFile turn on HTTPS and HTTP/2 on Addr. The files
+	// are reloaded when they change.
+	TLSCertFile string
+	TLSKeyFile  string
+	// RedirectAddr, with TLS on, listens for plain HTTP and redirects it.
+	RedirectAddr string
+}
+
+// TLS reports whether the server terminates TLS itself.
+func (c Config) TLS() bool {
+	return c.TLSCertFile != ""
+}
+
+// ConfigFrom reads HTTP_ADDR, HTTP_READ_TIMEOUT, HTTP_READ_HEADER_TIMEOUT,
+// HTTP_WRITE_TIMEOUT, HTTP_IDLE_TIMEOUT, HTTP_MAX_HEADER_BYTES,
+// HTTP_SHUTDOWN_TIMEOUT, HTTP_DRAIN_DELAY, TLS_CERT_FILE, TLS_KEY_FILE and
+// HTTP_REDIRECT_ADDR through getenv. The error lists every bad setting.
+func ConfigFrom(getenv func(string) string) (Config, error) {
+	cfg := Config{
+		Addr:              ":8082",
+		ReadTimeout:       15 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		MaxHeaderBytes:    http.DefaultMaxHeaderBytes,
+		ShutdownTimeout:   20 * time.Second,
+	}
+	var errs []error
+
+	if v := getenv("HTTP_ADDR"); v != "" {
+		if _, _, err := net.SplitHostPort(v); err != nil {
+			errs = append(errs, fmt.Errorf("bad HTTP_ADDR %q", v))
+		}
+		cfg.Addr = v
+	}
+	durations := []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"HTTP_READ_TIMEOUT", &cfg.ReadTimeout},
+		{"HTTP_READ_HEADER_TIMEOUT", &cfg.ReadHeaderTimeout},
+		{"HTTP_WRITE_TIMEOUT", &cfg.WriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", &cfg.IdleTimeout},
+		{"HTTP_SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout},
+		{"HTTP_DRAIN_DELAY", &cfg.DrainDelay},
+	}
+	for _, d := range durations {
+		v := getenv(d.name)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed < 0 {
+			errs = append(errs, fmt.Errorf("bad %s %q", d.name, v))
+			continue
+		}
+		*d.dst = parsed
+	}
+	if v := getenv("HTTP_MAX_HEADER_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			errs = append(errs, fmt.Errorf("bad HTTP_MAX_HEADER_BYTES %q", v))
+		} else {
+			cfg.MaxHeaderBytes = n
+		}
+	}
+
+	cfg.TLSCertFile = getenv("TLS_CERT_FILE")
+	cfg.TLSKeyFile = getenv("TLS_KEY_FILE")
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+	if v := getenv("HTTP_REDIRECT_ADDR"); v != "" {
+		if !cfg.TLS() {
+			errs = append(errs, errors.New("HTTP_REDIRECT_ADDR needs TLS_CERT_FILE and TLS_KEY_FILE"))
+		} else if _, _, err := net.SplitHostPort(v); err != nil {
+			errs = append(errs, fmt.Errorf("bad HTTP_REDIRECT_ADDR %q", v))
+		}
+		cfg.RedirectAddr = v
+	}
+	return cfg, errors.Join(errs...)
+}
+
+type closer struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Server runs the HTTP server until its context ends, then drains it and
+// releases what was registered with OnShutdown.
+type Server struct {
+	http    *http.Server
+	cfg     Config
+	logger  *slog.Logger
+	drains  []func()
+	closers []closer
+}
+
+func New(cfg Config, handler http.Handler, logger *slog.Logger) *Server {
+	return &Server{
+		http: &http.Server{
+			Addr:              cfg.Addr,
+			Handler:           handler,
+			ReadTimeout:       cfg.ReadTimeout,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+			MaxHeaderBytes:    cfg.MaxHeaderBytes,
+			ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+		},
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// OnDrain registers fn to run as soon as shutdown starts, before DrainDelay.
+func (s *Server) OnDrain(fn func()) {
+	s.drains = append(s.drains, fn)
+}
+
+// OnShutdown registers fn to run once the requests are drained. Functions run
+// in the order they were registered, so workers go before the stores they use.
+func (s *Server) OnShutdown(name string, fn func(ctx context.Context) error) {
+	s.closers = append(s.closers, closer{name: name, fn: fn})
+}
+
+// Run listens on the configured address until ctx is done, typically on
+// SIGINT or SIGTERM.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	if s.cfg.RedirectAddr == "" {
+		return s.Serve(ctx, ln)
+	}
+
+	redirectLn, err := net.Listen("tcp", s.cfg.RedirectAddr)
+	if err != nil {
+		ln.Close()
+		return err
+	}
+	redirect := &http.Server{
+		Handler:           RedirectHandler(s.cfg.Addr),
+		ReadHeaderTimeout: s.cfg.ReadHeaderTimeout,
+		IdleTimeout:       s.cfg.IdleTimeout,
+		MaxHeaderBytes:    s.cfg.MaxHeaderBytes,
+		ErrorLog:          s.http.ErrorLog,
+	}
+	go func() {
+		s.logger.Info("redirect started", "addr", redirectLn.Addr().String())
+		if err := redirect.Serve(redirectLn); !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("redirect failed", "error", err)
+		}
+	}()
+	// registered first so that it stops along with the main server
+	s.closers = append([]closer{{name: "redirect", fn: redirect.Shutdown}}, s.closers...)
+	return s.Serve(ctx, ln)
+}
+
+// Serve is Run on an existing listener, without the redirect listener.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	scheme := "http"
+	if s.cfg.TLS() {
+		certs, err := newCertReloader(s.cfg.TLSCertFile, s.cfg.TLSKeyFile, s.logger)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		s.http.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.GetCertificate,
+		}
+		scheme = "https"
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		s.logger.Info("server started", "addr", ln.Addr().String(), "scheme", scheme)
+		if s.cfg.TLS() {
+			// ServeTLS offers h2 over ALPN, falling back to HTTP/1.1
+			served <- s.http.ServeTLS(ln, "", "")
+			return
+		}
+		served <- s.http.Serve(ln)
+	}()
+
+	var serveErr error
+	select {
+	case err := <-served:
+		// the listener failed, there is nothing left to drain
+		serveErr = err
+	case <-ctx.Done():
+		s.logger.Info("shutting down", "delay", s.cfg.DrainDelay, "timeout", s.cfg.ShutdownTimeout)
+		for _, fn := range s.drains {
+			fn()
+		}
+		time.Sleep(s.cfg.DrainDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	errs := []error{serveErr}
+	if err := s.http.Shutdown(shutdownCtx); err != nil {
+		s.logger.Warn("requests still running, closing connections", "error", err)
+		errs = append(errs, s.http.Close())
+	}
+	for _, c := range s.closers {
+		if err := c.fn(shutdownCtx); err != nil {
+			s.logger.Error("shutdown", "component", c.name, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+		}
+	}
+	s.logger.Info("server stopped")
+	return errors.Join(errs...)
+}
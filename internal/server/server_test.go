@@ -0,0 +1,105 @@
+package server_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"redditclone/internal/server"
+)
+
+func TestConfigFrom(t *testing.T) {
+	cfg, err := server.ConfigFrom(os.Getenv)
+	assert.NoError(t, err)
+	assert.Equal(t, ":8082", cfg.Addr)
+	assert.Equal(t, http.DefaultMaxHeaderBytes, cfg.MaxHeaderBytes)
+
+	t.Setenv("HTTP_ADDR", "127.0.0.1:9000")
+	t.Setenv("HTTP_WRITE_TIMEOUT", "1m")
+	t.Setenv("HTTP_MAX_HEADER_BYTES", "8192")
+	cfg, err = server.ConfigFrom(os.Getenv)
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1:9000", cfg.Addr)
+	assert.Equal(t, time.Minute, cfg.WriteTimeout)
+	assert.Equal(t, 8192, cfg.MaxHeaderBytes)
+
+	t.Setenv("HTTP_REDIRECT_ADDR", ":80")
+	_, err = server.ConfigFrom(os.Getenv)
+	assert.EqualError(t, err, "HTTP_REDIRECT_ADDR needs TLS_CERT_FILE and TLS_KEY_FILE")
+
+	t.Setenv("TLS_CERT_FILE", "cert.pem")
+	_, err = server.ConfigFrom(os.Getenv)
+	assert.EqualError(t, err, "TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+
+	t.Setenv("TLS_KEY_FILE", "key.pem")
+	cfg, err = server.ConfigFrom(os.Getenv)
+	assert.NoError(t, err)
+	assert.True(t, cfg.TLS())
+	assert.Equal(t, ":80", cfg.RedirectAddr)
+
+	t.Setenv("HTTP_IDLE_TIMEOUT", "forever")
+	_, err = server.ConfigFrom(os.Getenv)
+	assert.EqualError(t, err, `bad HTTP_IDLE_TIMEOUT "forever"`)
+
+	t.Setenv("HTTP_ADDR", "8082")
+	_, err = server.ConfigFrom(os.Getenv)
+	assert.EqualError(t, err, "bad HTTP_ADDR \"8082\"\nbad HTTP_IDLE_TIMEOUT \"forever\"")
+}
+
+func TestServe_DrainsThenCloses(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})
+
+	srv := server.New(server.Config{ShutdownTimeout: 5 * time.Second}, handler, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	events := make(chan string, 3)
+	srv.OnDrain(func() { events <- "drain" })
+	srv.OnShutdown("worker", func(context.Context) error {
+		events <- "worker"
+		return nil
+	})
+	srv.OnShutdown("db", func(context.Context) error {
+		events <- "db"
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- srv.Serve(ctx, ln) }()
+
+	body := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		body <- string(b)
+	}()
+
+	<-started
+	cancel()
+	assert.Equal(t, "drain", <-events)
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, events, "closers ran before the request finished")
+	close(release)
+
+	assert.Equal(t, "done", <-body)
+	assert.NoError(t, <-stopped)
+	assert.Equal(t, "worker", <-events)
+	assert.Equal(t, "db", <-events)
+}
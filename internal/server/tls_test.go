@@ -0,0 +1,127 @@
+package server_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"redditclone/internal/server"
+)
+
+// writeCert writes a self-signed pair for 127.0.0.1 and returns its
+// certificate.
+func writeCert(t *testing.T, certFile, keyFile string, serial int64) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "redditclone test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600))
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return cert
+}
+
+func TestServe_TLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	first := writeCert(t, certFile, keyFile, 1)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	srv := server.New(server.Config{
+		ShutdownTimeout: time.Second,
+		TLSCertFile:     certFile,
+		TLSKeyFile:      keyFile,
+	}, handler, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- srv.Serve(ctx, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		assert.NoError(t, <-stopped)
+	})
+
+	// fetch dials a new connection and returns the protocol and the serial
+	// of the certificate the server presented
+	fetch := func(trusted *x509.Certificate) (string, int64) {
+		roots := x509.NewCertPool()
+		roots.AddCert(trusted)
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots},
+			ForceAttemptHTTP2: true,
+		}}
+		res, err := client.Get("https://" + ln.Addr().String())
+		assert.NoError(t, err)
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return string(body), res.TLS.PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	proto, serial := fetch(first)
+	assert.Equal(t, "HTTP/2.0", proto)
+	assert.Equal(t, int64(1), serial)
+
+	second := writeCert(t, certFile, keyFile, 2)
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(certFile, later, later))
+	assert.NoError(t, os.Chtimes(keyFile, later, later))
+	time.Sleep(1100 * time.Millisecond)
+
+	_, serial = fetch(second)
+	assert.Equal(t, int64(2), serial)
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		addr, host, want string
+	}{
+		{":443", "example.com", "https://example.com/api/posts/?x=1"},
+		{":443", "example.com:80", "https://example.com/api/posts/?x=1"},
+		{":8443", "example.com:8080", "https://example.com:8443/api/posts/?x=1"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/posts/?x=1", nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+
+		server.RedirectHandler(tt.addr).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+		assert.Equal(t, tt.want, w.Header().Get("Location"))
+	}
+}
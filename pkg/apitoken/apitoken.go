@@ -0,0 +1,195 @@
+package apitoken
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"redditclone/pkg/apperr"
+	"redditclone/pkg/audit"
+	"redditclone/pkg/claims"
+	"redditclone/pkg/generator"
+	"redditclone/pkg/user"
+)
+
+const (
+	// Prefix tells personal tokens apart from JWTs in the Authorization header.
+	Prefix = "rcp_"
+
+	secretLen  = 40
+	idLen      = 12
+	maxNameLen = 64
+	maxPerUser = 20
+	touchEvery = time.Minute
+)
+
+const (
+	ScopeRead    = "read"
+	ScopePosts   = "posts:write"
+	ScopeComment = "comments:write"
+	ScopeVotes   = "votes:write"
+)
+
+var Scopes = []string{ScopeRead, ScopePosts, ScopeComment, ScopeVotes}
+
+var (
+	ErrInvalidName   = apperr.New(apperr.Invalid, "invalid_token_name", "invalid token name")
+	ErrInvalidScope  = apperr.New(apperr.Invalid, "invalid_scope", "invalid scope")
+	ErrInvalidExpiry = apperr.New(apperr.Invalid, "invalid_expiry", "invalid expiry")
+	ErrTooMany       = apperr.New(apperr.Conflict, "too_many_tokens", "too many tokens")
+	ErrInvalidToken  = apperr.New(apperr.Unauthorized, "invalid_token", "invalid token")
+	ErrNotFound      = apperr.New(apperr.NotFound, "token_not_found", "token not found")
+)
+
+type Token struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"-"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	TokenHash  string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+type Repository interface {
+	Create(ctx context.Context, t *Token) error
+	ListByUser(ctx context.Context, userID string) ([]Token, error)
+	FindByHash(ctx context.Context, tokenHash string) (*Token, error)
+	Delete(ctx context.Context, userID, id string) error
+	Touch(ctx context.Context, id string, at time.Time) error
+}
+
+type ServiceInterface interface {
+	Create(ctx context.Context, userID, name string, scopes []string, ttl time.Duration) (string, *Token, error)
+	List(ctx context.Context, userID string) ([]Token, error)
+	Revoke(ctx context.Context, userID, id string) error
+}
+
+type Service struct {
+	Repo  Repository
+	Users user.Repository
+	Audit audit.Logger
+	Now   func() time.Time
+}
+
+func NewService(repo Repository, users user.Repository, auditLog audit.Logger) *Service {
+	return &Service{
+		Repo:  repo,
+		Users: users,
+		Audit: auditLog,
+		Now:   time.Now,
+	}
+}
+
+// Create returns the plaintext token, it is shown to the user only once.
+// A zero ttl makes a token that never expires.
+func (s *Service) Create(ctx context.Context, userID, name string, scopes []string, ttl time.Duration) (string, *Token, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxNameLen {
+		return "", nil, ErrInvalidName
+	}
+	if len(scopes) == 0 {
+		return "", nil, ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return "", nil, ErrInvalidScope
+		}
+	}
+	if ttl < 0 {
+		return "", nil, ErrInvalidExpiry
+	}
+
+	existing, err := s.Repo.ListByUser(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(existing) >= maxPerUser {
+		return "", nil, ErrTooMany
+	}
+
+	secret, err := generator.GenerateRandomID(secretLen)
+	if err != nil {
+		return "", nil, fmt.Errorf("token gen error: %s", err)
+	}
+	id, err := generator.GenerateRandomID(idLen)
+	if err != nil {
+		return "", nil, fmt.Errorf("token id gen error: %s", err)
+	}
+
+	now := s.Now().UTC()
+	t := &Token{
+		ID:        id,
+		UserID:    userID,
+		Name:      name,
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(scopes))),
+		TokenHash: hashToken(Prefix + secret),
+		CreatedAt: now,
+	}
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		t.ExpiresAt = &expires
+	}
+
+	if err := s.Repo.Create(ctx, t); err != nil {
+		return "", nil, err
+	}
+
+	s.Audit.Record("api_token_created", "user", userID, "token", id, "scopes", strings.Join(t.Scopes, " "))
+	return Prefix + secret, t, nil
+}
+
+func (s *Service) List(ctx context.Context, userID string) ([]Token, error) {
+	return s.Repo.ListByUser(ctx, userID)
+}
+
+func (s *Service) Revoke(ctx context.Context, userID, id string) error {
+	if err := s.Repo.Delete(ctx, userID, id); err != nil {
+		return err
+	}
+	s.Audit.Record("api_token_revoked", "user", userID, "token", id)
+	return nil
+}
+
+// Verify checks a personal token, CheckJWT hands it every token with Prefix.
+func (s *Service) Verify(ctx context.Context, raw string) (*claims.Claims, error) {
+	if !strings.HasPrefix(raw, Prefix) {
+		return nil, ErrInvalidToken
+	}
+
+	t, err := s.Repo.FindByHash(ctx, hashToken(raw))
+	if err != nil {
+		return nil, err
+	}
+	now := s.Now()
+	if t.ExpiresAt != nil && !now.Before(*t.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	u, err := s.Users.FindByID(ctx, t.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// last use is informational, a write per request is not worth it
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) > touchEvery {
+		if err := s.Repo.Touch(ctx, t.ID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	c := &claims.Claims{APIToken: t.ID, Scopes: t.Scopes}
+	c.User.Username = u.Username
+	c.User.ID = u.ID
+	return c, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,112 @@
+package apitoken_test
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"redditclone/pkg/apitoken"
+	"redditclone/pkg/audit"
+	"redditclone/pkg/user"
+)
+
+func setupService(t *testing.T) *apitoken.Service {
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+	CREATE TABLE users (
+		id TEXT PRIMARY KEY,
+		username TEXT NOT NULL UNIQUE,
+		password TEXT NOT NULL,
+		email TEXT NULL UNIQUE,
+		verified BOOLEAN NOT NULL DEFAULT FALSE,
+		totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+		role TEXT NOT NULL DEFAULT 'user'
+	);
+	CREATE TABLE api_tokens (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		scopes TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		last_used_at DATETIME NULL,
+		expires_at DATETIME NULL
+	);`)
+	assert.NoError(t, err)
+
+	users := user.NewMySQLRepo(db)
+	assert.NoError(t, users.Create(context.Background(), &user.User{ID: "uid", Username: "bot", Password: "x"}))
+
+	return apitoken.NewService(apitoken.NewMySQLRepo(db), users, audit.Nop{})
+}
+
+func TestService_CreateAndVerify(t *testing.T) {
+	svc := setupService(t)
+
+	secret, token, err := svc.Create(context.Background(), "uid", "release notes", []string{apitoken.ScopePosts, apitoken.ScopeRead, apitoken.ScopePosts}, 0)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, apitoken.Prefix))
+	assert.Equal(t, []string{apitoken.ScopePosts, apitoken.ScopeRead}, token.Scopes)
+	assert.Nil(t, token.ExpiresAt)
+
+	c, err := svc.Verify(context.Background(), secret)
+	assert.NoError(t, err)
+	assert.Equal(t, "bot", c.User.Username)
+	assert.Equal(t, "uid", c.User.ID)
+	assert.Equal(t, token.ID, c.APIToken)
+	assert.True(t, c.Allows(apitoken.ScopePosts))
+	assert.False(t, c.Allows(apitoken.ScopeComment))
+
+	tokens, err := svc.List(context.Background(), "uid")
+	assert.NoError(t, err)
+	assert.Len(t, tokens, 1)
+	assert.NotNil(t, tokens[0].LastUsedAt)
+	assert.NotContains(t, tokens[0].TokenHash, secret)
+
+	_, err = svc.Verify(context.Background(), secret+"x")
+	assert.EqualError(t, err, "invalid token")
+	_, err = svc.Verify(context.Background(), "not-a-token")
+	assert.EqualError(t, err, "invalid token")
+
+	assert.EqualError(t, svc.Revoke(context.Background(), "someone-else", token.ID), "token not found")
+	assert.NoError(t, svc.Revoke(context.Background(), "uid", token.ID))
+	_, err = svc.Verify(context.Background(), secret)
+	assert.EqualError(t, err, "invalid token")
+}
+
+func TestService_Validation(t *testing.T) {
+	svc := setupService(t)
+
+	_, _, err := svc.Create(context.Background(), "uid", " ", []string{apitoken.ScopeRead}, 0)
+	assert.EqualError(t, err, "invalid token name")
+	_, _, err = svc.Create(context.Background(), "uid", "bot", nil, 0)
+	assert.EqualError(t, err, "invalid scope")
+	_, _, err = svc.Create(context.Background(), "uid", "bot", []string{"admin"}, 0)
+	assert.EqualError(t, err, "invalid scope")
+	_, _, err = svc.Create(context.Background(), "uid", "bot", []string{apitoken.ScopeRead}, -time.Hour)
+	assert.EqualError(t, err, "invalid expiry")
+}
+
+func TestService_Expiry(t *testing.T) {
+	svc := setupService(t)
+	now := time.Now()
+	svc.Now = func() time.Time { return now }
+
+	secret, token, err := svc.Create(context.Background(), "uid", "bot", []string{apitoken.ScopeRead}, 24*time.Hour)
+	assert.NoError(t, err)
+	assert.NotNil(t, token.ExpiresAt)
+
+	_, err = svc.Verify(context.Background(), secret)
+	assert.NoError(t, err)
+
+	now = now.Add(25 * time.Hour)
+	_, err = svc.Verify(context.Background(), secret)
+	assert.EqualError(t, err, "invalid token")
+}
@@ -0,0 +1,114 @@
+package apitoken
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"redditclone/pkg/dbcall"
+)
+
+type MySQLRepo struct {
+	DB *sql.DB
+}
+
+func NewMySQLRepo(db *sql.DB) *MySQLRepo {
+	return &MySQLRepo{DB: db}
+}
+
+func (r *MySQLRepo) Create(ctx context.Context, t *Token) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "api_tokens", "Create")
+	defer done()
+	_, err := r.DB.ExecContext(ctx,
+		"INSERT INTO api_tokens (id, user_id, name, token_hash, scopes, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		t.ID, t.UserID, t.Name, t.TokenHash, strings.Join(t.Scopes, " "), t.CreatedAt.UTC(), nullTime(t.ExpiresAt),
+	)
+	return err
+}
+
+func (r *MySQLRepo) ListByUser(ctx context.Context, userID string) ([]Token, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "api_tokens", "ListByUser")
+	defer done()
+	rows, err := r.DB.QueryContext(ctx,
+		"SELECT id, user_id, name, token_hash, scopes, created_at, last_used_at, expires_at FROM api_tokens WHERE user_id = ? ORDER BY created_at",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []Token{}
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, rows.Err()
+}
+
+func (r *MySQLRepo) FindByHash(ctx context.Context, tokenHash string) (*Token, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "api_tokens", "FindByHash")
+	defer done()
+	t, err := scanToken(r.DB.QueryRowContext(ctx,
+		"SELECT id, user_id, name, token_hash, scopes, created_at, last_used_at, expires_at FROM api_tokens WHERE token_hash = ?",
+		tokenHash,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	return t, err
+}
+
+func (r *MySQLRepo) Delete(ctx context.Context, userID, id string) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "api_tokens", "Delete")
+	defer done()
+	res, err := r.DB.ExecContext(ctx, "DELETE FROM api_tokens WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (r *MySQLRepo) Touch(ctx context.Context, id string, at time.Time) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "api_tokens", "Touch")
+	defer done()
+	_, err := r.DB.ExecContext(ctx, "UPDATE api_tokens SET last_used_at = ? WHERE id = ?", at.UTC(), id)
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanToken(row scanner) (*Token, error) {
+	var t Token
+	var scopes string
+	var lastUsed, expires sql.NullTime
+	if err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.TokenHash, &scopes, &t.CreatedAt, &lastUsed, &expires); err != nil {
+		return nil, err
+	}
+
+	t.Scopes = strings.Fields(scopes)
+	if lastUsed.Valid {
+		t.LastUsedAt = &lastUsed.Time
+	}
+	if expires.Valid {
+		t.ExpiresAt = &expires.Time
+	}
+	return &t, nil
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
@@ -0,0 +1,88 @@
+// Package apperr gives domain errors a kind, so that every handler and
+// middleware answers them the same way, and writes the error envelope of the
+// API:
+//
+//	{"code": "post_not_found", "message": "post not found"}
+//
+// code is stable and meant for programs, message is meant for people.
+// Requests with invalid fields also get "errors", one
+// {"location", "param", "value", "msg"} object per field.
+package apperr
+
+import (
+	"errors"
+	"time"
+)
+
+// Kind says how the API answers an error.
+type Kind int
+
+const (
+	Internal Kind = iota
+	Invalid
+	Unauthorized
+	Forbidden
+	NotFound
+	Conflict
+	TooManyRequests
+)
+
+// Coder is implemented by errors that are safe to show to clients.
+type Coder interface {
+	error
+	Kind() Kind
+	Code() string
+}
+
+// Error is a sentinel of a domain package, e.g. user.ErrNotFound. Callers
+// compare with errors.Is, never with the message.
+type Error struct {
+	kind    Kind
+	code    string
+	message string
+}
+
+func New(kind Kind, code, message string) *Error {
+	return &Error{kind: kind, code: code, message: message}
+}
+
+func (e *Error) Error() string { return e.message }
+func (e *Error) Kind() Kind    { return e.kind }
+func (e *Error) Code() string  { return e.code }
+
+// Retrier is implemented by errors telling when to try again.
+type Retrier interface {
+	RetryAt() time.Time
+}
+
+// KindOf returns the kind of the first Coder in the chain of err, Internal
+// when there is none.
+func KindOf(err error) Kind {
+	var c Coder
+	if errors.As(err, &c) {
+		return c.Kind()
+	}
+	return Internal
+}
+
+// FieldError is an invalid field of a request.
+type FieldError struct {
+	Location string `json:"location"`
+	Param    string `json:"param"`
+	Value    string `json:"value"`
+	Msg      string `json:"msg"`
+}
+
+type fieldsError struct {
+	err    error
+	fields []FieldError
+}
+
+func (e *fieldsError) Error() string { return e.err.Error() }
+func (e *fieldsError) Unwrap() error { return e.err }
+
+// WithFields blames err on fields of the request, it is then answered with
+// 422 and the fields listed.
+func WithFields(err error, fields ...FieldError) error {
+	return &fieldsError{err: err, fields: fields}
+}
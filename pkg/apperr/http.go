@@ -0,0 +1,94 @@
+package apperr
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"redditclone/pkg/dbcall"
+)
+
+// Body is the error envelope.
+type Body struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors,omitempty"`
+}
+
+var statuses = map[Kind]int{
+	Internal:        http.StatusInternalServerError,
+	Invalid:         http.StatusBadRequest,
+	Unauthorized:    http.StatusUnauthorized,
+	Forbidden:       http.StatusForbidden,
+	NotFound:        http.StatusNotFound,
+	Conflict:        http.StatusConflict,
+	TooManyRequests: http.StatusTooManyRequests,
+}
+
+// Status returns the status answering err.
+func Status(err error) int {
+	if status, ok := dbcall.Status(err); ok {
+		return status
+	}
+	var f *fieldsError
+	if errors.As(err, &f) {
+		return http.StatusUnprocessableEntity
+	}
+	return statuses[KindOf(err)]
+}
+
+// Write answers status with message, the code is the one of the status, e.g.
+// "bad_request". It is meant for problems found by the transport itself.
+func Write(w http.ResponseWriter, status int, message string) {
+	WriteBody(w, status, Body{Code: statusCode(status), Message: message})
+}
+
+func WriteBody(w http.ResponseWriter, status int, body Body) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// WriteError answers err. Errors of the domain packages keep their message,
+// database calls that timed out or were cancelled get 504 or 503 and anything
+// else a 500 that does not tell the cause. Errors answered with a 5xx are
+// logged with action.
+func WriteError(w http.ResponseWriter, logger *slog.Logger, action string, err error) {
+	status := Status(err)
+
+	var body Body
+	var c Coder
+	switch {
+	case status >= 500 && status != http.StatusInternalServerError:
+		logger.Warn("database unavailable", "action", action, "status", status, "error", err)
+		body = Body{Code: statusCode(status), Message: strings.ToLower(http.StatusText(status))}
+	case status == http.StatusInternalServerError:
+		logger.Error(action, "error", err)
+		body = Body{Code: "internal_error", Message: "internal error"}
+	case errors.As(err, &c):
+		body = Body{Code: c.Code(), Message: err.Error()}
+	default:
+		body = Body{Code: statusCode(status), Message: err.Error()}
+	}
+
+	var f *fieldsError
+	if errors.As(err, &f) {
+		body.Errors = f.fields
+	}
+	var r Retrier
+	if errors.As(err, &r) {
+		retry := int(math.Ceil(time.Until(r.RetryAt()).Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(max(retry, 1)))
+	}
+
+	WriteBody(w, status, body)
+}
+
+func statusCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
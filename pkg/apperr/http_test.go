@@ -0,0 +1,97 @@
+package apperr_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"redditclone/pkg/apperr"
+)
+
+var errMissing = apperr.New(apperr.NotFound, "thing_not_found", "thing not found")
+
+type retryError struct{ at time.Time }
+
+func (e retryError) Error() string      { return "slow down" }
+func (e retryError) Kind() apperr.Kind  { return apperr.TooManyRequests }
+func (e retryError) Code() string       { return "slow_down" }
+func (e retryError) RetryAt() time.Time { return e.at }
+
+func TestWriteError(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	field := apperr.FieldError{Location: "body", Param: "name", Value: "x", Msg: "is invalid"}
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		body   string
+		retry  string
+	}{
+		{
+			name:   "domain error",
+			err:    errMissing,
+			status: http.StatusNotFound,
+			body:   `{"code":"thing_not_found","message":"thing not found"}`,
+		},
+		{
+			name:   "wrapped domain error keeps the message",
+			err:    fmt.Errorf("%w for 42", errMissing),
+			status: http.StatusNotFound,
+			body:   `{"code":"thing_not_found","message":"thing not found for 42"}`,
+		},
+		{
+			name:   "unknown error is hidden",
+			err:    errors.New("dial tcp: connection refused"),
+			status: http.StatusInternalServerError,
+			body:   `{"code":"internal_error","message":"internal error"}`,
+		},
+		{
+			name:   "database timeout",
+			err:    fmt.Errorf("find thing: %w", context.DeadlineExceeded),
+			status: http.StatusGatewayTimeout,
+			body:   `{"code":"gateway_timeout","message":"gateway timeout"}`,
+		},
+		{
+			name:   "fields",
+			err:    apperr.WithFields(apperr.New(apperr.Invalid, "invalid_name", "invalid name"), field),
+			status: http.StatusUnprocessableEntity,
+			body: `{"code":"invalid_name","message":"invalid name",
+				"errors":[{"location":"body","param":"name","value":"x","msg":"is invalid"}]}`,
+		},
+		{
+			name:   "retry after",
+			err:    retryError{at: time.Now().Add(30 * time.Second)},
+			status: http.StatusTooManyRequests,
+			body:   `{"code":"slow_down","message":"slow down"}`,
+			retry:  "30",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			apperr.WriteError(w, logger, "test", tt.err)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			assert.JSONEq(t, tt.body, w.Body.String())
+			assert.Equal(t, tt.retry, w.Header().Get("Retry-After"))
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	w := httptest.NewRecorder()
+	apperr.Write(w, http.StatusMethodNotAllowed, "method not allowed")
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.JSONEq(t, `{"code":"method_not_allowed","message":"method not allowed"}`, w.Body.String())
+}
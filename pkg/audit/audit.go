@@ -0,0 +1,28 @@
+package audit
+
+import (
+	"log/slog"
+)
+
+// Logger records security relevant events (lockouts, password changes, ...)
+// separately from regular request logs.
+type Logger interface {
+	Record(event string, attrs ...any)
+}
+
+type SlogLogger struct {
+	Logger *slog.Logger
+}
+
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	return &SlogLogger{Logger: logger.With("audit", true)}
+}
+
+func (l *SlogLogger) Record(event string, attrs ...any) {
+	l.Logger.Warn(event, attrs...)
+}
+
+// Nop drops every event, useful in tests and tools.
+type Nop struct{}
+
+func (Nop) Record(string, ...any) {}
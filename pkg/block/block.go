@@ -0,0 +1,37 @@
+package block
+
+import (
+	"context"
+	"time"
+
+	"redditclone/pkg/apperr"
+	"redditclone/pkg/user"
+)
+
+const (
+	// KindBlock hides the other user's content and stops them from replying.
+	KindBlock = "block"
+	// KindMute only hides the other user's content from feeds.
+	KindMute = "mute"
+)
+
+var (
+	ErrInvalidKind = apperr.New(apperr.Invalid, "invalid_kind", "invalid kind")
+	ErrSelf        = apperr.New(apperr.Invalid, "self_block", "cannot block or mute yourself")
+	// ErrNotFound is wrapped with the kind, e.g. "mute not found".
+	ErrNotFound = apperr.New(apperr.NotFound, "block_not_found", "not found")
+)
+
+type Entry struct {
+	User    user.User `json:"user"`
+	Kind    string    `json:"kind"`
+	Created time.Time `json:"created"`
+}
+
+type Repository interface {
+	Add(ctx context.Context, blockerID, blockedID, kind string) error
+	Remove(ctx context.Context, blockerID, blockedID, kind string) error
+	List(ctx context.Context, blockerID string) ([]Entry, error)
+	BlockedIDs(ctx context.Context, blockerID string) ([]string, error)
+	IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error)
+}
@@ -0,0 +1,130 @@
+package block
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"redditclone/pkg/dbcall"
+)
+
+type MySQLRepo struct {
+	DB *sql.DB
+}
+
+func NewMySQLRepo(db *sql.DB) *MySQLRepo {
+	return &MySQLRepo{DB: db}
+}
+
+// Add stores the relation, replacing a previous block or mute of the same user.
+func (r *MySQLRepo) Add(ctx context.Context, blockerID, blockedID, kind string) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "user_blocks", "Add")
+	defer done()
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM user_blocks WHERE blocker_id = ? AND blocked_id = ?",
+		blockerID, blockedID,
+	); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO user_blocks (blocker_id, blocked_id, kind, created_at) VALUES (?, ?, ?, ?)",
+		blockerID, blockedID, kind, time.Now().UTC(),
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *MySQLRepo) Remove(ctx context.Context, blockerID, blockedID, kind string) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "user_blocks", "Remove")
+	defer done()
+	res, err := r.DB.ExecContext(ctx,
+		"DELETE FROM user_blocks WHERE blocker_id = ? AND blocked_id = ? AND kind = ?",
+		blockerID, blockedID, kind,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%s %w", kind, ErrNotFound)
+	}
+	return nil
+}
+
+func (r *MySQLRepo) List(ctx context.Context, blockerID string) ([]Entry, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "user_blocks", "List")
+	defer done()
+	rows, err := r.DB.QueryContext(ctx, `
+		SELECT u.id, u.username, b.kind, b.created_at
+		FROM user_blocks b
+		JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = ?
+		ORDER BY b.created_at DESC
+	`, blockerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]Entry, 0)
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.User.ID, &e.User.Username, &e.Kind, &e.Created); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// BlockedIDs returns every user hidden by blockerID, whether blocked or muted.
+func (r *MySQLRepo) BlockedIDs(ctx context.Context, blockerID string) ([]string, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "user_blocks", "BlockedIDs")
+	defer done()
+	rows, err := r.DB.QueryContext(ctx,
+		"SELECT blocked_id FROM user_blocks WHERE blocker_id = ?",
+		blockerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// IsBlocked reports whether blockerID has blocked (not merely muted) blockedID.
+func (r *MySQLRepo) IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "user_blocks", "IsBlocked")
+	defer done()
+	var exists bool
+	err := r.DB.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM user_blocks
+			WHERE blocker_id = ? AND blocked_id = ? AND kind = ?
+		)
+	`, blockerID, blockedID, KindBlock).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,89 @@
+package block_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"redditclone/pkg/block"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	schema := `
+	CREATE TABLE users (
+		id TEXT PRIMARY KEY,
+		username TEXT NOT NULL UNIQUE,
+		password TEXT NOT NULL
+	);
+	CREATE TABLE user_blocks (
+		blocker_id TEXT NOT NULL,
+		blocked_id TEXT NOT NULL,
+		kind TEXT NOT NULL DEFAULT 'block',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (blocker_id, blocked_id)
+	);
+	INSERT INTO users (id, username, password) VALUES
+		('alice', 'alice', 'x'),
+		('bob', 'bob', 'x'),
+		('carol', 'carol', 'x');`
+
+	_, err = db.Exec(schema)
+	assert.NoError(t, err)
+
+	return db
+}
+
+func TestMySQLRepo_AddListRemove(t *testing.T) {
+	ctx := context.Background()
+	repo := block.NewMySQLRepo(setupTestDB(t))
+
+	assert.NoError(t, repo.Add(ctx, "alice", "bob", block.KindBlock))
+	assert.NoError(t, repo.Add(ctx, "alice", "carol", block.KindMute))
+
+	entries, err := repo.List(ctx, "alice")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	ids, err := repo.BlockedIDs(ctx, "alice")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"bob", "carol"}, ids)
+
+	blocked, err := repo.IsBlocked(ctx, "alice", "bob")
+	assert.NoError(t, err)
+	assert.True(t, blocked)
+
+	// a mute hides content but does not stop replies
+	blocked, err = repo.IsBlocked(ctx, "alice", "carol")
+	assert.NoError(t, err)
+	assert.False(t, blocked)
+
+	err = repo.Remove(ctx, "alice", "carol", block.KindBlock)
+	assert.EqualError(t, err, "block not found")
+
+	assert.NoError(t, repo.Remove(ctx, "alice", "carol", block.KindMute))
+
+	ids, err = repo.BlockedIDs(ctx, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bob"}, ids)
+}
+
+func TestMySQLRepo_AddReplacesKind(t *testing.T) {
+	ctx := context.Background()
+	repo := block.NewMySQLRepo(setupTestDB(t))
+
+	assert.NoError(t, repo.Add(ctx, "alice", "bob", block.KindMute))
+	assert.NoError(t, repo.Add(ctx, "alice", "bob", block.KindBlock))
+
+	entries, err := repo.List(ctx, "alice")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, block.KindBlock, entries[0].Kind)
+	assert.Equal(t, "bob", entries[0].User.Username)
+}
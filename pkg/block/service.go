@@ -0,0 +1,51 @@
+package block
+
+import (
+	"context"
+
+	"redditclone/pkg/user"
+)
+
+type ServiceInterface interface {
+	List(ctx context.Context, userID string) ([]Entry, error)
+	Add(ctx context.Context, userID, username, kind string) error
+	Remove(ctx context.Context, userID, username, kind string) error
+}
+
+type Service struct {
+	Repo  Repository
+	Users user.Repository
+}
+
+func NewService(repo Repository, users user.Repository) *Service {
+	return &Service{Repo: repo, Users: users}
+}
+
+func (s *Service) List(ctx context.Context, userID string) ([]Entry, error) {
+	return s.Repo.List(ctx, userID)
+}
+
+func (s *Service) Add(ctx context.Context, userID, username, kind string) error {
+	if kind != KindBlock && kind != KindMute {
+		return ErrInvalidKind
+	}
+
+	target, err := s.Users.FindByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+	if target.ID == userID {
+		return ErrSelf
+	}
+
+	return s.Repo.Add(ctx, userID, target.ID, kind)
+}
+
+func (s *Service) Remove(ctx context.Context, userID, username, kind string) error {
+	target, err := s.Users.FindByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	return s.Repo.Remove(ctx, userID, target.ID, kind)
+}
@@ -1,6 +1,6 @@
 package claims
 
-import jwt "github.com/dgrijalva/jwt-go"
+import "time"
 
 type contextKey string
 
@@ -8,10 +8,32 @@ const (
 	TokenContextKey contextKey = "token"
 )
 
+// Claims is who a request was authenticated as, see token.Verifier.
 type Claims struct {
 	User struct {
 		Username string `json:"username"`
 		ID       string `json:"id"`
 	} `json:"user"`
-	jwt.StandardClaims
+	// SessionID is the login session a session token belongs to, the token
+	// stops working with it. Empty for personal tokens.
+	SessionID string `json:"sid,omitempty"`
+	// APIToken is the ID of the personal token the request came with, its
+	// Scopes limit what the request may do. Empty for session tokens.
+	APIToken string   `json:"-"`
+	Scopes   []string `json:"-"`
+	// ExpiresAt is when the session token runs out.
+	ExpiresAt time.Time `json:"-"`
+}
+
+// Allows reports whether the request may use a route needing scope.
+func (c *Claims) Allows(scope string) bool {
+	if c.APIToken == "" {
+		return true
+	}
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
 }
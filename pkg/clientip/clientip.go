@@ -0,0 +1,17 @@
+// Package clientip tells who sent a request, for the handlers and middlewares
+// that count or log per client.
+package clientip
+
+import (
+	"net"
+	"net/http"
+)
+
+// From returns the address of the peer that sent the request.
+func From(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,122 @@
+// Package dbcall wraps every repository call: it bounds the call with a
+// deadline, opens its span and times it for the db latency histogram.
+package dbcall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"redditclone/pkg/metrics"
+	"redditclone/pkg/tracing"
+)
+
+const DefaultTimeout = 3 * time.Second
+
+type Config struct {
+	// Default bounds every call, 0 leaves calls without a deadline.
+	Default time.Duration
+	// Ops overrides Default for single calls, keyed "repo.Method" such as
+	// "posts.GetAll".
+	Ops map[string]time.Duration
+}
+
+// ConfigFrom reads DB_TIMEOUT and DB_TIMEOUTS, a comma separated list of
+// repo.Method=duration overrides, through getenv.
+func ConfigFrom(getenv func(string) string) (Config, error) {
+	cfg := Config{Default: DefaultTimeout, Ops: make(map[string]time.Duration)}
+	var errs []error
+
+	if v := getenv("DB_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("bad DB_TIMEOUT %q", v))
+		} else {
+			cfg.Default = d
+		}
+	}
+	for _, item := range strings.Split(getenv("DB_TIMEOUTS"), ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		op, v, ok := strings.Cut(item, "=")
+		d, err := time.ParseDuration(v)
+		if !ok || !strings.Contains(op, ".") || err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("bad DB_TIMEOUTS entry %q", item))
+			continue
+		}
+		cfg.Ops[op] = d
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// Timeout returns the deadline of repo.method.
+func (c Config) Timeout(repo, method string) time.Duration {
+	if d, ok := c.Ops[repo+"."+method]; ok {
+		return d
+	}
+	return c.Default
+}
+
+var config atomic.Pointer[Config]
+
+// Configure sets the deadlines of all later calls. Until it is called calls
+// only end with their context.
+func Configure(cfg Config) {
+	config.Store(&cfg)
+}
+
+// Start opens a repository call, use as
+//
+//	ctx, done := dbcall.Start(ctx, "mysql", "users", "Create")
+//	defer done()
+//
+// done ends the span, releases the deadline and records the latency.
+func Start(ctx context.Context, store, repo, method string) (context.Context, func()) {
+	cancel := context.CancelFunc(func() {})
+	if cfg := config.Load(); cfg != nil {
+		if d := cfg.Timeout(repo, method); d > 0 {
+			ctx, cancel = context.WithTimeout(ctx, d)
+		}
+	}
+
+	ctx, span := tracing.Start(ctx, repo+"."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", store),
+			attribute.String("db.collection.name", repo),
+			attribute.String("db.operation.name", method),
+		),
+	)
+	observe := metrics.ObserveDB(store, repo, method)
+
+	return ctx, func() {
+		observe()
+		if err := ctx.Err(); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		cancel()
+	}
+}
+
+// Status maps the error of a call that ran out of time to 504 and the one of a
+// call whose request went away, a client leaving or the server shutting down,
+// to 503. ok is false for errors that come from the data.
+func Status(err error) (status int, ok bool) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, true
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable, true
+	}
+	return 0, false
+}
@@ -0,0 +1,69 @@
+package dbcall_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"redditclone/pkg/dbcall"
+)
+
+func TestConfigFrom(t *testing.T) {
+	cfg, err := dbcall.ConfigFrom(os.Getenv)
+	assert.NoError(t, err)
+	assert.Equal(t, dbcall.DefaultTimeout, cfg.Timeout("posts", "GetAll"))
+
+	t.Setenv("DB_TIMEOUT", "2s")
+	t.Setenv("DB_TIMEOUTS", "posts.GetAll=5s, sessions.DeleteExpired=30s")
+	cfg, err = dbcall.ConfigFrom(os.Getenv)
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, cfg.Timeout("posts", "GetAll"))
+	assert.Equal(t, 30*time.Second, cfg.Timeout("sessions", "DeleteExpired"))
+	assert.Equal(t, 2*time.Second, cfg.Timeout("users", "Create"))
+
+	t.Setenv("DB_TIMEOUTS", "GetAll=5s")
+	_, err = dbcall.ConfigFrom(os.Getenv)
+	assert.EqualError(t, err, `bad DB_TIMEOUTS entry "GetAll=5s"`)
+
+	t.Setenv("DB_TIMEOUT", "soon")
+	_, err = dbcall.ConfigFrom(os.Getenv)
+	assert.EqualError(t, err, "bad DB_TIMEOUT \"soon\"\nbad DB_TIMEOUTS entry \"GetAll=5s\"")
+}
+
+func TestStart(t *testing.T) {
+	dbcall.Configure(dbcall.Config{
+		Default: time.Minute,
+		Ops:     map[string]time.Duration{"posts.GetAll": time.Millisecond},
+	})
+	t.Cleanup(func() { dbcall.Configure(dbcall.Config{}) })
+
+	ctx, done := dbcall.Start(context.Background(), "mongo", "posts", "GetAll")
+	<-ctx.Done()
+	done()
+	assert.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+
+	ctx, done = dbcall.Start(context.Background(), "mongo", "posts", "GetByID")
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+	done()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+}
+
+func TestStatus(t *testing.T) {
+	status, ok := dbcall.Status(fmt.Errorf("find: %w", context.DeadlineExceeded))
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusGatewayTimeout, status)
+
+	status, ok = dbcall.Status(context.Canceled)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+
+	_, ok = dbcall.Status(errors.New("post not found"))
+	assert.False(t, ok)
+}
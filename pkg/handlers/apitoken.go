@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"redditclone/pkg/apitoken"
+	"redditclone/pkg/apperr"
+	"redditclone/pkg/claims"
+	"redditclone/pkg/logctx"
+)
+
+const muxVarTokenID string = "token_id"
+
+type APITokenForm struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// ExpiresInDays of 0 makes a token that does not expire.
+	ExpiresInDays int `json:"expires_in_days"`
+}
+
+type APITokenHandler struct {
+	Service apitoken.ServiceInterface
+	Logger  *slog.Logger
+}
+
+func NewAPITokenHandler(service apitoken.ServiceInterface, logger *slog.Logger) *APITokenHandler {
+	return &APITokenHandler{
+		Service: service,
+		Logger:  logger,
+	}
+}
+
+func (h *APITokenHandler) List(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	var claims claims.Claims
+	if ok := h.sessionClaims(w, r, &claims); !ok {
+		return
+	}
+
+	tokens, err := h.Service.List(r.Context(), claims.User.ID)
+	if err != nil {
+		apperr.WriteError(w, logger, "list api tokens", err)
+		return
+	}
+
+	writeJSON(w, logger, tokens)
+}
+
+func (h *APITokenHandler) Create(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	var claims claims.Claims
+	if ok := h.sessionClaims(w, r, &claims); !ok {
+		return
+	}
+
+	var req APITokenForm
+	if ok := DecodeJSONBody(w, r, &req); !ok {
+		return
+	}
+
+	ttl := time.Duration(req.ExpiresInDays) * 24 * time.Hour
+	secret, token, err := h.Service.Create(r.Context(), claims.User.ID, req.Name, req.Scopes, ttl)
+	if err != nil {
+		apperr.WriteError(w, logger, "create api token", tokenFields(err, req))
+		return
+	}
+
+	if ok := WriteResp(w, logger, map[string]any{
+		"token":   secret,
+		"details": token,
+	}, http.StatusCreated); ok {
+		logger.Info("api token created", "user", claims.User.ID, "token", token.ID)
+	}
+}
+
+// tokenFields blames validation errors on the field of the form.
+func tokenFields(err error, req APITokenForm) error {
+	field := FieldError{Location: "body", Msg: "is invalid"}
+	switch {
+	case errors.Is(err, apitoken.ErrInvalidName):
+		field.Param, field.Value = "name", req.Name
+	case errors.Is(err, apitoken.ErrInvalidScope):
+		field.Param, field.Value = "scopes", strings.Join(req.Scopes, ",")
+	case errors.Is(err, apitoken.ErrInvalidExpiry):
+		field.Param, field.Value = "expires_in_days", strconv.Itoa(req.ExpiresInDays)
+	default:
+		return err
+	}
+	return apperr.WithFields(err, field)
+}
+
+func (h *APITokenHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	var claims claims.Claims
+	if ok := h.sessionClaims(w, r, &claims); !ok {
+		return
+	}
+
+	id := mux.Vars(r)[muxVarTokenID]
+	if err := h.Service.Revoke(r.Context(), claims.User.ID, id); err != nil {
+		apperr.WriteError(w, logger, "revoke api token", err)
+		return
+	}
+
+	if ok := writeJSON(w, logger, map[string]string{"message": "success"}); ok {
+		logger.Info("api token revoked", "user", claims.User.ID, "token", id)
+	}
+}
+
+// sessionClaims keeps token management to logged in users, a leaked personal
+// token must not be able to mint or list others.
+func (h *APITokenHandler) sessionClaims(w http.ResponseWriter, r *http.Request, c *claims.Claims) bool {
+	if ok := getClaimsFromContext(w, r, c); !ok {
+		return false
+	}
+	if c.APIToken != "" {
+		apperr.WriteBody(w, http.StatusForbidden, apperr.Body{Code: "insufficient_scope", Message: "insufficient scope"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"redditclone/pkg/apperr"
+	"redditclone/pkg/block"
+	"redditclone/pkg/claims"
+	"redditclone/pkg/logctx"
+)
+
+type BlockHandler struct {
+	Service block.ServiceInterface
+	Logger  *slog.Logger
+}
+
+func NewBlockHandler(service block.ServiceInterface, logger *slog.Logger) *BlockHandler {
+	return &BlockHandler{
+		Service: service,
+		Logger:  logger,
+	}
+}
+
+func (h *BlockHandler) List(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	var claims claims.Claims
+	if ok := getClaimsFromContext(w, r, &claims); !ok {
+		return
+	}
+
+	entries, err := h.Service.List(r.Context(), claims.User.ID)
+	if err != nil {
+		apperr.WriteError(w, logger, "list blocks", err)
+		return
+	}
+
+	writeJSON(w, logger, entries)
+}
+
+func (h *BlockHandler) Block(w http.ResponseWriter, r *http.Request) {
+	h.add(w, r, block.KindBlock)
+}
+
+func (h *BlockHandler) Unblock(w http.ResponseWriter, r *http.Request) {
+	h.remove(w, r, block.KindBlock)
+}
+
+func (h *BlockHandler) Mute(w http.ResponseWriter, r *http.Request) {
+	h.add(w, r, block.KindMute)
+}
+
+func (h *BlockHandler) Unmute(w http.ResponseWriter, r *http.Request) {
+	h.remove(w, r, block.KindMute)
+}
+
+func (h *BlockHandler) add(w http.ResponseWriter, r *http.Request, kind string) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	login, ok := mux.Vars(r)[muxVarLogin]
+	if !ok {
+		apperr.Write(w, http.StatusBadRequest, "invalid user login")
+		return
+	}
+
+	var claims claims.Claims
+	if ok := getClaimsFromContext(w, r, &claims); !ok {
+		return
+	}
+
+	if err := h.Service.Add(r.Context(), claims.User.ID, login, kind); err != nil {
+		apperr.WriteError(w, logger, "block", err)
+		return
+	}
+
+	if ok := writeJSON(w, logger, map[string]string{"message": "success"}); ok {
+		logger.Info(kind, "user", claims.User.ID, muxVarLogin, login)
+	}
+}
+
+func (h *BlockHandler) remove(w http.ResponseWriter, r *http.Request, kind string) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	login, ok := mux.Vars(r)[muxVarLogin]
+	if !ok {
+		apperr.Write(w, http.StatusBadRequest, "invalid user login")
+		return
+	}
+
+	var claims claims.Claims
+	if ok := getClaimsFromContext(w, r, &claims); !ok {
+		return
+	}
+
+	if err := h.Service.Remove(r.Context(), claims.User.ID, login, kind); err != nil {
+		apperr.WriteError(w, logger, "unblock", err)
+		return
+	}
+
+	if ok := writeJSON(w, logger, map[string]string{"message": "success"}); ok {
+		logger.Info("un"+kind, "user", claims.User.ID, muxVarLogin, login)
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"time"
+
+	"redditclone/pkg/post"
+	"redditclone/pkg/user"
+)
+
+// The bodies of /api, which is also served as /api/v1. Their shape is the one
+// the Asperitas frontend reads and must not change, new shapes go to v2.
+
+type PostForm struct {
+	Type     string  `json:"type"`
+	Title    string  `json:"title"`
+	Category string  `json:"category"`
+	Text     string  `json:"text,omitempty"`
+	URL      *string `json:"url,omitempty"`
+}
+
+type Author struct {
+	Username string `json:"username"`
+	ID       string `json:"id"`
+}
+
+type Vote struct {
+	User string `json:"user"`
+	Vote int8   `json:"vote"`
+}
+
+type Comment struct {
+	Created time.Time `json:"created"`
+	Author  Author    `json:"author"`
+	Body    string    `json:"body"`
+	ID      string    `json:"id"`
+}
+
+type Post struct {
+	Score            int       `json:"score"`
+	Views            int       `json:"views"`
+	Type             string    `json:"type"`
+	Title            string    `json:"title"`
+	Author           Author    `json:"author"`
+	Category         string    `json:"category"`
+	Text             string    `json:"text,omitempty"`
+	Votes            []Vote    `json:"votes"`
+	Comments         []Comment `json:"comments"`
+	Created          time.Time `json:"created"`
+	UpvotePercentage int       `json:"upvotePercentage"`
+	ID               string    `json:"id"`
+	URL              *string   `json:"url,omitempty"`
+}
+
+func (f PostForm) post() *post.Post {
+	return &post.Post{Type: f.Type, Title: f.Title, Category: f.Category, Text: f.Text, URL: f.URL}
+}
+
+func newAuthor(u user.User) Author {
+	return Author{Username: u.Username, ID: u.ID}
+}
+
+func newPost(p *post.Post) Post {
+	dto := Post{
+		Score:            p.Score,
+		Views:            p.Views,
+		Type:             p.Type,
+		Title:            p.Title,
+		Author:           newAuthor(p.Author),
+		Category:         p.Category,
+		Text:             p.Text,
+		Created:          p.Created,
+		UpvotePercentage: p.UpvotePercentage,
+		ID:               p.ID,
+		URL:              p.URL,
+	}
+	// nil stays null, as it always was
+	if p.Votes != nil {
+		dto.Votes = make([]Vote, len(p.Votes))
+		for i, v := range p.Votes {
+			dto.Votes[i] = Vote{User: v.User, Vote: v.Vote}
+		}
+	}
+	if p.Comments != nil {
+		dto.Comments = make([]Comment, len(p.Comments))
+		for i, c := range p.Comments {
+			dto.Comments[i] = Comment{Created: c.Created, Author: newAuthor(c.Author), Body: c.Body, ID: c.ID}
+		}
+	}
+	return dto
+}
+
+func newPosts(posts []*post.Post) []Post {
+	if posts == nil {
+		return nil
+	}
+	dtos := make([]Post, len(posts))
+	for i, p := range posts {
+		dtos[i] = newPost(p)
+	}
+	return dtos
+}
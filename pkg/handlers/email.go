@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"redditclone/pkg/apperr"
+	"redditclone/pkg/claims"
+	"redditclone/pkg/logctx"
+	"redditclone/pkg/user"
+)
+
+type EmailForm struct {
+	Email string `json:"email"`
+}
+
+type VerifyForm struct {
+	Token string `json:"token"`
+}
+
+type EmailHandler struct {
+	Service user.EmailServiceInterface
+	Logger  *slog.Logger
+}
+
+func NewEmailHandler(service user.EmailServiceInterface, logger *slog.Logger) *EmailHandler {
+	return &EmailHandler{
+		Service: service,
+		Logger:  logger,
+	}
+}
+
+func (h *EmailHandler) SetEmail(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	var claims claims.Claims
+	if ok := getClaimsFromContext(w, r, &claims); !ok {
+		return
+	}
+
+	var req EmailForm
+	if ok := DecodeJSONBody(w, r, &req); !ok {
+		return
+	}
+
+	if err := h.Service.SetEmail(r.Context(), claims.User.ID, req.Email); err != nil {
+		apperr.WriteError(w, logger, "set email", emailFields(err, req.Email))
+		return
+	}
+
+	if ok := WriteResp(w, logger, map[string]any{"message": "verification sent"}, http.StatusOK); ok {
+		logger.Info("set email", "user", claims.User.ID)
+	}
+}
+
+func (h *EmailHandler) Resend(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	var claims claims.Claims
+	if ok := getClaimsFromContext(w, r, &claims); !ok {
+		return
+	}
+
+	if err := h.Service.Resend(r.Context(), claims.User.ID); err != nil {
+		apperr.WriteError(w, logger, "resend verification", err)
+		return
+	}
+
+	WriteResp(w, logger, map[string]any{"message": "verification sent"}, http.StatusOK)
+}
+
+func (h *EmailHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	var req VerifyForm
+	if ok := DecodeJSONBody(w, r, &req); !ok {
+		return
+	}
+
+	if err := h.Service.Verify(r.Context(), req.Token); err != nil {
+		apperr.WriteError(w, logger, "verify email", err)
+		return
+	}
+
+	WriteResp(w, logger, map[string]any{"message": "success"}, http.StatusOK)
+}
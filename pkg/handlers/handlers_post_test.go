@@ -11,11 +11,13 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"redditclone/pkg/claims"
 	"redditclone/pkg/handlers"
 	"redditclone/pkg/post"
 	"redditclone/pkg/post/mocks"
+	"redditclone/pkg/user"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
@@ -95,12 +97,13 @@ func TestCreatePost(t *testing.T) {
 		r := SetDefaultUserClaims(httptest.NewRequest(http.MethodPost, "/api/posts", bytes.NewReader(body)))
 		w := httptest.NewRecorder()
 
-		mockPostService.On("CreatePost", mock.AnythingOfType("*post.Post"), "testuser", "user123").
+		mockPostService.On("CreatePost", mock.Anything, mock.AnythingOfType("*post.Post"), "testuser", "user123").
 			Return(errors.New("some_error"))
 
 		handler.CreatePost(w, r)
 
-		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.JSONEq(t, `{"code":"internal_error","message":"internal error"}`, w.Body.String())
 		mockPostService.AssertExpectations(t)
 	})
 
@@ -113,7 +116,7 @@ func TestCreatePost(t *testing.T) {
 		r := SetDefaultUserClaims(httptest.NewRequest(http.MethodPost, "/api/posts", bytes.NewReader(body)))
 		w := httptest.NewRecorder()
 
-		mockPostService.On("CreatePost", mock.AnythingOfType("*post.Post"), "testuser", "user123").
+		mockPostService.On("CreatePost", mock.Anything, mock.AnythingOfType("*post.Post"), "testuser", "user123").
 			Return(nil)
 
 		handler.CreatePost(w, r)
@@ -142,8 +145,8 @@ func TestGetPostByID(t *testing.T) {
 		r = mux.SetURLVars(r, map[string]string{"post_id": NicePostID})
 		w := httptest.NewRecorder()
 
-		mockPostService.On("GetByID", NicePostID).
-			Return(nil, errors.New("not found"))
+		mockPostService.On("GetByID", mock.Anything, NicePostID, "").
+			Return(nil, post.ErrNotFound)
 
 		handler.GetPostByID(w, r)
 
@@ -152,6 +155,23 @@ func TestGetPostByID(t *testing.T) {
 		mockPostService.AssertExpectations(t)
 	})
 
+	t.Run("database timeout", func(t *testing.T) {
+		defer resetMock(mockPostService)
+
+		r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/post/%s", NicePostID), nil)
+		r = mux.SetURLVars(r, map[string]string{"post_id": NicePostID})
+		w := httptest.NewRecorder()
+
+		mockPostService.On("GetByID", mock.Anything, NicePostID, "").
+			Return(nil, fmt.Errorf("failed to increment views and fetch post: %w", context.DeadlineExceeded))
+
+		handler.GetPostByID(w, r)
+
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Contains(t, w.Body.String(), "gateway timeout")
+		mockPostService.AssertExpectations(t)
+	})
+
 	t.Run("success", func(t *testing.T) {
 		defer resetMock(mockPostService)
 
@@ -161,7 +181,7 @@ func TestGetPostByID(t *testing.T) {
 		r = mux.SetURLVars(r, map[string]string{"post_id": NicePostID})
 		w := httptest.NewRecorder()
 
-		mockPostService.On("GetByID", NicePostID).
+		mockPostService.On("GetByID", mock.Anything, NicePostID, "").
 			Return(expected, nil)
 
 		handler.GetPostByID(w, r)
@@ -219,7 +239,7 @@ func TestAddComment(t *testing.T) {
 		w := httptest.NewRecorder()
 
 		expected := &post.Post{ID: NicePostID}
-		mockPostService.On("AddComment", NicePostID, "test comment", defaultClaims).
+		mockPostService.On("AddComment", mock.Anything, NicePostID, "test comment", defaultClaims).
 			Return(expected, nil)
 
 		handler.AddComment(w, r)
@@ -239,13 +259,13 @@ func TestAddComment(t *testing.T) {
 		r = SetDefaultUserClaims(mux.SetURLVars(r, defaultID))
 		w := httptest.NewRecorder()
 
-		mockPostService.On("AddComment", NicePostID, "test comment", defaultClaims).
-			Return(nil, errors.New("something went wrong"))
+		mockPostService.On("AddComment", mock.Anything, NicePostID, "test comment", defaultClaims).
+			Return(nil, post.ErrBlocked)
 
 		handler.AddComment(w, r)
 
-		assert.Equal(t, http.StatusBadRequest, w.Code)
-		assert.Contains(t, w.Body.String(), "something went wrong")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.JSONEq(t, `{"code":"blocked_by_author","message":"blocked by author"}`, w.Body.String())
 		mockPostService.AssertExpectations(t)
 	})
 }
@@ -282,8 +302,8 @@ func TestRemoveComment(t *testing.T) {
 		})
 		w := httptest.NewRecorder()
 
-		mockPostService.On("RemoveComment", NicePostID, NicePostID).
-			Return(nil, errors.New("not found"))
+		mockPostService.On("RemoveComment", mock.Anything, NicePostID, NicePostID).
+			Return(nil, post.ErrNotFound)
 
 		handler.RemoveComment(w, r)
 
@@ -304,7 +324,7 @@ func TestRemoveComment(t *testing.T) {
 		})
 		w := httptest.NewRecorder()
 
-		mockPostService.On("RemoveComment", NicePostID, NicePostID).
+		mockPostService.On("RemoveComment", mock.Anything, NicePostID, NicePostID).
 			Return(expected, nil)
 
 		handler.RemoveComment(w, r)
@@ -333,8 +353,8 @@ func TestDeletePost(t *testing.T) {
 		r = mux.SetURLVars(r, map[string]string{"post_id": NicePostID})
 		w := httptest.NewRecorder()
 
-		mockPostService.On("Delete", NicePostID).
-			Return(errors.New("post not found"))
+		mockPostService.On("Delete", mock.Anything, NicePostID).
+			Return(post.ErrNotFound)
 
 		handler.DeletePost(w, r)
 
@@ -350,7 +370,7 @@ func TestDeletePost(t *testing.T) {
 		r = mux.SetURLVars(r, map[string]string{"post_id": NicePostID})
 		w := httptest.NewRecorder()
 
-		mockPostService.On("Delete", NicePostID).
+		mockPostService.On("Delete", mock.Anything, NicePostID).
 			Return(nil)
 
 		handler.DeletePost(w, r)
@@ -407,13 +427,13 @@ func TestAddVote(t *testing.T) {
 		}))
 		w := httptest.NewRecorder()
 
-		mockPostService.On("AddVote", "123", "user123", "down").
-			Return(nil, errors.New("vote failed"))
+		mockPostService.On("AddVote", mock.Anything, "123", "user123", "down").
+			Return(nil, post.ErrInvalidAction)
 
 		handler.AddVote(w, r)
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
-		assert.Contains(t, w.Body.String(), "vote failed")
+		assert.Contains(t, w.Body.String(), `"code":"invalid_action"`)
 		mockPostService.AssertExpectations(t)
 	})
 
@@ -429,7 +449,7 @@ func TestAddVote(t *testing.T) {
 		}))
 		w := httptest.NewRecorder()
 
-		mockPostService.On("AddVote", "123", "user123", "up").
+		mockPostService.On("AddVote", mock.Anything, "123", "user123", "up").
 			Return(expected, nil)
 
 		handler.AddVote(w, r)
@@ -465,8 +485,8 @@ func TestGetPostsByUser(t *testing.T) {
 		r = mux.SetURLVars(r, map[string]string{"login": "tester"})
 		w := httptest.NewRecorder()
 
-		mockPostService.On("GetByUser", "tester").
-			Return(expectedPosts)
+		mockPostService.On("GetByUser", mock.Anything, "tester", "").
+			Return(expectedPosts, nil)
 
 		handler.GetPostsByUser(w, r)
 
@@ -502,8 +522,8 @@ func TestGetPostsByCategory(t *testing.T) {
 			{ID: "2", Text: "tech post 2"},
 		}
 
-		mockPostService.On("GetByCategory", "music").
-			Return(expectedPosts)
+		mockPostService.On("GetByCategory", mock.Anything, "music", "").
+			Return(expectedPosts, nil)
 
 		handler.GetPostsByCategory(w, r)
 
@@ -513,3 +533,30 @@ func TestGetPostsByCategory(t *testing.T) {
 		mockPostService.AssertExpectations(t)
 	})
 }
+
+// The v1 bodies are built from DTOs now, they must still encode like post.Post.
+func TestPostBodyKeepsItsShape(t *testing.T) {
+	defer resetMock(mockPostService)
+
+	url := "https://go.dev"
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	p := &post.Post{
+		ID: NicePostID, Score: 2, Views: 7, Type: "link", Title: "Go", Category: "programming",
+		Author:           user.User{Username: "testuser", ID: "user123"},
+		Votes:            []post.Voting{{User: "user123", Vote: 1}, {User: "user456", Vote: 1}},
+		Comments:         []post.Comment{{ID: "c1", Body: "nice", Created: created, Author: user.User{Username: "bob", ID: "user456"}}},
+		Created:          created,
+		UpvotePercentage: 100,
+		URL:              &url,
+	}
+	want, err := json.Marshal(p)
+	assert.NoError(t, err)
+
+	mockPostService.On("GetByID", mock.Anything, NicePostID, "").Return(p, nil)
+	r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/post/"+NicePostID, nil), defaultID)
+	w := httptest.NewRecorder()
+	handler.GetPostByID(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, string(want), w.Body.String())
+}
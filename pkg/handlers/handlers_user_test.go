@@ -1,6 +1,7 @@
 package handlers_test
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 	"net/http"
@@ -8,6 +9,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -15,17 +17,28 @@ import (
 	"redditclone/pkg/user"
 )
 
+type fakeIssuer struct {
+	err error
+}
+
+func (f fakeIssuer) Issue(username, userID, sessionID string) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	return "token-" + userID, nil
+}
+
 type mockService struct {
 	mock.Mock
 }
 
-func (m *mockService) Register(username, password string) (*user.User, error) {
-	args := m.Called(username, password)
+func (m *mockService) Register(ctx context.Context, username, password, email string) (*user.User, error) {
+	args := m.Called(username, password, email)
 	return args.Get(0).(*user.User), args.Error(1)
 }
 
-func (m *mockService) Login(username, password string) (*user.User, error) {
-	args := m.Called(username, password)
+func (m *mockService) Login(ctx context.Context, username, password, ip string) (*user.User, error) {
+	args := m.Called(username, password, ip)
 	return args.Get(0).(*user.User), args.Error(1)
 }
 
@@ -33,11 +46,14 @@ func TestLoginHandler(t *testing.T) {
 	m := new(mockService)
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{}))
 
-	m.On("Login", "validuser", "correct").Return(&user.User{ID: "id", Username: "validuser"}, nil)
-	m.On("Login", "wronguser", "correct").Return((*user.User)(nil), errors.New("user not found"))
-	m.On("Login", "validuser", "wrong").Return((*user.User)(nil), errors.New("invalid credentials"))
+	m.On("Login", "validuser", "correct", "192.0.2.1").Return(&user.User{ID: "id", Username: "validuser"}, nil)
+	m.On("Login", "wronguser", "correct", "192.0.2.1").Return((*user.User)(nil), user.ErrInvalidCredentials)
+	m.On("Login", "validuser", "wrong", "192.0.2.1").Return((*user.User)(nil), user.ErrInvalidCredentials)
+	m.On("Login", "lockeduser", "correct", "192.0.2.1").
+		Return((*user.User)(nil), &user.ThrottledError{Until: time.Now().Add(90 * time.Second)})
+	m.On("Login", "brokendb", "correct", "192.0.2.1").Return((*user.User)(nil), errors.New("connection refused"))
 
-	handler := handlers.NewUserHandler(m, logger)
+	handler := handlers.NewUserHandler(m, fakeIssuer{}, logger)
 
 	tests := []struct {
 		name           string
@@ -54,25 +70,37 @@ func TestLoginHandler(t *testing.T) {
 			name:           "User not found",
 			body:           `{"username":"wronguser","password":"correct"}`,
 			expectedStatus: http.StatusUnauthorized,
-			expectedError:  "user not found",
+			expectedError:  `{"code":"invalid_credentials","message":"invalid credentials"}`,
 		},
 		{
 			name:           "Invalid credentials",
 			body:           `{"username":"validuser","password":"wrong"}`,
 			expectedStatus: http.StatusUnauthorized,
-			expectedError:  "invalid password",
+			expectedError:  `{"code":"invalid_credentials","message":"invalid credentials"}`,
+		},
+		{
+			name:           "Locked out",
+			body:           `{"username":"lockeduser","password":"correct"}`,
+			expectedStatus: http.StatusTooManyRequests,
+			expectedError:  "too many failed attempts",
+		},
+		{
+			name:           "Internal error",
+			body:           `{"username":"brokendb","password":"correct"}`,
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  `{"code":"internal_error","message":"internal error"}`,
 		},
 		{
 			name:           "Bad Content-Type",
 			body:           `{"username":"validuser","password":"wrong"}`,
 			expectedStatus: http.StatusBadRequest,
-			expectedError:  `{"error":"invalid Content-Type"}`,
+			expectedError:  `{"code":"bad_request","message":"invalid Content-Type"}`,
 		},
 		{
 			name:           "Bad JSON",
 			body:           `{"username" oops "validuser","password":"wrong"}`,
 			expectedStatus: http.StatusBadRequest,
-			expectedError:  `{"error":"bad json"}`,
+			expectedError:  `{"code":"bad_request","message":"bad json"}`,
 		},
 	}
 
@@ -90,6 +118,9 @@ func TestLoginHandler(t *testing.T) {
 			handler.Login(rr, req)
 
 			assert.Equal(t, test.expectedStatus, rr.Code)
+			if test.expectedStatus == http.StatusTooManyRequests {
+				assert.Equal(t, "90", rr.Header().Get("Retry-After"))
+			}
 
 			if test.expectedError != "" {
 				assert.Contains(t, rr.Body.String(), test.expectedError)
@@ -104,11 +135,13 @@ func TestRegister(t *testing.T) {
 	m := new(mockService)
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{}))
 
-	m.On("Register", "validuser", "correct").Return(&user.User{ID: "id", Username: "validuser"}, nil)
-	m.On("Register", "existinguser", "password").Return((*user.User)(nil), errors.New("user already exists"))
-	m.On("Register", "wronguser", "password").Return((*user.User)(nil), errors.New("unexpected error"))
+	m.On("Register", "validuser", "correct", "").Return(&user.User{ID: "id", Username: "validuser"}, nil)
+	m.On("Register", "existinguser", "password", "").Return((*user.User)(nil), user.ErrExists)
+	m.On("Register", "wronguser", "password", "").Return((*user.User)(nil), errors.New("unexpected error"))
+	m.On("Register", "mailuser", "password", "taken@example.com").Return((*user.User)(nil), user.ErrEmailInUse)
+	m.On("Register", "shortuser", "short", "").Return((*user.User)(nil), user.ErrPasswordTooShort)
 
-	handler := handlers.NewUserHandler(m, logger)
+	handler := handlers.NewUserHandler(m, fakeIssuer{}, logger)
 
 	tests := []struct {
 		name           string
@@ -125,13 +158,25 @@ func TestRegister(t *testing.T) {
 			name:           "User already exists",
 			body:           `{"username":"existinguser","password":"password"}`,
 			expectedStatus: http.StatusUnprocessableEntity,
-			expectedError:  "already exists",
+			expectedError:  `"errors":[{"location":"body","param":"username","value":"existinguser","msg":"already exists"}]`,
+		},
+		{
+			name:           "Email taken",
+			body:           `{"username":"mailuser","password":"password","email":"taken@example.com"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  `"param":"email"`,
+		},
+		{
+			name:           "Password too short",
+			body:           `{"username":"shortuser","password":"short"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  `"param":"password","value":"","msg":"must be at least 8 characters long"`,
 		},
 		{
 			name:           "Unexpected error",
 			body:           `{"username":"wronguser","password":"password"}`,
 			expectedStatus: http.StatusInternalServerError,
-			expectedError:  "unexpected error",
+			expectedError:  `"message":"internal error"`,
 		},
 		{
 			name:           "Bad Content-Type",
@@ -171,25 +216,14 @@ func TestRegister(t *testing.T) {
 	m.AssertExpectations(t)
 }
 
-/* не работает
 func TestLoginGenerateToken(t *testing.T) {
-
-	//original := os.Getenv("JWT_SECRET")
-	os.Unsetenv("JWT_SECRET")
-
 	m := new(mockService)
-	m.On("Login", "validuser", "correct").Return(&user.User{ID: "id", Username: "validuser"}, nil)
+	m.On("Login", "validuser", "correct", "192.0.2.1").Return(&user.User{ID: "id", Username: "validuser"}, nil)
 
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{}))
-	handler := &handlers.Handler{
-		Service: m,
-		Logger:  logger,
-	}
-
+	handler := handlers.NewUserHandler(m, fakeIssuer{err: errors.New("token signing failed")}, logger)
 
 	t.Run("Token signing error", func(t *testing.T) {
-		//os.Unsetenv("JWT_SECRET")
-
 		req := httptest.NewRequest("POST", "/login", strings.NewReader(`{"username":"validuser","password":"correct"}`))
 		req.Header.Set("Content-Type", "application/json")
 
@@ -201,9 +235,8 @@ func TestLoginGenerateToken(t *testing.T) {
 			t.Errorf("expected status 500, got %d", resp.Code)
 		}
 
-		if !strings.Contains(resp.Body.String(), "token signing") {
-			t.Errorf("expected error 'token signing' in response, got %s", resp.Body.String())
+		if strings.Contains(resp.Body.String(), "token signing failed") {
+			t.Errorf("expected the cause to stay out of the response, got %s", resp.Body.String())
 		}
 	})
 }
-*/
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"redditclone/pkg/jwtkeys"
+	"redditclone/pkg/logctx"
+)
+
+type JWKSHandler struct {
+	Keys   *jwtkeys.Keyring
+	Logger *slog.Logger
+}
+
+func NewJWKSHandler(keys *jwtkeys.Keyring, logger *slog.Logger) *JWKSHandler {
+	return &JWKSHandler{
+		Keys:   keys,
+		Logger: logger,
+	}
+}
+
+func (h *JWKSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	// keys are published ahead of use, a short cache is safe
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	if err := json.NewEncoder(w).Encode(h.Keys.JWKS()); err != nil {
+		logctx.From(r.Context(), h.Logger).Error("failed to write JWKS", slog.Any("err", err))
+	}
+}
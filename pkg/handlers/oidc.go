@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"redditclone/pkg/apperr"
+	"redditclone/pkg/logctx"
+	"redditclone/pkg/oidc"
+	"redditclone/pkg/token"
+	"redditclone/pkg/user"
+)
+
+const oidcStateCookie = "oidc_state"
+
+type OIDCProvider interface {
+	Begin(ctx context.Context) (authURL, state string, err error)
+	Finish(ctx context.Context, state, code string) (*oidc.Identity, error)
+}
+
+type OIDCHandler struct {
+	Provider OIDCProvider
+	Service  user.IdentityServiceInterface
+	Tokens   token.Issuer
+	Logger   *slog.Logger
+	// SecureCookie marks the state cookie https only.
+	SecureCookie bool
+	// FrontendURL is the page the browser lands on after the callback. The
+	// token or 2FA challenge travels in the URL fragment, which browsers do
+	// not send to servers or put into Referer headers.
+	FrontendURL string
+}
+
+func NewOIDCHandler(provider OIDCProvider, service user.IdentityServiceInterface, tokens token.Issuer, logger *slog.Logger) *OIDCHandler {
+	return &OIDCHandler{
+		Provider:    provider,
+		Service:     service,
+		Tokens:      tokens,
+		Logger:      logger,
+		FrontendURL: "/sso",
+	}
+}
+
+// Login sends the browser to the provider. The state also goes into a cookie
+// so the callback only completes in the browser that started the login.
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	authURL, state, err := h.Provider.Begin(r.Context())
+	if err != nil {
+		logctx.From(r.Context(), h.Logger).Error("oidc login", "error", err.Error())
+		apperr.Write(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	h.setStateCookie(w, state, 600)
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	query := r.URL.Query()
+	if reason := query.Get("error"); reason != "" {
+		logger.Warn("oidc callback", "error", reason, "description", query.Get("error_description"))
+		apperr.Write(w, http.StatusUnauthorized, "sso login failed")
+		return
+	}
+
+	state := query.Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	h.setStateCookie(w, "", -1)
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		apperr.Write(w, http.StatusBadRequest, "invalid oidc state")
+		return
+	}
+
+	identity, err := h.Provider.Finish(r.Context(), state, query.Get("code"))
+	if err != nil {
+		if errors.Is(err, oidc.ErrInvalidState) {
+			apperr.WriteError(w, logger, "oidc callback", err)
+			return
+		}
+		logger.Warn("oidc callback", "error", err.Error())
+		apperr.Write(w, http.StatusUnauthorized, "sso login failed")
+		return
+	}
+
+	u, err := h.Service.LoginExternal(r.Context(), user.ExternalProfile{
+		Provider:          identity.Issuer,
+		Subject:           identity.Subject,
+		Email:             identity.Email,
+		EmailVerified:     identity.EmailVerified,
+		PreferredUsername: identity.PreferredUsername,
+	})
+	if err != nil {
+		var secondFactor *user.SecondFactorRequiredError
+		if errors.As(err, &secondFactor) {
+			h.redirectToFrontend(w, r, url.Values{"challenge": {secondFactor.Challenge}})
+			return
+		}
+		apperr.WriteError(w, logger, "oidc login", err)
+		return
+	}
+
+	tokenString, err := h.Tokens.Issue(u.Username, u.ID, u.SessionID)
+	if err != nil {
+		apperr.WriteError(w, logger, "token signing", err)
+		return
+	}
+
+	h.redirectToFrontend(w, r, url.Values{"token": {tokenString}})
+	logger.Info("oidc login", "user", u.ID)
+}
+
+func (h *OIDCHandler) redirectToFrontend(w http.ResponseWriter, r *http.Request, fragment url.Values) {
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, h.FrontendURL+"#"+fragment.Encode(), http.StatusFound)
+}
+
+func (h *OIDCHandler) setStateCookie(w http.ResponseWriter, state string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   h.SecureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,140 @@
+package handlers_test
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"redditclone/pkg/handlers"
+	"redditclone/pkg/oidc"
+	"redditclone/pkg/oidc/oidctest"
+	"redditclone/pkg/user"
+)
+
+type mockIdentityService struct {
+	mock.Mock
+}
+
+func (m *mockIdentityService) LoginExternal(ctx context.Context, profile user.ExternalProfile) (*user.User, error) {
+	args := m.Called(profile)
+	if u := args.Get(0); u != nil {
+		return u.(*user.User), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func setupOIDCHandler(t *testing.T) (*handlers.OIDCHandler, *mockIdentityService, *oidctest.Server) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`CREATE TABLE oidc_states (
+		state_hash TEXT PRIMARY KEY,
+		verifier TEXT NOT NULL,
+		nonce TEXT NOT NULL,
+		expires_at DATETIME NOT NULL
+	);`)
+	assert.NoError(t, err)
+
+	stub := oidctest.NewServer("client", "secret")
+	t.Cleanup(stub.Close)
+
+	provider, err := oidc.NewProvider(context.Background(), &oidc.Config{
+		Issuer:       stub.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "http://app.test/api/oidc/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+	}, oidc.NewMySQLStateRepo(db))
+	assert.NoError(t, err)
+
+	m := new(mockIdentityService)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{}))
+	return handlers.NewOIDCHandler(provider, m, fakeIssuer{}, logger), m, stub
+}
+
+// startLogin runs the login endpoint and lets the stub provider answer, it
+// returns the callback request the browser would make next.
+func startLogin(t *testing.T, h *handlers.OIDCHandler) *http.Request {
+	rr := httptest.NewRecorder()
+	h.Login(rr, httptest.NewRequest(http.MethodGet, "/api/oidc/login", nil))
+	assert.Equal(t, http.StatusFound, rr.Code)
+
+	cookies := rr.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	assert.True(t, cookies[0].HttpOnly)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(rr.Header().Get("Location"))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	back, err := url.Parse(resp.Header.Get("Location"))
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/oidc/callback?"+back.RawQuery, nil)
+	req.AddCookie(cookies[0])
+	return req
+}
+
+func TestOIDCHandler_Login(t *testing.T) {
+	h, m, stub := setupOIDCHandler(t)
+	stub.SetProfile(oidctest.Profile{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true, PreferredUsername: "alice"})
+
+	m.On("LoginExternal", user.ExternalProfile{
+		Provider:          stub.URL,
+		Subject:           "sub-1",
+		Email:             "alice@example.com",
+		EmailVerified:     true,
+		PreferredUsername: "alice",
+	}).Return(&user.User{ID: "uid", Username: "alice"}, nil)
+
+	h.FrontendURL = "http://app.test/sso"
+	rr := httptest.NewRecorder()
+	h.Callback(rr, startLogin(t, h))
+
+	// the token is only in the fragment, the server of the landing page never sees it
+	assert.Equal(t, http.StatusFound, rr.Code)
+	assert.Equal(t, "http://app.test/sso#token=token-uid", rr.Header().Get("Location"))
+	assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+	assert.Empty(t, rr.Result().Cookies()[0].Value)
+	m.AssertExpectations(t)
+}
+
+func TestOIDCHandler_SecondFactor(t *testing.T) {
+	h, m, stub := setupOIDCHandler(t)
+	stub.SetProfile(oidctest.Profile{Subject: "sub-2"})
+	m.On("LoginExternal", mock.Anything).Return(nil, &user.SecondFactorRequiredError{Challenge: "chal"})
+
+	rr := httptest.NewRecorder()
+	h.Callback(rr, startLogin(t, h))
+
+	assert.Equal(t, http.StatusFound, rr.Code)
+	assert.Equal(t, "/sso#challenge=chal", rr.Header().Get("Location"))
+}
+
+func TestOIDCHandler_CallbackRejects(t *testing.T) {
+	h, m, _ := setupOIDCHandler(t)
+
+	// the cookie ties the callback to the browser that started the login
+	req := startLogin(t, h)
+	forged := httptest.NewRequest(http.MethodGet, req.URL.String(), nil)
+	rr := httptest.NewRecorder()
+	h.Callback(rr, forged)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	h.Callback(rr, httptest.NewRequest(http.MethodGet, "/api/oidc/callback?error=access_denied", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	m.AssertNotCalled(t, "LoginExternal", mock.Anything)
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"redditclone/pkg/apperr"
+	"redditclone/pkg/claims"
+	"redditclone/pkg/logctx"
+	"redditclone/pkg/token"
+	"redditclone/pkg/user"
+)
+
+type ChangePasswordForm struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ResetRequestForm struct {
+	Username string `json:"username"`
+}
+
+type ResetForm struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+type PasswordHandler struct {
+	Service user.PasswordServiceInterface
+	Tokens  token.Issuer
+	Logger  *slog.Logger
+}
+
+func NewPasswordHandler(service user.PasswordServiceInterface, tokens token.Issuer, logger *slog.Logger) *PasswordHandler {
+	return &PasswordHandler{
+		Service: service,
+		Tokens:  tokens,
+		Logger:  logger,
+	}
+}
+
+// ChangePassword answers with a new token, every other session is closed.
+func (h *PasswordHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	var claims claims.Claims
+	if ok := getClaimsFromContext(w, r, &claims); !ok {
+		return
+	}
+
+	var req ChangePasswordForm
+	if ok := DecodeJSONBody(w, r, &req); !ok {
+		return
+	}
+
+	u, err := h.Service.ChangePassword(r.Context(), claims.User.ID, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		writePasswordError(w, logger, "change password", err)
+		return
+	}
+
+	GenerateToken(h.Tokens, u, w, logger, "change password")
+}
+
+func (h *PasswordHandler) RequestReset(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	var req ResetRequestForm
+	if ok := DecodeJSONBody(w, r, &req); !ok {
+		return
+	}
+
+	if err := h.Service.RequestReset(r.Context(), req.Username); err != nil {
+		writePasswordError(w, logger, "request reset", err)
+		return
+	}
+
+	WriteResp(w, logger, map[string]any{"message": "if the account exists a reset link was sent"}, http.StatusOK)
+}
+
+func (h *PasswordHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	var req ResetForm
+	if ok := DecodeJSONBody(w, r, &req); !ok {
+		return
+	}
+
+	if err := h.Service.Reset(r.Context(), req.Token, req.NewPassword); err != nil {
+		writePasswordError(w, logger, "reset password", err)
+		return
+	}
+
+	WriteResp(w, logger, map[string]any{"message": "success"}, http.StatusOK)
+}
+
+func writePasswordError(w http.ResponseWriter, logger *slog.Logger, action string, err error) {
+	apperr.WriteError(w, logger, action, passwordFields(err, "new_password"))
+}
+
+// passwordFields blames a too short password on the param field.
+func passwordFields(err error, param string) error {
+	if !errors.Is(err, user.ErrPasswordTooShort) {
+		return err
+	}
+	return apperr.WithFields(err, FieldError{
+		Location: "body",
+		Param:    param,
+		Msg:      fmt.Sprintf("must be at least %d characters long", user.MinPasswordLen),
+	})
+}
@@ -6,14 +6,14 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"redditclone/pkg/apperr"
 	"redditclone/pkg/claims"
+	"redditclone/pkg/logctx"
 	"redditclone/pkg/post"
 )
 
 const (
 	lenID          int    = 24
-	typeError      string = "error"
-	typeMessage    string = "message"
 	muxVarPostID   string = "post_id"
 	muxVarCommID   string = "comm_id"
 	muxVarAction   string = "action"
@@ -21,6 +21,10 @@ const (
 	muxVarCategory string = "category"
 )
 
+type CommentForm struct {
+	Comment string `json:"comment"`
+}
+
 type PostHandler struct {
 	Service post.ServicePost
 	Logger  *slog.Logger
@@ -34,16 +38,26 @@ func NewPostHandler(service post.ServicePost, logger *slog.Logger) *PostHandler
 }
 
 func (h *PostHandler) GetAllPosts(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, h.Logger, h.Service.GetAll())
+	logger := logctx.From(r.Context(), h.Logger)
+
+	posts, err := h.Service.GetAll(r.Context(), viewerID(r))
+	if err != nil {
+		apperr.WriteError(w, logger, "list posts", err)
+		return
+	}
+
+	writeJSON(w, logger, newPosts(posts))
 }
 
 func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
 	defer r.Body.Close()
 
-	var newPost post.Post
-	if err := json.NewDecoder(r.Body).Decode(&newPost); err != nil {
-		h.Logger.Error("invalid json", "error", err)
-		writeError(w, http.StatusBadRequest, typeError, "invalid JSON payload")
+	var form PostForm
+	if err := json.NewDecoder(r.Body).Decode(&form); err != nil {
+		logger.Error("invalid json", "error", err)
+		apperr.Write(w, http.StatusBadRequest, "invalid JSON payload")
 		return
 	}
 
@@ -52,13 +66,14 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.Service.CreatePost(&newPost, claims.User.Username, claims.User.ID); err != nil {
-		writeError(w, http.StatusBadRequest, typeError, err.Error())
+	created := form.post()
+	if err := h.Service.CreatePost(r.Context(), created, claims.User.Username, claims.User.ID); err != nil {
+		apperr.WriteError(w, logger, "create post", err)
 		return
 	}
 
-	if ok := writeJSON(w, h.Logger, newPost); ok {
-		h.Logger.Info("new post created", "user", claims.User.ID)
+	if ok := writeJSON(w, logger, newPost(created)); ok {
+		logger.Info("new post created", "user", claims.User.ID)
 	}
 }
 
@@ -67,34 +82,36 @@ func (h *PostHandler) GetPostByID(w http.ResponseWriter, r *http.Request) {
 
 	postID, ok := vars[muxVarPostID]
 	if !ok || len(postID) != lenID {
-		writeError(w, http.StatusBadRequest, typeMessage, "invalid post id")
+		apperr.Write(w, http.StatusBadRequest, "invalid post id")
 		return
 	}
 
-	post, err := h.Service.GetByID(postID)
+	post, err := h.Service.GetByID(r.Context(), postID, viewerID(r))
 	if err != nil {
-		writeError(w, http.StatusNotFound, typeMessage, err.Error())
+		apperr.WriteError(w, logctx.From(r.Context(), h.Logger), "get post", err)
 		return
 	}
 
-	writeJSON(w, h.Logger, post)
+	writeJSON(w, logctx.From(r.Context(), h.Logger), newPost(post))
 }
 
 func (h *PostHandler) AddComment(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
 	defer r.Body.Close()
 
 	vars := mux.Vars(r)
 
 	postID, ok := vars[muxVarPostID]
 	if !ok || len(postID) != lenID {
-		writeError(w, http.StatusBadRequest, typeMessage, "invalid post id")
+		apperr.Write(w, http.StatusBadRequest, "invalid post id")
 		return
 	}
 
-	var comment = make(map[string]string)
+	var comment CommentForm
 	if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
-		h.Logger.Error("Invalid JSON", "error", err)
-		writeError(w, http.StatusBadRequest, typeError, "invalid JSON payload")
+		logger.Error("Invalid JSON", "error", err)
+		apperr.Write(w, http.StatusBadRequest, "invalid JSON payload")
 		return
 	}
 
@@ -103,75 +120,80 @@ func (h *PostHandler) AddComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	post, err := h.Service.AddComment(postID, comment["comment"], &claims)
+	post, err := h.Service.AddComment(r.Context(), postID, comment.Comment, &claims)
 	if err != nil {
-		h.Logger.Error("AddComment", "error", err)
-		writeError(w, http.StatusBadRequest, typeError, err.Error())
+		apperr.WriteError(w, logger, "add comment", err)
 		return
 	}
 
-	if ok := writeJSON(w, h.Logger, post); ok {
-		h.Logger.Info("new comm created", "user", claims.User.ID)
+	if ok := writeJSON(w, logger, newPost(post)); ok {
+		logger.Info("new comm created", "user", claims.User.ID)
 	}
 }
 
 func (h *PostHandler) RemoveComment(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
 	vars := mux.Vars(r)
 
 	postID, ok1 := vars[muxVarPostID]
 	if !ok1 {
-		writeError(w, http.StatusBadRequest, typeMessage, "invalid post id")
+		apperr.Write(w, http.StatusBadRequest, "invalid post id")
 		return
 	}
 
 	commID, ok2 := vars[muxVarCommID]
 	if !ok2 {
-		writeError(w, http.StatusBadRequest, typeMessage, "invalid comment id")
+		apperr.Write(w, http.StatusBadRequest, "invalid comment id")
 		return
 	}
 
-	post, err := h.Service.RemoveComment(postID, commID)
+	post, err := h.Service.RemoveComment(r.Context(), postID, commID)
 	if err != nil {
-		writeError(w, http.StatusNotFound, typeError, err.Error())
+		apperr.WriteError(w, logger, "remove comment", err)
 		return
 	}
 
-	if ok := writeJSON(w, h.Logger, post); ok {
-		h.Logger.Info("comment delete", muxVarPostID, postID, muxVarCommID, commID)
+	if ok := writeJSON(w, logger, newPost(post)); ok {
+		logger.Info("comment delete", muxVarPostID, postID, muxVarCommID, commID)
 	}
 }
 
 func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
 	vars := mux.Vars(r)
 
 	postID, ok := vars[muxVarPostID]
 	if !ok {
-		writeError(w, http.StatusBadRequest, typeMessage, "invalid post id")
+		apperr.Write(w, http.StatusBadRequest, "invalid post id")
 		return
 	}
 
-	if err := h.Service.Delete(postID); err != nil {
-		writeError(w, http.StatusNotFound, typeError, err.Error())
+	if err := h.Service.Delete(r.Context(), postID); err != nil {
+		apperr.WriteError(w, logger, "delete post", err)
 		return
 	}
 
-	if ok := writeJSON(w, h.Logger, map[string]string{"message": "success"}); ok {
-		h.Logger.Info("post delete", muxVarPostID, postID)
+	if ok := writeJSON(w, logger, map[string]string{"message": "success"}); ok {
+		logger.Info("post delete", muxVarPostID, postID)
 	}
 }
 
 func (h *PostHandler) AddVote(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
 	vars := mux.Vars(r)
 
 	postID, ok1 := vars[muxVarPostID]
 	if !ok1 {
-		writeError(w, http.StatusBadRequest, typeMessage, "invalid post id")
+		apperr.Write(w, http.StatusBadRequest, "invalid post id")
 		return
 	}
 
 	action, ok2 := vars[muxVarAction]
 	if !ok2 {
-		writeError(w, http.StatusBadRequest, typeMessage, "invalid vote action")
+		apperr.Write(w, http.StatusBadRequest, "invalid vote action")
 		return
 	}
 
@@ -180,14 +202,14 @@ func (h *PostHandler) AddVote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	post, err := h.Service.AddVote(postID, claims.User.ID, action)
+	post, err := h.Service.AddVote(r.Context(), postID, claims.User.ID, action)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, typeError, err.Error())
+		apperr.WriteError(w, logger, "vote", err)
 		return
 	}
 
-	if ok := writeJSON(w, h.Logger, post); ok {
-		h.Logger.Info("user voting", "user", claims.User.ID, muxVarAction, action)
+	if ok := writeJSON(w, logger, newPost(post)); ok {
+		logger.Info("user voting", "user", claims.User.ID, muxVarAction, action)
 	}
 }
 
@@ -196,13 +218,17 @@ func (h *PostHandler) GetPostsByUser(w http.ResponseWriter, r *http.Request) {
 
 	userID, ok := vars[muxVarLogin]
 	if !ok {
-		writeError(w, http.StatusBadRequest, typeMessage, "invalid user id")
+		apperr.Write(w, http.StatusBadRequest, "invalid user id")
 		return
 	}
 
-	posts := h.Service.GetByUser(userID)
+	posts, err := h.Service.GetByUser(r.Context(), userID, viewerID(r))
+	if err != nil {
+		apperr.WriteError(w, logctx.From(r.Context(), h.Logger), "list posts", err)
+		return
+	}
 
-	writeJSON(w, h.Logger, posts)
+	writeJSON(w, logctx.From(r.Context(), h.Logger), newPosts(posts))
 }
 
 func (h *PostHandler) GetPostsByCategory(w http.ResponseWriter, r *http.Request) {
@@ -210,20 +236,24 @@ func (h *PostHandler) GetPostsByCategory(w http.ResponseWriter, r *http.Request)
 
 	category, ok := vars[muxVarCategory]
 	if !ok {
-		writeError(w, http.StatusBadRequest, typeMessage, "invalid category")
+		apperr.Write(w, http.StatusBadRequest, "invalid category")
 		return
 	}
 
-	posts := h.Service.GetByCategory(category)
+	posts, err := h.Service.GetByCategory(r.Context(), category, viewerID(r))
+	if err != nil {
+		apperr.WriteError(w, logctx.From(r.Context(), h.Logger), "list posts", err)
+		return
+	}
 
-	writeJSON(w, h.Logger, posts)
+	writeJSON(w, logctx.From(r.Context(), h.Logger), newPosts(posts))
 }
 
 func writeJSON(w http.ResponseWriter, logger *slog.Logger, data any) bool {
 	resp, err := json.Marshal(data)
 	if err != nil {
 		logger.Error("Failed to serialize JSON response", "error", err)
-		writeError(w, http.StatusInternalServerError, typeError, "failed json marshal")
+		apperr.Write(w, http.StatusInternalServerError, "failed json marshal")
 		return false
 	}
 
@@ -239,17 +269,18 @@ func writeJSON(w http.ResponseWriter, logger *slog.Logger, data any) bool {
 func getClaimsFromContext(w http.ResponseWriter, r *http.Request, c *claims.Claims) bool {
 	val, ok := r.Context().Value(claims.TokenContextKey).(*claims.Claims)
 	if !ok || val == nil || val.User.ID == "" {
-		writeError(w, http.StatusUnauthorized, typeMessage, "unauthorized")
+		apperr.Write(w, http.StatusUnauthorized, "unauthorized")
 		return false
 	}
 	*c = *val
 	return true
 }
 
-func writeError(w http.ResponseWriter, status int, field, msg string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(map[string]string{field: msg}); err != nil {
-		return
+// viewerID returns the ID of the authenticated caller, or "" for anonymous requests.
+func viewerID(r *http.Request) string {
+	val, ok := r.Context().Value(claims.TokenContextKey).(*claims.Claims)
+	if !ok || val == nil {
+		return ""
 	}
+	return val.User.ID
 }
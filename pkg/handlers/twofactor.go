@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"redditclone/pkg/apperr"
+	"redditclone/pkg/claims"
+	"redditclone/pkg/clientip"
+	"redditclone/pkg/logctx"
+	"redditclone/pkg/token"
+	"redditclone/pkg/user"
+)
+
+type CodeForm struct {
+	Code string `json:"code"`
+}
+
+type DisableTwoFactorForm struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type CompleteLoginForm struct {
+	Challenge string `json:"challenge"`
+	Code      string `json:"code"`
+}
+
+type TwoFactorHandler struct {
+	Service user.TwoFactorServiceInterface
+	Tokens  token.Issuer
+	Logger  *slog.Logger
+}
+
+func NewTwoFactorHandler(service user.TwoFactorServiceInterface, tokens token.Issuer, logger *slog.Logger) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		Service: service,
+		Tokens:  tokens,
+		Logger:  logger,
+	}
+}
+
+func (h *TwoFactorHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	var claims claims.Claims
+	if ok := getClaimsFromContext(w, r, &claims); !ok {
+		return
+	}
+
+	enrollment, err := h.Service.Enroll(r.Context(), claims.User.ID)
+	if err != nil {
+		apperr.WriteError(w, logger, "2fa enroll", err)
+		return
+	}
+
+	writeJSON(w, logger, enrollment)
+}
+
+func (h *TwoFactorHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	var claims claims.Claims
+	if ok := getClaimsFromContext(w, r, &claims); !ok {
+		return
+	}
+
+	var req CodeForm
+	if ok := DecodeJSONBody(w, r, &req); !ok {
+		return
+	}
+
+	codes, err := h.Service.Confirm(r.Context(), claims.User.ID, req.Code)
+	if err != nil {
+		apperr.WriteError(w, logger, "2fa confirm", err)
+		return
+	}
+
+	if ok := WriteResp(w, logger, map[string]any{"recovery_codes": codes}, http.StatusOK); ok {
+		logger.Info("2fa enabled", "user", claims.User.ID)
+	}
+}
+
+func (h *TwoFactorHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	var claims claims.Claims
+	if ok := getClaimsFromContext(w, r, &claims); !ok {
+		return
+	}
+
+	var req DisableTwoFactorForm
+	if ok := DecodeJSONBody(w, r, &req); !ok {
+		return
+	}
+
+	if err := h.Service.Disable(r.Context(), claims.User.ID, req.Password, req.Code); err != nil {
+		apperr.WriteError(w, logger, "2fa disable", err)
+		return
+	}
+
+	if ok := WriteResp(w, logger, map[string]any{"message": "success"}, http.StatusOK); ok {
+		logger.Info("2fa disabled", "user", claims.User.ID)
+	}
+}
+
+// Complete is the second step of Handler.Login for accounts with 2FA.
+func (h *TwoFactorHandler) Complete(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	var req CompleteLoginForm
+	if ok := DecodeJSONBody(w, r, &req); !ok {
+		return
+	}
+
+	u, err := h.Service.Complete(r.Context(), req.Challenge, req.Code, clientip.From(r))
+	if err != nil {
+		apperr.WriteError(w, logger, "2fa login", err)
+		return
+	}
+
+	GenerateToken(h.Tokens, u, w, logger, "login")
+}
@@ -2,127 +2,130 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
-	"os"
-	"time"
 
+	"redditclone/pkg/apperr"
+	"redditclone/pkg/clientip"
+	"redditclone/pkg/logctx"
+	"redditclone/pkg/token"
 	"redditclone/pkg/user"
-
-	jwt "github.com/dgrijalva/jwt-go"
 )
 
 type LoginForm struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	Email    string `json:"email,omitempty"`
 }
 
 type Handler struct {
 	Service user.ServiceInterface
+	Tokens  token.Issuer
 	Logger  *slog.Logger
 }
 
-type FieldError struct {
-	Location string `json:"location"`
-	Param    string `json:"param"`
-	Value    string `json:"value"`
-	Msg      string `json:"msg"`
-}
+// FieldError is an entry of the errors list of the error envelope.
+type FieldError = apperr.FieldError
 
-func NewUserHandler(service user.ServiceInterface, logger *slog.Logger) *Handler {
+func NewUserHandler(service user.ServiceInterface, tokens token.Issuer, logger *slog.Logger) *Handler {
 	return &Handler{
 		Service: service,
+		Tokens:  tokens,
 		Logger:  logger,
 	}
 }
 
 func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
 	var req LoginForm
 	if ok := DecodeJSONBody(w, r, &req); !ok {
 		return
 	}
 
-	user, err := h.Service.Register(req.Username, req.Password)
+	u, err := h.Service.Register(r.Context(), req.Username, req.Password, req.Email)
 	if err != nil {
-		if err.Error() != "user already exists" {
-			h.Logger.Error("register", "error", err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+		if errors.Is(err, user.ErrExists) {
+			err = apperr.WithFields(err, FieldError{Location: "body", Param: "username", Value: req.Username, Msg: "already exists"})
 		}
-		if ok := WriteResp(w, h.Logger, map[string]any{
-			"errors": []FieldError{
-				{
-					Location: "body",
-					Param:    "username",
-					Value:    req.Username,
-					Msg:      "already exists",
-				},
-			},
-		}, http.StatusUnprocessableEntity); ok {
-			h.Logger.Error("register", "error", err.Error(), "user", user)
-		}
-	} else {
-		GenerateToken(user.Username, user.ID, w, h.Logger, "register")
+		err = passwordFields(err, "password")
+		apperr.WriteError(w, logger, "register", emailFields(err, req.Email))
+		return
 	}
+
+	GenerateToken(h.Tokens, u, w, logger, "register")
 }
 
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
 	var req LoginForm
 	if ok := DecodeJSONBody(w, r, &req); !ok {
 		return
 	}
 
-	user, err := h.Service.Login(req.Username, req.Password)
+	u, err := h.Service.Login(r.Context(), req.Username, req.Password, clientip.From(r))
 	if err != nil {
-		var msg string
-		if err.Error() == "user not found" {
-			msg = "user not found"
-		} else {
-			msg = "invalid password"
+		var secondFactor *user.SecondFactorRequiredError
+		if errors.As(err, &secondFactor) {
+			WriteResp(w, logger, map[string]any{
+				"second_factor_required": true,
+				"challenge":              secondFactor.Challenge,
+			}, http.StatusOK)
+			return
 		}
-		if ok := WriteResp(w, h.Logger, map[string]any{"message": msg}, http.StatusUnauthorized); ok {
-			h.Logger.Error("login", "error", "unauthorized", "user", user)
+		if apperr.KindOf(err) != apperr.Internal {
+			logger.Warn("login", "error", err, "username", req.Username)
 		}
-	} else {
-		GenerateToken(user.Username, user.ID, w, h.Logger, "login")
+		apperr.WriteError(w, logger, "login", err)
+		return
+	}
+
+	GenerateToken(h.Tokens, u, w, logger, "login")
+}
+
+// emailFields blames the email errors of the user package on the email field.
+func emailFields(err error, email string) error {
+	field := FieldError{Location: "body", Param: "email", Value: email}
+	switch {
+	case errors.Is(err, user.ErrInvalidEmail):
+		field.Msg = "is invalid"
+	case errors.Is(err, user.ErrEmailInUse):
+		field.Msg = "already in use"
+	default:
+		return err
 	}
+	return apperr.WithFields(err, field)
 }
 
 func DecodeJSONBody(w http.ResponseWriter, r *http.Request, req any) bool {
 	if r.Header.Get("Content-Type") != "application/json" {
-		writeError(w, http.StatusBadRequest, typeError, "invalid Content-Type")
+		apperr.Write(w, http.StatusBadRequest, "invalid Content-Type")
 		return false
 	}
 
 	defer r.Body.Close()
 
 	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-		writeError(w, http.StatusBadRequest, typeError, "bad json")
+		apperr.Write(w, http.StatusBadRequest, "bad json")
 		return false
 	}
 
 	return true
 }
 
-func GenerateToken(username, userID string, w http.ResponseWriter, logger *slog.Logger, action string) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user": map[string]string{
-			"username": username,
-			"id":       userID,
-		},
-		"iat": time.Now().UTC().Unix(),
-		"exp": time.Now().Add(time.Hour * 1).UTC().Unix(),
-	})
-	JWTSecret := os.Getenv("JWT_SECRET")
-	tokenString, err := token.SignedString([]byte(JWTSecret))
+// GenerateToken answers a token for the session Login or its siblings opened
+// for u.
+func GenerateToken(tokens token.Issuer, u *user.User, w http.ResponseWriter, logger *slog.Logger, action string) {
+	tokenString, err := tokens.Issue(u.Username, u.ID, u.SessionID)
 	if err != nil {
-		logger.Error("token signing", "error", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		apperr.WriteError(w, logger, "token signing", err)
 		return
 	}
 
 	if ok := WriteResp(w, logger, map[string]any{"token": tokenString}, http.StatusOK); ok {
-		logger.Info(action, "user", userID)
+		logger.Info(action, "user", u.ID)
 	}
 }
 
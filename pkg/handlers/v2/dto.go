@@ -0,0 +1,108 @@
+package v2
+
+import (
+	"time"
+
+	"redditclone/pkg/post"
+	"redditclone/pkg/user"
+)
+
+type PostForm struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Category string `json:"category"`
+	Text     string `json:"text,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+type CommentForm struct {
+	Body string `json:"body"`
+}
+
+type Author struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+type Comment struct {
+	ID      string    `json:"id"`
+	Author  Author    `json:"author"`
+	Body    string    `json:"body"`
+	Created time.Time `json:"created"`
+}
+
+// Post tells the caller its own vote instead of listing who voted. Comments
+// are only filled in the body of a single post, lists leave them empty and
+// have their count.
+type Post struct {
+	ID               string    `json:"id"`
+	Type             string    `json:"type"`
+	Title            string    `json:"title"`
+	URL              string    `json:"url,omitempty"`
+	Text             string    `json:"text,omitempty"`
+	Category         string    `json:"category"`
+	Author           Author    `json:"author"`
+	Created          time.Time `json:"created"`
+	Score            int       `json:"score"`
+	UpvotePercentage int       `json:"upvote_percentage"`
+	Views            int       `json:"views"`
+	// MyVote is 1 or -1, 0 when the caller did not vote or is anonymous.
+	MyVote       int8      `json:"my_vote"`
+	CommentCount int       `json:"comment_count"`
+	Comments     []Comment `json:"comments"`
+}
+
+// PostPage is a page of a list of posts, Total counts the posts of every
+// page.
+type PostPage struct {
+	Items   []Post `json:"items"`
+	Page    int    `json:"page"`
+	PerPage int    `json:"per_page"`
+	Total   int    `json:"total"`
+}
+
+func (f PostForm) post() *post.Post {
+	p := &post.Post{Type: f.Type, Title: f.Title, Category: f.Category, Text: f.Text}
+	if f.URL != "" {
+		url := f.URL
+		p.URL = &url
+	}
+	return p
+}
+
+func newAuthor(u user.User) Author {
+	return Author{ID: u.ID, Username: u.Username}
+}
+
+// newPost describes p to viewerID, comments are left out of summaries.
+func newPost(p *post.Post, viewerID string, summary bool) Post {
+	dto := Post{
+		ID:               p.ID,
+		Type:             p.Type,
+		Title:            p.Title,
+		Text:             p.Text,
+		Category:         p.Category,
+		Author:           newAuthor(p.Author),
+		Created:          p.Created,
+		Score:            p.Score,
+		UpvotePercentage: p.UpvotePercentage,
+		Views:            p.Views,
+		CommentCount:     len(p.Comments),
+		Comments:         []Comment{},
+	}
+	if p.URL != nil {
+		dto.URL = *p.URL
+	}
+	for _, v := range p.Votes {
+		if viewerID != "" && v.User == viewerID {
+			dto.MyVote = v.Vote
+		}
+	}
+	if !summary {
+		dto.Comments = make([]Comment, len(p.Comments))
+		for i, c := range p.Comments {
+			dto.Comments[i] = Comment{ID: c.ID, Author: newAuthor(c.Author), Body: c.Body, Created: c.Created}
+		}
+	}
+	return dto
+}
@@ -0,0 +1,311 @@
+// Package v2 holds the handlers of /api/v2 that differ from /api. They call
+// the same services with bodies of their own: lists come in pages, a post
+// tells the caller its vote instead of listing the voters, and invalid input
+// is answered with the fields at fault.
+package v2
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"redditclone/pkg/apperr"
+	"redditclone/pkg/claims"
+	"redditclone/pkg/handlers"
+	"redditclone/pkg/logctx"
+	"redditclone/pkg/post"
+)
+
+const (
+	muxVarPostID = "post_id"
+	muxVarCommID = "comm_id"
+	muxVarAction = "action"
+	muxVarLogin  = "login"
+
+	defaultPerPage = 25
+	maxPerPage     = 100
+)
+
+var (
+	errInvalidPost    = apperr.New(apperr.Invalid, "invalid_post", "invalid post")
+	errInvalidComment = apperr.New(apperr.Invalid, "invalid_comment", "invalid comment")
+	errInvalidPage    = apperr.New(apperr.Invalid, "invalid_page", "invalid page")
+)
+
+type PostHandler struct {
+	Service post.ServicePost
+	Logger  *slog.Logger
+}
+
+func NewPostHandler(service post.ServicePost, logger *slog.Logger) *PostHandler {
+	return &PostHandler{
+		Service: service,
+		Logger:  logger,
+	}
+}
+
+// List answers a page of every post, or of the posts of the category query
+// parameter.
+func (h *PostHandler) List(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	page, perPage, err := pagination(r.URL.Query())
+	if err != nil {
+		apperr.WriteError(w, logger, "list posts", err)
+		return
+	}
+
+	viewer := viewerID(r)
+	filter := post.ListFilter{Category: r.URL.Query().Get("category")}
+	posts, total, err := h.Service.GetPage(r.Context(), filter, viewer, page, perPage)
+	if err != nil {
+		apperr.WriteError(w, logger, "list posts", err)
+		return
+	}
+
+	writeJSON(w, logger, http.StatusOK, newPage(posts, total, viewer, page, perPage))
+}
+
+func (h *PostHandler) ListByUser(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	page, perPage, err := pagination(r.URL.Query())
+	if err != nil {
+		apperr.WriteError(w, logger, "list posts", err)
+		return
+	}
+
+	viewer := viewerID(r)
+	filter := post.ListFilter{Username: mux.Vars(r)[muxVarLogin]}
+	posts, total, err := h.Service.GetPage(r.Context(), filter, viewer, page, perPage)
+	if err != nil {
+		apperr.WriteError(w, logger, "list posts", err)
+		return
+	}
+
+	writeJSON(w, logger, http.StatusOK, newPage(posts, total, viewer, page, perPage))
+}
+
+func (h *PostHandler) Create(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	c, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var form PostForm
+	if ok := handlers.DecodeJSONBody(w, r, &form); !ok {
+		return
+	}
+	if err := form.validate(); err != nil {
+		apperr.WriteError(w, logger, "create post", err)
+		return
+	}
+
+	p := form.post()
+	if err := h.Service.CreatePost(r.Context(), p, c.User.Username, c.User.ID); err != nil {
+		apperr.WriteError(w, logger, "create post", err)
+		return
+	}
+
+	if writeJSON(w, logger, http.StatusCreated, newPost(p, c.User.ID, false)) {
+		logger.Info("new post created", "user", c.User.ID)
+	}
+}
+
+func (h *PostHandler) Get(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	viewer := viewerID(r)
+	p, err := h.Service.GetByID(r.Context(), mux.Vars(r)[muxVarPostID], viewer)
+	if err != nil {
+		apperr.WriteError(w, logger, "get post", err)
+		return
+	}
+
+	writeJSON(w, logger, http.StatusOK, newPost(p, viewer, false))
+}
+
+func (h *PostHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	c, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+
+	postID := mux.Vars(r)[muxVarPostID]
+	if err := h.Service.DeleteAs(r.Context(), postID, c.User.ID); err != nil {
+		apperr.WriteError(w, logger, "delete post", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	logger.Info("post delete", muxVarPostID, postID)
+}
+
+func (h *PostHandler) AddComment(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	c, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var form CommentForm
+	if ok := handlers.DecodeJSONBody(w, r, &form); !ok {
+		return
+	}
+	if strings.TrimSpace(form.Body) == "" {
+		apperr.WriteError(w, logger, "add comment", apperr.WithFields(errInvalidComment,
+			apperr.FieldError{Location: "body", Param: "body", Value: form.Body, Msg: "is required"}))
+		return
+	}
+
+	p, err := h.Service.AddComment(r.Context(), mux.Vars(r)[muxVarPostID], form.Body, c)
+	if err != nil {
+		apperr.WriteError(w, logger, "add comment", err)
+		return
+	}
+
+	if writeJSON(w, logger, http.StatusCreated, newPost(p, c.User.ID, false)) {
+		logger.Info("new comm created", "user", c.User.ID)
+	}
+}
+
+func (h *PostHandler) RemoveComment(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	c, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	p, err := h.Service.RemoveCommentAs(r.Context(), vars[muxVarPostID], vars[muxVarCommID], c.User.ID)
+	if err != nil {
+		apperr.WriteError(w, logger, "remove comment", err)
+		return
+	}
+
+	if writeJSON(w, logger, http.StatusOK, newPost(p, c.User.ID, false)) {
+		logger.Info("comment delete", muxVarPostID, vars[muxVarPostID], muxVarCommID, vars[muxVarCommID])
+	}
+}
+
+func (h *PostHandler) Vote(w http.ResponseWriter, r *http.Request) {
+	logger := logctx.From(r.Context(), h.Logger)
+
+	c, ok := requireClaims(w, r)
+	if !ok {
+		return
+	}
+
+	vars := mux.Vars(r)
+	p, err := h.Service.AddVote(r.Context(), vars[muxVarPostID], c.User.ID, vars[muxVarAction])
+	if err != nil {
+		apperr.WriteError(w, logger, "vote", err)
+		return
+	}
+
+	if writeJSON(w, logger, http.StatusOK, newPost(p, c.User.ID, false)) {
+		logger.Info("user voting", "user", c.User.ID, muxVarAction, vars[muxVarAction])
+	}
+}
+
+func (f PostForm) validate() error {
+	var fields []apperr.FieldError
+	invalid := func(param, value, msg string) {
+		fields = append(fields, apperr.FieldError{Location: "body", Param: param, Value: value, Msg: msg})
+	}
+
+	switch f.Type {
+	case "text":
+		if strings.TrimSpace(f.Text) == "" {
+			invalid("text", f.Text, "is required")
+		}
+	case "link":
+		if u, err := url.Parse(f.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("url", f.URL, "must be an http or https URL")
+		}
+	default:
+		invalid("type", f.Type, "must be text or link")
+	}
+	if strings.TrimSpace(f.Title) == "" {
+		invalid("title", f.Title, "is required")
+	}
+	if !post.ValidCategory(f.Category) {
+		invalid("category", f.Category, "must be one of "+strings.Join(post.Categories, ", "))
+	}
+
+	if len(fields) > 0 {
+		return apperr.WithFields(errInvalidPost, fields...)
+	}
+	return nil
+}
+
+// pagination reads the page and per_page query parameters, pages count from
+// 1.
+func pagination(query url.Values) (page, perPage int, err error) {
+	var fields []apperr.FieldError
+	read := func(param string, def, maxValue int) int {
+		raw := query.Get(param)
+		if raw == "" {
+			return def
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxValue {
+			fields = append(fields, apperr.FieldError{Location: "query", Param: param, Value: raw,
+				Msg: "must be between 1 and " + strconv.Itoa(maxValue)})
+		}
+		return n
+	}
+
+	page = read("page", 1, 1<<20)
+	perPage = read("per_page", defaultPerPage, maxPerPage)
+	if len(fields) > 0 {
+		return 0, 0, apperr.WithFields(errInvalidPage, fields...)
+	}
+	return page, perPage, nil
+}
+
+func newPage(posts []*post.Post, total int, viewerID string, page, perPage int) PostPage {
+	items := make([]Post, 0, len(posts))
+	for _, p := range posts {
+		items = append(items, newPost(p, viewerID, true))
+	}
+	return PostPage{Items: items, Page: page, PerPage: perPage, Total: total}
+}
+
+func viewerID(r *http.Request) string {
+	if c, ok := r.Context().Value(claims.TokenContextKey).(*claims.Claims); ok && c != nil {
+		return c.User.ID
+	}
+	return ""
+}
+
+// requireClaims answers 401 when CheckJWT let the request through without
+// claims, which only public routes do.
+func requireClaims(w http.ResponseWriter, r *http.Request) (*claims.Claims, bool) {
+	c, ok := r.Context().Value(claims.TokenContextKey).(*claims.Claims)
+	if !ok || c == nil {
+		apperr.Write(w, http.StatusUnauthorized, "unauthorized")
+		return nil, false
+	}
+	return c, true
+}
+
+func writeJSON(w http.ResponseWriter, logger *slog.Logger, status int, body any) bool {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		logger.Error("failed to write JSON response", "error", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,216 @@
+package v2_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"redditclone/pkg/apperr"
+	"redditclone/pkg/claims"
+	v2 "redditclone/pkg/handlers/v2"
+	"redditclone/pkg/post"
+	"redditclone/pkg/post/mocks"
+	"redditclone/pkg/user"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const postID = "0123456789abcdef01234567"
+
+func withClaims(r *http.Request) *http.Request {
+	c := &claims.Claims{}
+	c.User.Username = "viewer"
+	c.User.ID = "viewer-id"
+	return r.WithContext(context.WithValue(r.Context(), claims.TokenContextKey, c))
+}
+
+func jsonRequest(method, target, body string) *http.Request {
+	r := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	r.Header.Set("Content-Type", "application/json")
+	return withClaims(r)
+}
+
+func newHandler() (*v2.PostHandler, *mocks.ServicePost) {
+	service := new(mocks.ServicePost)
+	return v2.NewPostHandler(service, slog.Default()), service
+}
+
+func TestList(t *testing.T) {
+	posts := []*post.Post{{
+		ID: postID, Type: "text", Title: "title", Text: "text", Category: "news",
+		Author:   user.User{ID: "author-id", Username: "author"},
+		Votes:    []post.Voting{{User: "author-id", Vote: 1}, {User: "viewer-id", Vote: -1}},
+		Comments: []post.Comment{{ID: "c1", Body: "comment"}},
+	}}
+
+	h, service := newHandler()
+	service.On("GetPage", mock.Anything, post.ListFilter{Category: "news"}, "viewer-id", 2, 2).Return(posts, 3, nil)
+
+	w := httptest.NewRecorder()
+	h.List(w, withClaims(httptest.NewRequest(http.MethodGet, "/api/v2/posts?category=news&page=2&per_page=2", nil)))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var page v2.PostPage
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Equal(t, 2, page.Page)
+	assert.Equal(t, 2, page.PerPage)
+	assert.Equal(t, 3, page.Total)
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, int8(-1), page.Items[0].MyVote)
+		assert.Equal(t, 1, page.Items[0].CommentCount)
+		assert.Empty(t, page.Items[0].Comments)
+	}
+	assert.Contains(t, w.Body.String(), `"comments":[]`)
+	assert.NotContains(t, w.Body.String(), "votes")
+	service.AssertExpectations(t)
+}
+
+func TestList_InvalidPage(t *testing.T) {
+	h, service := newHandler()
+
+	w := httptest.NewRecorder()
+	h.List(w, httptest.NewRequest(http.MethodGet, "/api/v2/posts?page=0&per_page=1000", nil))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var body apperr.Body
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "invalid_page", body.Code)
+	assert.Len(t, body.Errors, 2)
+	service.AssertNotCalled(t, "GetPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestListByUser(t *testing.T) {
+	h, service := newHandler()
+	service.On("GetPage", mock.Anything, post.ListFilter{Username: "author"}, "", 1, 25).Return([]*post.Post{}, 0, nil)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v2/users/author/posts", nil)
+	r = mux.SetURLVars(r, map[string]string{"login": "author"})
+	w := httptest.NewRecorder()
+	h.ListByUser(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"items":[],"page":1,"per_page":25,"total":0}`, w.Body.String())
+	service.AssertExpectations(t)
+}
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		fields []string
+	}{
+		{
+			name:   "text",
+			body:   `{"type":"text","title":"title","category":"news","text":"text"}`,
+			status: http.StatusCreated,
+		},
+		{
+			name:   "link without url",
+			body:   `{"type":"link","title":" ","category":"news"}`,
+			status: http.StatusUnprocessableEntity,
+			fields: []string{"url", "title"},
+		},
+		{
+			name:   "unknown type",
+			body:   `{"type":"image","title":"title"}`,
+			status: http.StatusUnprocessableEntity,
+			fields: []string{"type", "category"},
+		},
+		{
+			name:   "unknown category",
+			body:   `{"type":"text","title":"title","category":"cats","text":"text"}`,
+			status: http.StatusUnprocessableEntity,
+			fields: []string{"category"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, service := newHandler()
+			service.On("CreatePost", mock.Anything, mock.AnythingOfType("*post.Post"), "viewer", "viewer-id").
+				Run(func(args mock.Arguments) { args.Get(1).(*post.Post).ID = postID }).
+				Return(nil)
+
+			w := httptest.NewRecorder()
+			h.Create(w, jsonRequest(http.MethodPost, "/api/v2/posts", tt.body))
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusCreated {
+				var p v2.Post
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+				assert.Equal(t, postID, p.ID)
+				assert.Contains(t, w.Body.String(), `"comments":[]`)
+				return
+			}
+			var body apperr.Body
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			var params []string
+			for _, f := range body.Errors {
+				params = append(params, f.Param)
+			}
+			assert.Equal(t, tt.fields, params)
+			service.AssertNotCalled(t, "CreatePost", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	h, service := newHandler()
+	service.On("DeleteAs", mock.Anything, postID, "viewer-id").Return(nil)
+
+	r := httptest.NewRequest(http.MethodDelete, "/api/v2/posts/"+postID, nil)
+	r = mux.SetURLVars(withClaims(r), map[string]string{"post_id": postID})
+	w := httptest.NewRecorder()
+	h.Delete(w, r)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+	service.AssertExpectations(t)
+}
+
+func TestDelete_NotAuthor(t *testing.T) {
+	h, service := newHandler()
+	service.On("DeleteAs", mock.Anything, postID, "viewer-id").Return(post.ErrNotAuthor)
+
+	r := httptest.NewRequest(http.MethodDelete, "/api/v2/posts/"+postID, nil)
+	r = mux.SetURLVars(withClaims(r), map[string]string{"post_id": postID})
+	w := httptest.NewRecorder()
+	h.Delete(w, r)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"not_author"`)
+	service.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestRemoveComment_NotAuthor(t *testing.T) {
+	h, service := newHandler()
+	service.On("RemoveCommentAs", mock.Anything, postID, "c1", "viewer-id").Return(nil, post.ErrNotAuthor)
+
+	r := httptest.NewRequest(http.MethodDelete, "/api/v2/posts/"+postID+"/comments/c1", nil)
+	r = mux.SetURLVars(withClaims(r), map[string]string{"post_id": postID, "comm_id": "c1"})
+	w := httptest.NewRecorder()
+	h.RemoveComment(w, r)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	service.AssertNotCalled(t, "RemoveComment", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAddComment_Empty(t *testing.T) {
+	h, service := newHandler()
+
+	r := jsonRequest(http.MethodPost, "/api/v2/posts/"+postID+"/comments", `{"body":"  "}`)
+	r = mux.SetURLVars(r, map[string]string{"post_id": postID})
+	w := httptest.NewRecorder()
+	h.AddComment(w, r)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), `"invalid_comment"`)
+	service.AssertNotCalled(t, "AddComment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
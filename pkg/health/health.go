@@ -0,0 +1,120 @@
+// Package health answers the liveness and readiness probes of an
+// orchestrator.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultTimeout = 2 * time.Second
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+	StatusShutdown    = "shutting down"
+)
+
+type check struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Checker runs the dependency checks behind /readyz.
+type Checker struct {
+	// Timeout bounds each check.
+	Timeout time.Duration
+	// Logger gets the errors of failed checks, probes only see the status.
+	Logger *slog.Logger
+
+	checks   []check
+	draining atomic.Bool
+}
+
+func NewChecker() *Checker {
+	return &Checker{Timeout: DefaultTimeout, Logger: slog.Default()}
+}
+
+// Add registers a dependency, fn reports whether it can be used.
+func (c *Checker) Add(name string, fn func(ctx context.Context) error) {
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// Drain marks the service not ready for good, so that load balancers stop
+// sending traffic while in-flight requests finish.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+type DependencyStatus struct {
+	Status string `json:"status"`
+}
+
+type Report struct {
+	Status string                      `json:"status"`
+	Checks map[string]DependencyStatus `json:"checks,omitempty"`
+}
+
+// Check runs all checks at once and reports ok only if each of them passed.
+func (c *Checker) Check(ctx context.Context) Report {
+	if c.draining.Load() {
+		return Report{Status: StatusShutdown}
+	}
+
+	report := Report{Status: StatusOK, Checks: make(map[string]DependencyStatus, len(c.checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, chk := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+			defer cancel()
+
+			status := DependencyStatus{Status: StatusOK}
+			if err := chk.fn(ctx); err != nil {
+				c.Logger.Warn("readiness check", "dependency", chk.name, "error", err.Error())
+				status = DependencyStatus{Status: StatusUnavailable}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[chk.name] = status
+			if status.Status != StatusOK {
+				report.Status = StatusUnavailable
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+// Live answers /healthz, the process is alive as long as it serves it.
+func Live(w http.ResponseWriter, r *http.Request) {
+	write(w, http.StatusOK, Report{Status: StatusOK})
+}
+
+// Ready answers /readyz with 200 when every dependency is reachable and 503
+// otherwise or once the service drains.
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	report := c.Check(r.Context())
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	write(w, status, report)
+}
+
+func write(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	// probes must see the current state
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	// a failed write means the prober went away, nothing to report
+	_ = json.NewEncoder(w).Encode(report)
+}
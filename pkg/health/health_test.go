@@ -0,0 +1,81 @@
+package health_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"redditclone/pkg/health"
+)
+
+func TestLive(t *testing.T) {
+	w := httptest.NewRecorder()
+	health.Live(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+}
+
+func TestReady(t *testing.T) {
+	checker := health.NewChecker()
+	checker.Timeout = 20 * time.Millisecond
+	checker.Add("mysql", func(ctx context.Context) error { return nil })
+
+	t.Run("all dependencies up", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		checker.Ready(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"status":"ok","checks":{"mysql":{"status":"ok"}}}`, w.Body.String())
+	})
+
+	t.Run("a dependency hangs", func(t *testing.T) {
+		checker.Add("mongo", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		w := httptest.NewRecorder()
+		checker.Ready(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.JSONEq(t, `{"status":"unavailable","checks":{
+			"mysql":{"status":"ok"},
+			"mongo":{"status":"unavailable"}
+		}}`, w.Body.String())
+	})
+
+	t.Run("errors are logged, not served", func(t *testing.T) {
+		var logs bytes.Buffer
+		checker := health.NewChecker()
+		checker.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+		checker.Add("mysql", func(ctx context.Context) error {
+			return errors.New("dial tcp 10.0.0.5:3306: connection refused")
+		})
+
+		w := httptest.NewRecorder()
+		checker.Ready(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.NotContains(t, w.Body.String(), "10.0.0.5")
+		assert.Contains(t, logs.String(), "dependency=mysql")
+		assert.Contains(t, logs.String(), "10.0.0.5:3306")
+	})
+
+	t.Run("draining", func(t *testing.T) {
+		checker := health.NewChecker()
+		checker.Add("mysql", func(ctx context.Context) error { return errors.New("unused") })
+		checker.Drain()
+
+		w := httptest.NewRecorder()
+		checker.Ready(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.JSONEq(t, `{"status":"shutting down"}`, w.Body.String())
+	})
+}
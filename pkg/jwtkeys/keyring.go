@@ -0,0 +1,443 @@
+package jwtkeys
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"redditclone/pkg/generator"
+	"redditclone/pkg/secretbox"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+
+	kidLen = 16
+	// unknown kids reload the keys from the store at most this often
+	reloadInterval = 10 * time.Second
+)
+
+// Key is a signing key. For HS256 Private and Public hold the same secret.
+type Key struct {
+	ID        string
+	Alg       string
+	Private   any
+	Public    any
+	CreatedAt time.Time
+	// ExpiresAt is when tokens signed with the key stop being accepted.
+	ExpiresAt time.Time
+}
+
+// StoredKey is a key as persisted, the private part sealed and PKCS #8 encoded.
+type StoredKey struct {
+	ID            string
+	Alg           string
+	SealedPrivate string
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+}
+
+type Store interface {
+	List(ctx context.Context) ([]StoredKey, error)
+	Create(ctx context.Context, k *StoredKey) error
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+// Keyring hands out the current signing key and every key still accepted
+// for verification. Asymmetric keys live in the Store so that all instances
+// share them, Rotate replaces the signing key every RotateEvery and keeps
+// the previous ones for Retain after that.
+type Keyring struct {
+	Alg   string
+	Store Store
+	Box   *secretbox.Box
+	// RotateEvery is how long a key is used for signing.
+	RotateEvery time.Duration
+	// Retain keeps a replaced key for verification, at least the token lifetime.
+	Retain time.Duration
+	// PublishAhead creates the next key that long before it is used, so that
+	// verifiers caching the JWKS learn it before the first token shows up.
+	PublishAhead time.Duration
+	Now          func() time.Time
+
+	mu       sync.RWMutex
+	keys     []*Key
+	loadedAt time.Time
+}
+
+// NewHMAC returns a keyring with a single shared secret and no public keys.
+func NewHMAC(secret []byte) *Keyring {
+	return &Keyring{
+		Alg: AlgHS256,
+		Now: time.Now,
+		keys: []*Key{{
+			Alg:     AlgHS256,
+			Private: secret,
+			Public:  secret,
+		}},
+	}
+}
+
+func NewKeyring(alg string, store Store, box *secretbox.Box) (*Keyring, error) {
+	if alg != AlgRS256 && alg != AlgEdDSA {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	return &Keyring{
+		Alg:          alg,
+		Store:        store,
+		Box:          box,
+		RotateEvery:  30 * 24 * time.Hour,
+		Retain:       2 * time.Hour,
+		PublishAhead: 10 * time.Minute,
+		Now:          time.Now,
+	}, nil
+}
+
+type Config struct {
+	// Alg is HS256, RS256 or EdDSA.
+	Alg string
+	// Secret signs HS256 tokens.
+	Secret string
+	// EncryptionKey seals the stored private keys of RS256 and EdDSA.
+	EncryptionKey string
+	RotateEvery   time.Duration
+	Retain        time.Duration
+}
+
+// ConfigFrom reads JWT_ALG, JWT_SECRET, JWT_KEY_ENCRYPTION_KEY,
+// JWT_ROTATE_EVERY and JWT_KEY_RETAIN through getenv, HS256 by default. The
+// error lists every bad setting.
+func ConfigFrom(getenv func(string) string) (Config, error) {
+	cfg := Config{
+		Alg:           getenv("JWT_ALG"),
+		Secret:        getenv("JWT_SECRET"),
+		EncryptionKey: getenv("JWT_KEY_ENCRYPTION_KEY"),
+		RotateEvery:   30 * 24 * time.Hour,
+		Retain:        2 * time.Hour,
+	}
+	if cfg.Alg == "" {
+		cfg.Alg = AlgHS256
+	}
+	var errs []error
+
+	switch cfg.Alg {
+	case AlgHS256:
+		if cfg.Secret == "" {
+			errs = append(errs, errors.New("JWT_SECRET is not set"))
+		}
+	case AlgRS256, AlgEdDSA:
+		if _, err := secretbox.New(cfg.EncryptionKey); err != nil {
+			errs = append(errs, fmt.Errorf("JWT_KEY_ENCRYPTION_KEY: %w", err))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unsupported JWT_ALG %q", cfg.Alg))
+	}
+
+	for _, d := range []struct {
+		env string
+		dst *time.Duration
+	}{
+		{"JWT_ROTATE_EVERY", &cfg.RotateEvery},
+		{"JWT_KEY_RETAIN", &cfg.Retain},
+	} {
+		v := getenv(d.env)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed <= 0 {
+			errs = append(errs, fmt.Errorf("bad %s %q", d.env, v))
+			continue
+		}
+		*d.dst = parsed
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// New builds the keyring cfg names. Asymmetric keys are stored in db and
+// rotated once right away.
+func New(ctx context.Context, cfg Config, db *sql.DB) (*Keyring, error) {
+	if cfg.Alg == AlgHS256 {
+		if cfg.Secret == "" {
+			return nil, errors.New("JWT_SECRET is not set")
+		}
+		return NewHMAC([]byte(cfg.Secret)), nil
+	}
+
+	box, err := secretbox.New(cfg.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("JWT_KEY_ENCRYPTION_KEY: %w", err)
+	}
+	k, err := NewKeyring(cfg.Alg, NewMySQLStore(db), box)
+	if err != nil {
+		return nil, err
+	}
+	k.RotateEvery = cfg.RotateEvery
+	k.Retain = cfg.Retain
+
+	if err := k.Rotate(ctx); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Signing returns the key new tokens are signed with, the newest one that
+// has been published for PublishAhead.
+func (k *Keyring) Signing() (*Key, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := k.Now()
+	var newest *Key
+	for _, key := range k.keys {
+		if key.Alg != k.Alg || !k.valid(key, now) {
+			continue
+		}
+		if newest == nil {
+			newest = key
+		}
+		if !key.CreatedAt.Add(k.PublishAhead).After(now) {
+			return key, nil
+		}
+	}
+	if newest == nil {
+		return nil, errors.New("no signing key")
+	}
+	// nothing is old enough yet, e.g. right after the first start
+	return newest, nil
+}
+
+// Verification returns the key a token names in its kid header. ctx bounds
+// the reload of an unknown kid.
+func (k *Keyring) Verification(ctx context.Context, kid string) (*Key, error) {
+	if key := k.find(kid); key != nil {
+		return key, nil
+	}
+
+	if k.Store == nil {
+		return nil, errors.New("unknown key")
+	}
+
+	k.mu.RLock()
+	recent := k.Now().Sub(k.loadedAt) < reloadInterval
+	k.mu.RUnlock()
+	if recent {
+		return nil, errors.New("unknown key")
+	}
+
+	// another instance may have rotated already
+	if err := k.Load(ctx); err != nil {
+		return nil, err
+	}
+	if key := k.find(kid); key != nil {
+		return key, nil
+	}
+	return nil, errors.New("unknown key")
+}
+
+func (k *Keyring) find(kid string) *Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := k.Now()
+	for _, key := range k.keys {
+		if key.ID == kid && k.valid(key, now) {
+			return key
+		}
+	}
+	return nil
+}
+
+func (k *Keyring) valid(key *Key, now time.Time) bool {
+	return key.ExpiresAt.IsZero() || now.Before(key.ExpiresAt)
+}
+
+// Keys returns the keys still accepted for verification, newest first.
+func (k *Keyring) Keys() []*Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := k.Now()
+	keys := make([]*Key, 0, len(k.keys))
+	for _, key := range k.keys {
+		if k.valid(key, now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Load replaces the keys in memory with the ones in the store.
+func (k *Keyring) Load(ctx context.Context) error {
+	stored, err := k.Store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]*Key, 0, len(stored))
+	for _, s := range stored {
+		key, err := k.open(s)
+		if err != nil {
+			return fmt.Errorf("key %s: %w", s.ID, err)
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+
+	k.mu.Lock()
+	k.keys = keys
+	k.loadedAt = k.Now()
+	k.mu.Unlock()
+	return nil
+}
+
+// Rotate adds the next key once the newest one is due to be replaced and
+// drops expired keys. Instances racing here at worst add one spare key.
+func (k *Keyring) Rotate(ctx context.Context) error {
+	if k.Store == nil {
+		return nil
+	}
+	if err := k.Load(ctx); err != nil {
+		return err
+	}
+
+	now := k.Now()
+	due := true
+	for _, key := range k.Keys() {
+		if key.Alg == k.Alg && key.CreatedAt.Add(k.RotateEvery-k.PublishAhead).After(now) {
+			due = false
+			break
+		}
+	}
+
+	if due {
+		stored, err := k.generate(now)
+		if err != nil {
+			return err
+		}
+		if err := k.Store.Create(ctx, stored); err != nil {
+			return err
+		}
+	}
+
+	if err := k.Store.DeleteExpired(ctx, now); err != nil {
+		return err
+	}
+	return k.Load(ctx)
+}
+
+// Start rotates in the background until stop is called. stop waits for a
+// running rotation to finish.
+func (k *Keyring) Start(interval time.Duration, logger *slog.Logger) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := k.Rotate(context.Background()); err != nil {
+					logger.Error("jwt key rotation", "error", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+func (k *Keyring) generate(now time.Time) (*StoredKey, error) {
+	var private crypto.Signer
+	var err error
+	switch k.Alg {
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key gen error: %s", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := k.Box.Seal(base64.StdEncoding.EncodeToString(der))
+	if err != nil {
+		return nil, err
+	}
+
+	kid, err := generator.GenerateRandomID(kidLen)
+	if err != nil {
+		return nil, fmt.Errorf("kid gen error: %s", err)
+	}
+
+	return &StoredKey{
+		ID:            kid,
+		Alg:           k.Alg,
+		SealedPrivate: sealed,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(k.RotateEvery + k.Retain),
+	}, nil
+}
+
+func (k *Keyring) open(s StoredKey) (*Key, error) {
+	encoded, err := k.Box.Open(s.SealedPrivate)
+	if err != nil {
+		return nil, err
+	}
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("not a signing key")
+	}
+	switch signer.(type) {
+	case *rsa.PrivateKey:
+		if s.Alg != AlgRS256 {
+			return nil, errors.New("algorithm does not match key")
+		}
+	case ed25519.PrivateKey:
+		if s.Alg != AlgEdDSA {
+			return nil, errors.New("algorithm does not match key")
+		}
+	default:
+		return nil, errors.New("unsupported key type")
+	}
+
+	return &Key{
+		ID:        s.ID,
+		Alg:       s.Alg,
+		Private:   signer,
+		Public:    signer.Public(),
+		CreatedAt: s.CreatedAt,
+		ExpiresAt: s.ExpiresAt,
+	}, nil
+}
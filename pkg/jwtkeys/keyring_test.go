@@ -0,0 +1,124 @@
+package jwtkeys_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"redditclone/pkg/jwtkeys"
+	"redditclone/pkg/secretbox"
+)
+
+const testBoxKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+var ctx = context.Background()
+
+func setupStore(t *testing.T) *jwtkeys.MySQLStore {
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`
+	CREATE TABLE jwt_keys (
+		kid TEXT PRIMARY KEY,
+		alg TEXT NOT NULL,
+		private_key TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL
+	);`)
+	assert.NoError(t, err)
+	return jwtkeys.NewMySQLStore(db)
+}
+
+func newKeyring(t *testing.T, alg string, store jwtkeys.Store, now *time.Time) *jwtkeys.Keyring {
+	box, err := secretbox.New(testBoxKey)
+	assert.NoError(t, err)
+
+	k, err := jwtkeys.NewKeyring(alg, store, box)
+	assert.NoError(t, err)
+	k.RotateEvery = 24 * time.Hour
+	k.Retain = 2 * time.Hour
+	k.PublishAhead = 10 * time.Minute
+	k.Now = func() time.Time { return *now }
+	return k
+}
+
+func TestKeyring_Keys(t *testing.T) {
+	for _, alg := range []string{jwtkeys.AlgRS256, jwtkeys.AlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			now := time.Now()
+			k := newKeyring(t, alg, setupStore(t), &now)
+			assert.NoError(t, k.Rotate(ctx))
+
+			key, err := k.Signing()
+			assert.NoError(t, err)
+			assert.Equal(t, alg, key.Alg)
+
+			found, err := k.Verification(ctx, key.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, key.Public, found.Public)
+
+			jwks := k.JWKS()
+			assert.Len(t, jwks.Keys, 1)
+			assert.Equal(t, key.ID, jwks.Keys[0].Kid)
+			assert.Equal(t, alg, jwks.Keys[0].Alg)
+		})
+	}
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	k := newKeyring(t, jwtkeys.AlgEdDSA, setupStore(t), &now)
+	assert.NoError(t, k.Rotate(ctx))
+
+	first, err := k.Signing()
+	assert.NoError(t, err)
+
+	// nothing is due yet
+	now = now.Add(12 * time.Hour)
+	assert.NoError(t, k.Rotate(ctx))
+	assert.Len(t, k.Keys(), 1)
+
+	// the next key is published ahead but not used yet
+	now = first.CreatedAt.Add(24*time.Hour - 10*time.Minute)
+	assert.NoError(t, k.Rotate(ctx))
+	assert.Len(t, k.JWKS().Keys, 2)
+	current, err := k.Signing()
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, current.ID)
+
+	now = now.Add(10 * time.Minute)
+	current, err = k.Signing()
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.ID, current.ID)
+
+	// tokens of the replaced key stay valid for Retain
+	_, err = k.Verification(ctx, first.ID)
+	assert.NoError(t, err)
+	now = now.Add(2 * time.Hour)
+	assert.NoError(t, k.Rotate(ctx))
+	_, err = k.Verification(ctx, first.ID)
+	assert.EqualError(t, err, "unknown key")
+	assert.Len(t, k.Keys(), 1)
+}
+
+func TestKeyring_SharedStore(t *testing.T) {
+	now := time.Now()
+	store := setupStore(t)
+	a := newKeyring(t, jwtkeys.AlgRS256, store, &now)
+	b := newKeyring(t, jwtkeys.AlgRS256, store, &now)
+
+	assert.NoError(t, a.Rotate(ctx))
+	key, err := a.Signing()
+	assert.NoError(t, err)
+
+	// b has never loaded the key, the unknown kid makes it look again
+	_, err = b.Verification(ctx, key.ID)
+	assert.NoError(t, err)
+}
+
+func TestKeyring_HMACPublishesNothing(t *testing.T) {
+	assert.Empty(t, jwtkeys.NewHMAC([]byte("secret")).JWKS().Keys)
+}
@@ -0,0 +1,54 @@
+package jwtkeys
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"redditclone/pkg/dbcall"
+)
+
+type MySQLStore struct {
+	DB *sql.DB
+}
+
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{DB: db}
+}
+
+func (s *MySQLStore) List(ctx context.Context) ([]StoredKey, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "jwt_keys", "List")
+	defer done()
+	rows, err := s.DB.QueryContext(ctx, "SELECT kid, alg, private_key, created_at, expires_at FROM jwt_keys")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []StoredKey
+	for rows.Next() {
+		var k StoredKey
+		if err := rows.Scan(&k.ID, &k.Alg, &k.SealedPrivate, &k.CreatedAt, &k.ExpiresAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (s *MySQLStore) Create(ctx context.Context, k *StoredKey) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "jwt_keys", "Create")
+	defer done()
+	_, err := s.DB.ExecContext(ctx,
+		"INSERT INTO jwt_keys (kid, alg, private_key, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		k.ID, k.Alg, k.SealedPrivate, k.CreatedAt.UTC(), k.ExpiresAt.UTC(),
+	)
+	return err
+}
+
+func (s *MySQLStore) DeleteExpired(ctx context.Context, now time.Time) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "jwt_keys", "DeleteExpired")
+	defer done()
+	_, err := s.DB.ExecContext(ctx, "DELETE FROM jwt_keys WHERE expires_at <= ?", now.UTC())
+	return err
+}
@@ -0,0 +1,36 @@
+// Package logctx carries the request ID and a logger tagged with it through
+// a request context.
+package logctx
+
+import (
+	"context"
+	"log/slog"
+)
+
+type contextKey string
+
+const (
+	loggerKey    contextKey = "logger"
+	requestIDKey contextKey = "request_id"
+)
+
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// From returns the logger of the request, or fallback outside of one.
+func From(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok && logger != nil {
+		return logger
+	}
+	return fallback
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
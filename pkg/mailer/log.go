@@ -0,0 +1,21 @@
+package mailer
+
+import (
+	"log/slog"
+)
+
+// LogMailer logs the recipient and subject of messages instead of sending
+// them. Bodies carry reset and verification tokens and are never logged, use
+// the file mailer to read them during development.
+type LogMailer struct {
+	Logger *slog.Logger
+}
+
+func NewLogMailer(logger *slog.Logger) *LogMailer {
+	return &LogMailer{Logger: logger}
+}
+
+func (m *LogMailer) Send(msg Message) error {
+	m.Logger.Info("mail", "to", msg.To, "subject", msg.Subject)
+	return nil
+}
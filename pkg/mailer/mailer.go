@@ -0,0 +1,72 @@
+package mailer
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(msg Message) error
+}
+
+type Config struct {
+	// Kind is "log", "file", "smtp" or "memory".
+	Kind string
+	// File is where the file mailer appends messages.
+	File string
+
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	From         string
+}
+
+// ConfigFrom reads MAILER, MAILER_FILE, SMTP_ADDR, SMTP_USERNAME,
+// SMTP_PASSWORD and MAIL_FROM through getenv. MAILER has no default so that
+// a deployment does not end up with mails only in its logs.
+func ConfigFrom(getenv func(string) string) (Config, error) {
+	cfg := Config{
+		Kind:         getenv("MAILER"),
+		File:         getenv("MAILER_FILE"),
+		SMTPAddr:     getenv("SMTP_ADDR"),
+		SMTPUsername: getenv("SMTP_USERNAME"),
+		SMTPPassword: getenv("SMTP_PASSWORD"),
+		From:         getenv("MAIL_FROM"),
+	}
+	if cfg.File == "" {
+		cfg.File = "mail.log"
+	}
+
+	switch cfg.Kind {
+	case "":
+		return Config{}, fmt.Errorf("MAILER is not set")
+	case "log", "file", "memory":
+	case "smtp":
+		if cfg.SMTPAddr == "" || cfg.From == "" {
+			return Config{}, fmt.Errorf("MAILER=smtp needs SMTP_ADDR and MAIL_FROM")
+		}
+	default:
+		return Config{}, fmt.Errorf("unknown MAILER %q", cfg.Kind)
+	}
+	return cfg, nil
+}
+
+// New builds the implementation named by cfg.Kind.
+func New(cfg Config, logger *slog.Logger) Mailer {
+	switch cfg.Kind {
+	case "file":
+		return NewFileMailer(cfg.File)
+	case "smtp":
+		return NewSMTPMailer(cfg.SMTPAddr, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From)
+	case "memory":
+		return NewMemoryMailer()
+	default:
+		return NewLogMailer(logger)
+	}
+}
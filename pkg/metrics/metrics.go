@@ -0,0 +1,114 @@
+// Package metrics holds the Prometheus collectors of the app and serves them
+// in the text exposition format.
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "redditclone"
+
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route template, method and status.",
+	}, []string{"route", "method", "status"})
+
+	HTTPDuration = promauto.With(Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route template, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	DBDuration = promauto.With(Registry).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_call_duration_seconds",
+		Help:      "Repository call latency by store, repository and method.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"store", "repo", "method"})
+
+	PostsCreated = promauto.With(Registry).NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "posts_created_total",
+		Help:      "Posts created.",
+	})
+
+	CommentsCreated = promauto.With(Registry).NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "comments_created_total",
+		Help:      "Comments added to posts.",
+	})
+
+	Votes = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "votes_total",
+		Help:      "Votes cast by direction: upvote, downvote or unvote.",
+	}, []string{"direction"})
+
+	Logins = promauto.With(Registry).NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts by method (password, 2fa, oidc) and result (success, failure, error).",
+	}, []string{"method", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// ObserveDB times a repository call, use as
+// defer metrics.ObserveDB("mysql", "users", "Create")().
+func ObserveDB(store, repo, method string) func() {
+	start := time.Now()
+	return func() {
+		DBDuration.WithLabelValues(store, repo, method).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RegisterActiveSessions exports the number of live sessions, counted on
+// every scrape.
+func RegisterActiveSessions(count func(ctx context.Context) (int64, error)) error {
+	return Registry.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_sessions",
+		Help:      "Sessions that have not expired.",
+	}, func() float64 {
+		n, err := count(context.Background())
+		if err != nil {
+			return math.NaN()
+		}
+		return float64(n)
+	}))
+}
+
+// Handler serves the registry. A non-empty token has to be sent as a bearer
+// token.
+func Handler(token string) http.Handler {
+	h := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+	if token == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
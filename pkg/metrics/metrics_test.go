@@ -0,0 +1,42 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"redditclone/pkg/metrics"
+)
+
+func TestHandler(t *testing.T) {
+	metrics.PostsCreated.Inc()
+	metrics.ObserveDB("mysql", "users", "FindByID")()
+	active := int64(3)
+	assert.NoError(t, metrics.RegisterActiveSessions(func(context.Context) (int64, error) { return active, nil }))
+
+	h := metrics.Handler("s3cret")
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	body := rr.Body.String()
+	assert.Contains(t, body, "redditclone_posts_created_total 1")
+	assert.Contains(t, body, `redditclone_db_call_duration_seconds_count{method="FindByID",repo="users",store="mysql"} 1`)
+	assert.Contains(t, body, "redditclone_active_sessions 3")
+	assert.Contains(t, body, "go_goroutines")
+
+	// a second registration is refused
+	assert.Error(t, metrics.RegisterActiveSessions(func(context.Context) (int64, error) { return 0, errors.New("x") }))
+	assert.True(t, strings.HasPrefix(rr.Header().Get("Content-Type"), "text/plain"))
+}
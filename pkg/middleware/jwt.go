@@ -2,86 +2,148 @@ package middleware
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
 	"net/http"
-	"os"
 	"strings"
 
+	"redditclone/pkg/apitoken"
+	"redditclone/pkg/apperr"
 	"redditclone/pkg/claims"
+	"redditclone/pkg/dbcall"
+	"redditclone/pkg/logctx"
+	"redditclone/pkg/post"
 	"redditclone/pkg/session"
+	"redditclone/pkg/token"
 
-	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/mux"
 )
 
-const category string = "music|funny|videos|programming|news|fashion"
+var category = strings.Join(post.Categories, "|")
 
+// noSessUrls maps the templates of the routes open without a token to their
+// method. It must list the operations marked public in the OpenAPI document,
+// a test in internal/routing compares them.
 var (
 	noSessUrls = map[string]string{
-		"/api/login":                       http.MethodPost,
-		"/api/register":                    http.MethodPost,
-		"/api":                             http.MethodGet,
-		"/api/posts/":                      http.MethodGet,
-		"/api/post/{post_id:[a-zA-Z0-9]+}": http.MethodGet,
-		"/api/user/{login:[a-zA-Z0-9]+}":   http.MethodGet,
+		"/api/login":                                 http.MethodPost,
+		"/api/login/2fa":                             http.MethodPost,
+		"/api/register":                              http.MethodPost,
+		"/api/oidc/login":                            http.MethodGet,
+		"/api/oidc/callback":                         http.MethodGet,
+		"/api/password/reset":                        http.MethodPost,
+		"/api/password/reset/confirm":                http.MethodPost,
+		"/api/email/verify":                          http.MethodPost,
+		"/api/openapi.json":                          http.MethodGet,
+		"/api/docs":                                  http.MethodGet,
+		"/api/posts/":                                http.MethodGet,
+		"/api/post/{post_id:[a-zA-Z0-9]+}":           http.MethodGet,
+		"/api/user/{login:[a-zA-Z0-9]+}":             http.MethodGet,
 		"/api/posts/{category:(?:" + category + ")}": http.MethodGet,
+
+		"/api/v2/login":                            http.MethodPost,
+		"/api/v2/login/2fa":                        http.MethodPost,
+		"/api/v2/register":                         http.MethodPost,
+		"/api/v2/password/reset":                   http.MethodPost,
+		"/api/v2/password/reset/confirm":           http.MethodPost,
+		"/api/v2/email/verify":                     http.MethodPost,
+		"/api/v2/posts":                            http.MethodGet,
+		"/api/v2/posts/{post_id:[0-9a-f]{24}}":     http.MethodGet,
+		"/api/v2/users/{login:[a-zA-Z0-9]+}/posts": http.MethodGet,
 	}
 )
 
-func CheckJWT(sessionStore *session.MySQLSessionRepo) func(http.Handler) http.Handler {
+// PublicRoutes returns a copy of the route templates CheckJWT lets through
+// without a token, with their method.
+func PublicRoutes() map[string]string {
+	return maps.Clone(noSessUrls)
+}
+
+// APITokenVerifier checks personal API tokens, which are looked up in the
+// database.
+type APITokenVerifier interface {
+	Verify(ctx context.Context, raw string) (*claims.Claims, error)
+}
+
+func CheckJWT(sessionStore session.Repository, verifier token.Verifier, apiTokens APITokenVerifier) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			route := mux.CurrentRoute(r)
+			if route == nil {
+				apperr.Write(w, http.StatusNotFound, "not found")
+				return
+			}
 			template, err := route.GetPathTemplate()
-
 			if err != nil {
-				http.Error(w, "Route not found", http.StatusNotFound)
+				apperr.Write(w, http.StatusNotFound, "not found")
 				return
 			}
 
 			if method, ok := noSessUrls[template]; ok && method == r.Method {
+				// public routes still learn who is asking when a valid token is sent,
+				// so per-viewer filtering (blocks, mutes) can apply
+				if _claims_, err := parseClaims(r, sessionStore, verifier, apiTokens); err == nil {
+					r = r.WithContext(withClaims(r.Context(), _claims_))
+				}
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			auth := r.Header.Get("Authorization")
-			if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
-				log.Println("1")
-				http.Error(w, `{"message":"unauthorized"}`, http.StatusUnauthorized)
+			_claims_, err := parseClaims(r, sessionStore, verifier, apiTokens)
+			if err != nil {
+				logger := logctx.From(r.Context(), slog.Default())
+				if _, ok := dbcall.Status(err); ok {
+					apperr.WriteError(w, logger, "session check", err)
+					return
+				}
+				logger.Info("unauthorized", "error", err)
+				apperr.Write(w, http.StatusUnauthorized, "unauthorized")
 				return
 			}
 
-			token := strings.TrimPrefix(auth, "Bearer ")
+			next.ServeHTTP(w, r.WithContext(withClaims(r.Context(), _claims_)))
+		})
+	}
+}
+
+// withClaims stores the claims and tags the request logger and the access
+// log with the user.
+func withClaims(ctx context.Context, c *claims.Claims) context.Context {
+	setAccessUser(ctx, c.User.ID)
+	ctx = logctx.WithLogger(ctx, logctx.From(ctx, slog.Default()).With("user", c.User.ID))
+	return context.WithValue(ctx, claims.TokenContextKey, c)
+}
 
-			hashSecretGetter := func(token *jwt.Token) (interface{}, error) {
-				method, ok := token.Method.(*jwt.SigningMethodHMAC)
-				if !ok || method.Alg() != "HS256" {
-					http.Error(w, "bad sign method", http.StatusUnauthorized)
-					return nil, nil
-				}
-				JWTSecret := os.Getenv("JWT_SECRET")
-				return []byte(JWTSecret), nil
-			}
+func parseClaims(r *http.Request, sessionStore session.Repository, verifier token.Verifier, apiTokens APITokenVerifier) (*claims.Claims, error) {
+	auth := r.Header.Get("Authorization")
+	if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
+		return nil, errors.New("missing bearer token")
+	}
 
-			_claims_ := &claims.Claims{}
+	raw := strings.TrimPrefix(auth, "Bearer ")
 
-			_token_, err := jwt.ParseWithClaims(token, _claims_, hashSecretGetter)
-			if err != nil || !_token_.Valid || _claims_.User.Username == "" {
-				log.Println("_token_.Valid", _token_.Valid)
-				http.Error(w, `{"message":"unauthorized"}`, http.StatusUnauthorized)
-				return
-			}
+	// personal tokens live on their own and do not need a login session
+	if strings.HasPrefix(raw, apitoken.Prefix) {
+		if apiTokens == nil {
+			return nil, errors.New("invalid token")
+		}
+		return apiTokens.Verify(r.Context(), raw)
+	}
 
-			ok, err := sessionStore.IsValid(_claims_.User.ID)
-			if err != nil || !ok {
-				log.Println("2", _claims_.User.ID, _claims_.User.Username)
-				log.Println(ok, err)
-				http.Error(w, `{"message":"unauthorized"}`, http.StatusUnauthorized)
-				return
-			}
+	_claims_, err := verifier.Verify(r.Context(), raw)
+	if err != nil {
+		return nil, err
+	}
 
-			ctx := context.WithValue(r.Context(), claims.TokenContextKey, _claims_)
-			next.ServeHTTP(w, r.WithContext(ctx))
-		})
+	ok, err := sessionStore.IsValid(r.Context(), _claims_.User.ID, _claims_.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("no valid session for %s: %w", _claims_.User.ID, err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w for %s", session.ErrInvalid, _claims_.User.ID)
 	}
+
+	return _claims_, nil
 }
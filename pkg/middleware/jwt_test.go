@@ -0,0 +1,92 @@
+package middleware_test
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"redditclone/pkg/audit"
+	"redditclone/pkg/jwtkeys"
+	"redditclone/pkg/middleware"
+	"redditclone/pkg/session"
+	"redditclone/pkg/token"
+	"redditclone/pkg/user"
+)
+
+func TestCheckJWT_PasswordChangeEndsOtherSessions(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Exec(`
+	CREATE TABLE users (
+		id TEXT PRIMARY KEY,
+		username TEXT NOT NULL UNIQUE,
+		password TEXT NOT NULL,
+		email TEXT NULL UNIQUE,
+		verified BOOLEAN NOT NULL DEFAULT FALSE,
+		totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+		role TEXT NOT NULL DEFAULT 'user'
+	);
+	CREATE TABLE sessions (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		created_at DATETIME,
+		expires_at DATETIME
+	);`)
+	assert.NoError(t, err)
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("oldpassword"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	repo := user.NewMySQLRepo(db)
+	assert.NoError(t, repo.Create(ctx, &user.User{ID: "uid", Username: "bob", Password: string(hashed), Role: user.RoleUser}))
+
+	sessions := session.NewMySQLSessionRepo(db)
+	tokens := token.NewJWT(jwtkeys.NewHMAC([]byte("secret")))
+	issue := func(u *user.User) string {
+		raw, err := tokens.Issue(u.Username, u.ID, u.SessionID)
+		assert.NoError(t, err)
+		return raw
+	}
+
+	r := mux.NewRouter()
+	r.HandleFunc("/api/blocks", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+	r.Use(middleware.CheckJWT(sessions, tokens, nil))
+	status := func(raw string) int {
+		req := httptest.NewRequest("GET", "/api/blocks", nil)
+		req.Header.Set("Authorization", "Bearer "+raw)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// bob is logged in on a laptop and a stolen phone
+	users := user.NewService(repo, sessions)
+	laptop, err := users.Login(ctx, "bob", "oldpassword", "10.0.0.1")
+	assert.NoError(t, err)
+	laptopToken := issue(laptop)
+	phone, err := users.Login(ctx, "bob", "oldpassword", "10.0.0.2")
+	assert.NoError(t, err)
+	phoneToken := issue(phone)
+	assert.Equal(t, http.StatusOK, status(phoneToken))
+
+	passwords := user.NewPasswordService(repo, sessions, nil, nil, audit.Nop{})
+	changed, err := passwords.ChangePassword(ctx, "uid", "oldpassword", "newpassword")
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, status(issue(changed)))
+	assert.Equal(t, http.StatusUnauthorized, status(laptopToken))
+	assert.Equal(t, http.StatusUnauthorized, status(phoneToken))
+
+	// logging in again does not bring the old tokens back
+	_, err = users.Login(ctx, "bob", "newpassword", "10.0.0.2")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status(phoneToken))
+}
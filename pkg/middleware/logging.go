@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"redditclone/pkg/generator"
+	"redditclone/pkg/logctx"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// incoming IDs are kept when a proxy already assigned a sane one
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID tags every request with an ID, echoed in the response, and puts
+// a logger carrying it into the context.
+func RequestID(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID.MatchString(id) {
+				var err error
+				if id, err = generator.GenerateRandomID(16); err != nil {
+					logger.Error("request id", "error", err)
+					id = "unknown"
+				}
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			requestLogger := logger.With("request_id", id)
+			if span := trace.SpanContextFromContext(r.Context()); span.IsValid() {
+				requestLogger = requestLogger.With("trace_id", span.TraceID().String())
+			}
+
+			ctx := logctx.WithRequestID(r.Context(), id)
+			ctx = logctx.WithLogger(ctx, requestLogger)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+type accessKey struct{}
+
+// accessEntry lets middleware further down report who made the request, the
+// access log only sees its own copy of the context.
+type accessEntry struct {
+	userID string
+}
+
+func setAccessUser(ctx context.Context, userID string) {
+	if entry, ok := ctx.Value(accessKey{}).(*accessEntry); ok {
+		entry.userID = userID
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += n
+	return n, err
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// AccessLog writes one line per request. Must run after RequestID.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &accessEntry{}
+		rec := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), accessKey{}, entry)))
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		logctx.From(r.Context(), slog.Default()).Info("access",
+			"method", r.Method,
+			"route", route,
+			"status", rec.status,
+			"latency", time.Since(start),
+			"bytes", rec.bytes,
+			"user", entry.userID,
+		)
+	})
+}
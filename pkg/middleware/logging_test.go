@@ -0,0 +1,123 @@
+package middleware_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"redditclone/pkg/claims"
+	"redditclone/pkg/logctx"
+	"redditclone/pkg/middleware"
+)
+
+type stubVerifier struct{}
+
+func (stubVerifier) Verify(ctx context.Context, raw string) (*claims.Claims, error) {
+	if raw != "good" {
+		return nil, errors.New("invalid token")
+	}
+	c := &claims.Claims{}
+	c.User.ID = "uid"
+	c.User.Username = "alice"
+	return c, nil
+}
+
+type stubSessions struct{}
+
+func (stubSessions) Create(ctx context.Context, userID, sessionID string) (string, error) {
+	return sessionID, nil
+}
+func (stubSessions) IsValid(ctx context.Context, userID, sessionID string) (bool, error) {
+	return true, nil
+}
+func (stubSessions) Invalidate(ctx context.Context, userID string) error       { return nil }
+func (stubSessions) Touch(ctx context.Context, userID, sessionID string) error { return nil }
+
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func TestRequestIDAndAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	r := mux.NewRouter()
+	r.Use(middleware.RequestID(logger))
+	r.Use(middleware.AccessLog)
+	api := r.PathPrefix("/api").Subrouter()
+	api.Use(middleware.CheckJWT(stubSessions{}, stubVerifier{}, nil))
+	api.HandleFunc("/post/{post_id:[a-zA-Z0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		logctx.From(r.Context(), nil).Info("handler")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}).Methods("POST")
+
+	t.Run("authenticated", func(t *testing.T) {
+		buf.Reset()
+		req := httptest.NewRequest("POST", "/api/post/abc", nil)
+		req.Header.Set("Authorization", "Bearer good")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		id := rr.Header().Get(middleware.RequestIDHeader)
+		assert.Len(t, id, 16)
+
+		lines := logLines(t, &buf)
+		assert.Len(t, lines, 2)
+		assert.Equal(t, "handler", lines[0]["msg"])
+		assert.Equal(t, id, lines[0]["request_id"])
+		assert.Equal(t, "uid", lines[0]["user"])
+
+		access := lines[1]
+		assert.Equal(t, "access", access["msg"])
+		assert.Equal(t, id, access["request_id"])
+		assert.Equal(t, "POST", access["method"])
+		assert.Equal(t, "/api/post/{post_id:[a-zA-Z0-9]+}", access["route"])
+		assert.Equal(t, float64(http.StatusCreated), access["status"])
+		assert.Equal(t, float64(5), access["bytes"])
+		assert.Equal(t, "uid", access["user"])
+		assert.Contains(t, access, "latency")
+	})
+
+	t.Run("unauthorized keeps the incoming id", func(t *testing.T) {
+		buf.Reset()
+		req := httptest.NewRequest("POST", "/api/post/abc", nil)
+		req.Header.Set(middleware.RequestIDHeader, "edge-42")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, "edge-42", rr.Header().Get(middleware.RequestIDHeader))
+
+		lines := logLines(t, &buf)
+		access := lines[len(lines)-1]
+		assert.Equal(t, "edge-42", access["request_id"])
+		assert.Equal(t, float64(http.StatusUnauthorized), access["status"])
+		assert.Equal(t, "", access["user"])
+	})
+
+	t.Run("malformed incoming id is replaced", func(t *testing.T) {
+		buf.Reset()
+		req := httptest.NewRequest("POST", "/api/post/abc", nil)
+		req.Header.Set(middleware.RequestIDHeader, strings.Repeat("x", 65))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Len(t, rr.Header().Get(middleware.RequestIDHeader), 16)
+	})
+}
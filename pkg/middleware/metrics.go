@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"redditclone/pkg/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+// Metrics counts and times requests by route template, so IDs in the path
+// do not blow up the label space.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		status, method := strconv.Itoa(rec.status), methodLabel(r.Method)
+		metrics.HTTPRequests.WithLabelValues(route, method, status).Inc()
+		metrics.HTTPDuration.WithLabelValues(route, method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// methodLabel keeps made up methods sent by clients out of the label space.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
@@ -0,0 +1,42 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"redditclone/pkg/metrics"
+	"redditclone/pkg/middleware"
+)
+
+func TestMetrics(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(middleware.Metrics)
+	r.HandleFunc("/api/post/{post_id:[a-zA-Z0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["post_id"] == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}).Methods("GET")
+
+	for _, id := range []string{"a", "b", "missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/post/"+id, nil))
+	}
+
+	route := "/api/post/{post_id:[a-zA-Z0-9]+}"
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(route, "GET", "200")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(route, "GET", "404")))
+}
+
+func TestMetrics_UnknownMethod(t *testing.T) {
+	handler := middleware.Metrics(http.NotFoundHandler())
+
+	for _, method := range []string{"FOO", "BAR", "get"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/nowhere", nil))
+	}
+
+	assert.Equal(t, float64(3), testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("unmatched", "OTHER", "404")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("unmatched", "FOO", "404")))
+}
@@ -1,17 +1,20 @@
 package middleware
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"runtime/debug"
+
+	"redditclone/pkg/apperr"
+	"redditclone/pkg/logctx"
 )
 
 func Panic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				log.Println("Panic recover:", string(debug.Stack()))
-				http.Error(w, "Internal server error", 500)
+				logctx.From(r.Context(), slog.Default()).Error("panic recovered", "error", err, "stack", string(debug.Stack()))
+				apperr.Write(w, http.StatusInternalServerError, "internal error")
 			}
 		}()
 		next.ServeHTTP(w, r)
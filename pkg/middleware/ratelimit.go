@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"redditclone/pkg/apperr"
+	"redditclone/pkg/claims"
+	"redditclone/pkg/clientip"
+	"redditclone/pkg/ratelimit"
+
+	"github.com/gorilla/mux"
+)
+
+// RateLimit applies the policy registered for the current route name. Every
+// request is counted against the client IP, authenticated ones also against
+// the user ID, so must run after CheckJWT.
+func RateLimit(store ratelimit.Store, policies map[string]ratelimit.Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := mux.CurrentRoute(r)
+			if route == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			name := route.GetName()
+			policy, ok := policies[name]
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			keys := []string{"ip:" + name + ":" + clientip.From(r)}
+			if c, ok := r.Context().Value(claims.TokenContextKey).(*claims.Claims); ok && c != nil {
+				keys = append(keys, "user:"+name+":"+c.User.ID)
+			}
+
+			// stop at the first denial, a refused request must not drain the
+			// other bucket
+			for _, key := range keys {
+				if allowed, retry := store.Take(key, policy); !allowed {
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+					apperr.Write(w, http.StatusTooManyRequests, "too many requests")
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
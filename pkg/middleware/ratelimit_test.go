@@ -0,0 +1,59 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"redditclone/pkg/claims"
+	"redditclone/pkg/middleware"
+	"redditclone/pkg/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+	now := time.Unix(0, 0)
+	store := ratelimit.NewMemoryStoreWithClock(func() time.Time { return now })
+
+	r := mux.NewRouter()
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	r.HandleFunc("/login", ok).Methods("POST").Name("login")
+	r.HandleFunc("/posts", ok).Methods("GET")
+	r.Use(middleware.RateLimit(store, map[string]ratelimit.Policy{
+		"login": {Burst: 1, Per: 30 * time.Second},
+	}))
+
+	alice := &claims.Claims{}
+	alice.User.ID = "alice"
+	send := func(method, path, ip string, c *claims.Claims) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		if c != nil {
+			req = req.WithContext(context.WithValue(req.Context(), claims.TokenContextKey, c))
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// routes without a policy are never limited
+	for range 3 {
+		assert.Equal(t, http.StatusOK, send("GET", "/posts", "10.0.0.1", nil).Code)
+	}
+
+	assert.Equal(t, http.StatusOK, send("POST", "/login", "10.0.0.1", nil).Code)
+	rr := send("POST", "/login", "10.0.0.1", alice)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "30", rr.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"code":"too_many_requests","message":"too many requests"}`, rr.Body.String())
+
+	// the request refused by the IP bucket left the bucket of alice full
+	assert.Equal(t, http.StatusOK, send("POST", "/login", "10.0.0.2", alice).Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("POST", "/login", "10.0.0.3", alice).Code)
+
+	now = now.Add(30 * time.Second)
+	assert.Equal(t, http.StatusOK, send("POST", "/login", "10.0.0.1", nil).Code)
+}
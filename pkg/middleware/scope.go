@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+
+	"redditclone/pkg/apperr"
+	"redditclone/pkg/claims"
+
+	"github.com/gorilla/mux"
+)
+
+// RequireScope limits requests made with personal API tokens to the routes
+// their scopes cover. scopes maps route names to the scope they need, GET
+// routes not listed need readScope and any other route is closed to them.
+// Session tokens pass untouched. Must run after CheckJWT.
+func RequireScope(scopes map[string]string, readScope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c, ok := r.Context().Value(claims.TokenContextKey).(*claims.Claims)
+			if !ok || c == nil || c.APIToken == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var name string
+			if route := mux.CurrentRoute(r); route != nil {
+				name = route.GetName()
+			}
+			scope, listed := scopes[name]
+			if !listed && r.Method == http.MethodGet {
+				scope, listed = readScope, true
+			}
+
+			if !listed || !c.Allows(scope) {
+				apperr.WriteBody(w, http.StatusForbidden, apperr.Body{Code: "insufficient_scope", Message: "insufficient scope"})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"redditclone/pkg/claims"
+	"redditclone/pkg/middleware"
+)
+
+func TestRequireScope(t *testing.T) {
+	r := mux.NewRouter()
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	r.HandleFunc("/posts", ok).Methods("POST").Name("posts")
+	r.HandleFunc("/posts", ok).Methods("GET")
+	r.HandleFunc("/vote", ok).Methods("GET").Name("vote")
+	r.HandleFunc("/password", ok).Methods("POST").Name("password")
+	r.Use(middleware.RequireScope(map[string]string{
+		"posts": "posts:write",
+		"vote":  "votes:write",
+	}, "read"))
+
+	bot := &claims.Claims{APIToken: "tok", Scopes: []string{"posts:write"}}
+	session := &claims.Claims{}
+
+	tests := []struct {
+		name   string
+		claims *claims.Claims
+		method string
+		path   string
+		status int
+	}{
+		{"scope granted", bot, "POST", "/posts", http.StatusOK},
+		{"read needs read scope", bot, "GET", "/posts", http.StatusForbidden},
+		{"listed GET uses its own scope", bot, "GET", "/vote", http.StatusForbidden},
+		{"unlisted routes are closed", bot, "POST", "/password", http.StatusForbidden},
+		{"session tokens are not limited", session, "POST", "/password", http.StatusOK},
+		{"anonymous requests pass", nil, "GET", "/posts", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.claims != nil {
+				req = req.WithContext(context.WithValue(req.Context(), claims.TokenContextKey, tt.claims))
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			assert.Equal(t, tt.status, rr.Code)
+		})
+	}
+}
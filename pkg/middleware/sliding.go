@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"redditclone/pkg/claims"
+	"redditclone/pkg/token"
+)
+
+// RefreshedTokenHeader carries a replacement token once the current one has
+// used up half of its lifetime.
+const RefreshedTokenHeader = "X-Refreshed-Token"
+
+type SessionToucher interface {
+	Touch(ctx context.Context, userID, sessionID string) error
+}
+
+// SlidingSession keeps active users signed in: it extends their session and
+// hands out a fresh token before the old one expires. Personal API tokens
+// are left alone. Must run after CheckJWT.
+func SlidingSession(sessions SessionToucher, tokens token.Issuer, ttl time.Duration, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c, ok := r.Context().Value(claims.TokenContextKey).(*claims.Claims)
+			if !ok || c == nil || c.APIToken != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err := sessions.Touch(r.Context(), c.User.ID, c.SessionID); err != nil {
+				logger.Error("session touch", "error", err, "user", c.User.ID)
+			}
+
+			if !c.ExpiresAt.IsZero() && time.Until(c.ExpiresAt) < ttl/2 {
+				refreshed, err := tokens.Issue(c.User.Username, c.User.ID, c.SessionID)
+				if err != nil {
+					logger.Error("token refresh", "error", err, "user", c.User.ID)
+				} else {
+					w.Header().Set(RefreshedTokenHeader, refreshed)
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package middleware_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"redditclone/pkg/claims"
+	"redditclone/pkg/middleware"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type touchedSessions []string
+
+func (t *touchedSessions) Touch(ctx context.Context, userID, sessionID string) error {
+	*t = append(*t, userID+"/"+sessionID)
+	return nil
+}
+
+type sidIssuer struct{}
+
+func (sidIssuer) Issue(username, userID, sessionID string) (string, error) {
+	return "token-" + sessionID, nil
+}
+
+func TestSlidingSession(t *testing.T) {
+	var touched touchedSessions
+	handler := middleware.SlidingSession(&touched, sidIssuer{}, time.Hour, slog.Default())(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	c := &claims.Claims{SessionID: "s1", ExpiresAt: time.Now().Add(10 * time.Minute)}
+	c.User.ID = "uid"
+	r := httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+	r = r.WithContext(context.WithValue(r.Context(), claims.TokenContextKey, c))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	// only the session of the token is extended, and the refreshed token keeps it
+	assert.Equal(t, touchedSessions{"uid/s1"}, touched)
+	assert.Equal(t, "token-s1", w.Header().Get(middleware.RefreshedTokenHeader))
+}
@@ -0,0 +1,84 @@
+package middleware_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"redditclone/pkg/dbcall"
+	"redditclone/pkg/logctx"
+	"redditclone/pkg/middleware"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	r := mux.NewRouter()
+	r.Use(middleware.Tracing)
+	r.Use(middleware.RequestID(logger))
+	r.HandleFunc("/api/post/{post_id}", func(w http.ResponseWriter, r *http.Request) {
+		_, done := dbcall.Start(r.Context(), "mongo", "posts", "GetByID")
+		done()
+		logctx.From(r.Context(), nil).Info("handler")
+		w.WriteHeader(http.StatusOK)
+	}).Methods("GET")
+	r.HandleFunc("/api/posts", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}).Methods("POST")
+
+	t.Run("continues the incoming trace", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/post/abc", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := recorder.Ended()
+		assert.Len(t, spans, 2)
+		db, server := spans[0], spans[1]
+
+		assert.Equal(t, "GET /api/post/{post_id}", server.Name())
+		assert.Equal(t, trace.SpanKindServer, server.SpanKind())
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+		assert.Contains(t, server.Attributes(), attribute.String("http.route", "/api/post/{post_id}"))
+		assert.Contains(t, server.Attributes(), attribute.Int("http.response.status_code", http.StatusOK))
+		assert.Equal(t, codes.Unset, server.Status().Code)
+
+		assert.Equal(t, "posts.GetByID", db.Name())
+		assert.Equal(t, trace.SpanKindClient, db.SpanKind())
+		assert.Equal(t, server.SpanContext().SpanID(), db.Parent().SpanID())
+
+		assert.Contains(t, buf.String(), `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`)
+	})
+
+	t.Run("server errors mark the span", func(t *testing.T) {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/posts", nil))
+
+		spans := recorder.Ended()
+		server := spans[len(spans)-1]
+		assert.Equal(t, "POST /api/posts", server.Name())
+		assert.False(t, server.Parent().IsValid())
+		assert.Equal(t, codes.Error, server.Status().Code)
+	})
+}
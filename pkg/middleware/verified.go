@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"redditclone/pkg/apperr"
+	"redditclone/pkg/claims"
+	"redditclone/pkg/logctx"
+
+	"github.com/gorilla/mux"
+)
+
+type VerifiedChecker interface {
+	IsVerified(ctx context.Context, userID string) (bool, error)
+}
+
+// ParseRoutes reads a comma separated list of route names.
+func ParseRoutes(list string) map[string]bool {
+	routes := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			routes[name] = true
+		}
+	}
+	return routes
+}
+
+// RequireVerified rejects authenticated users without a verified email on the
+// given route names. Must run after CheckJWT.
+func RequireVerified(checker VerifiedChecker, routes map[string]bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := mux.CurrentRoute(r)
+			if route == nil || !routes[route.GetName()] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			c, ok := r.Context().Value(claims.TokenContextKey).(*claims.Claims)
+			if !ok || c == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			verified, err := checker.IsVerified(r.Context(), c.User.ID)
+			if err != nil {
+				apperr.WriteError(w, logctx.From(r.Context(), slog.Default()), "verified check", err)
+				return
+			}
+			if !verified {
+				apperr.WriteBody(w, http.StatusForbidden, apperr.Body{Code: "email_not_verified", Message: "email not verified"})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
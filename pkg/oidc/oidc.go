@@ -0,0 +1,190 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"redditclone/pkg/apperr"
+	"redditclone/pkg/generator"
+)
+
+// ErrInvalidState is returned for unknown, used or expired login states.
+var ErrInvalidState = apperr.New(apperr.Invalid, "invalid_oidc_state", "invalid oidc state")
+
+const (
+	stateLen = 40
+	nonceLen = 32
+)
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// ConfigFrom reads the OIDC_* settings through getenv. It returns nil when
+// OIDC_ISSUER is not set, SSO is then disabled.
+func ConfigFrom(getenv func(string) string) (*Config, error) {
+	issuer := getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+
+	cfg := &Config{
+		Issuer:       issuer,
+		ClientID:     getenv("OIDC_CLIENT_ID"),
+		ClientSecret: getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  getenv("OIDC_REDIRECT_URL"),
+		Scopes:       []string{gooidc.ScopeOpenID, "profile", "email"},
+	}
+	if cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, fmt.Errorf("OIDC_ISSUER needs OIDC_CLIENT_ID and OIDC_REDIRECT_URL")
+	}
+	if scopes := getenv("OIDC_SCOPES"); scopes != "" {
+		cfg.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+	}
+	return cfg, nil
+}
+
+// Identity is what the provider asserts about the user in the ID token.
+type Identity struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+}
+
+// State keeps the secrets of a login in flight, it is looked up by the hash
+// of the state parameter and can be taken only once.
+type State struct {
+	StateHash string
+	Verifier  string
+	Nonce     string
+	ExpiresAt time.Time
+}
+
+type StateRepository interface {
+	Create(ctx context.Context, s *State) error
+	// Take returns the state and deletes it.
+	Take(ctx context.Context, stateHash string) (*State, error)
+}
+
+type Provider struct {
+	Issuer   string
+	States   StateRepository
+	StateTTL time.Duration
+	Now      func() time.Time
+
+	oauth    oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+}
+
+// NewProvider runs the discovery against the issuer.
+func NewProvider(ctx context.Context, cfg *Config, states StateRepository) (*Provider, error) {
+	provider, err := gooidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+
+	return &Provider{
+		Issuer:   cfg.Issuer,
+		States:   states,
+		StateTTL: 10 * time.Minute,
+		Now:      time.Now,
+		oauth: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       cfg.Scopes,
+		},
+		verifier: provider.Verifier(&gooidc.Config{ClientID: cfg.ClientID}),
+	}, nil
+}
+
+// Begin starts an authorization code flow with PKCE, the returned state has
+// to come back to Finish together with the code.
+func (p *Provider) Begin(ctx context.Context) (authURL, state string, err error) {
+	state, err = generator.GenerateRandomID(stateLen)
+	if err != nil {
+		return "", "", fmt.Errorf("state gen error: %s", err)
+	}
+	nonce, err := generator.GenerateRandomID(nonceLen)
+	if err != nil {
+		return "", "", fmt.Errorf("nonce gen error: %s", err)
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	if err := p.States.Create(ctx, &State{
+		StateHash: hashState(state),
+		Verifier:  verifier,
+		Nonce:     nonce,
+		ExpiresAt: p.Now().Add(p.StateTTL),
+	}); err != nil {
+		return "", "", err
+	}
+
+	authURL = p.oauth.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), gooidc.Nonce(nonce))
+	return authURL, state, nil
+}
+
+// Finish redeems the code and verifies the ID token.
+func (p *Provider) Finish(ctx context.Context, state, code string) (*Identity, error) {
+	st, err := p.States.Take(ctx, hashState(state))
+	if err != nil {
+		return nil, err
+	}
+	if !p.Now().Before(st.ExpiresAt) {
+		return nil, ErrInvalidState
+	}
+
+	token, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(st.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("oidc code exchange: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("oidc response has no id_token")
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("oidc id_token: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(st.Nonce)) != 1 {
+		return nil, errors.New("oidc id_token: nonce mismatch")
+	}
+
+	var claims struct {
+		Email             string `json:"email"`
+		EmailVerified     bool   `json:"email_verified"`
+		PreferredUsername string `json:"preferred_username"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("oidc id_token claims: %w", err)
+	}
+
+	return &Identity{
+		Issuer:            idToken.Issuer,
+		Subject:           idToken.Subject,
+		Email:             claims.Email,
+		EmailVerified:     claims.EmailVerified,
+		PreferredUsername: claims.PreferredUsername,
+	}, nil
+}
+
+func hashState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,124 @@
+package oidc_test
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"redditclone/pkg/oidc"
+	"redditclone/pkg/oidc/oidctest"
+)
+
+func setupProvider(t *testing.T) (*oidc.Provider, *oidctest.Server) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`
+	CREATE TABLE oidc_states (
+		state_hash TEXT PRIMARY KEY,
+		verifier TEXT NOT NULL,
+		nonce TEXT NOT NULL,
+		expires_at DATETIME NOT NULL
+	);`)
+	assert.NoError(t, err)
+
+	stub := oidctest.NewServer("client", "secret")
+	t.Cleanup(stub.Close)
+	stub.SetProfile(oidctest.Profile{
+		Subject:           "sub-1",
+		Email:             "alice@example.com",
+		EmailVerified:     true,
+		PreferredUsername: "alice",
+	})
+
+	provider, err := oidc.NewProvider(context.Background(), &oidc.Config{
+		Issuer:       stub.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "http://app.test/api/oidc/callback",
+		Scopes:       []string{"openid", "email"},
+	}, oidc.NewMySQLStateRepo(db))
+	assert.NoError(t, err)
+
+	return provider, stub
+}
+
+// authorize follows the auth URL to the stub and returns the callback query.
+func authorize(t *testing.T, authURL string) url.Values {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+	back, err := url.Parse(resp.Header.Get("Location"))
+	assert.NoError(t, err)
+	return back.Query()
+}
+
+func TestProvider_Flow(t *testing.T) {
+	provider, stub := setupProvider(t)
+
+	authURL, state, err := provider.Begin(context.Background())
+	assert.NoError(t, err)
+
+	parsed, err := url.Parse(authURL)
+	assert.NoError(t, err)
+	assert.Equal(t, "S256", parsed.Query().Get("code_challenge_method"))
+	assert.NotEmpty(t, parsed.Query().Get("nonce"))
+
+	callback := authorize(t, authURL)
+	assert.Equal(t, state, callback.Get("state"))
+
+	identity, err := provider.Finish(context.Background(), state, callback.Get("code"))
+	assert.NoError(t, err)
+	assert.Equal(t, &oidc.Identity{
+		Issuer:            stub.URL,
+		Subject:           "sub-1",
+		Email:             "alice@example.com",
+		EmailVerified:     true,
+		PreferredUsername: "alice",
+	}, identity)
+
+	// the state is single use
+	_, err = provider.Finish(context.Background(), state, callback.Get("code"))
+	assert.EqualError(t, err, "invalid oidc state")
+}
+
+func TestProvider_Rejects(t *testing.T) {
+	provider, stub := setupProvider(t)
+
+	_, err := provider.Finish(context.Background(), "unknown", "code")
+	assert.EqualError(t, err, "invalid oidc state")
+
+	// a code issued for another login does not match the PKCE verifier
+	authURL, _, err := provider.Begin(context.Background())
+	assert.NoError(t, err)
+	stolen := authorize(t, authURL).Get("code")
+	_, state, err := provider.Begin(context.Background())
+	assert.NoError(t, err)
+	_, err = provider.Finish(context.Background(), state, stolen)
+	assert.ErrorContains(t, err, "invalid_grant")
+
+	stub.ForceNonce("replayed")
+	authURL, state, err = provider.Begin(context.Background())
+	assert.NoError(t, err)
+	_, err = provider.Finish(context.Background(), state, authorize(t, authURL).Get("code"))
+	assert.EqualError(t, err, "oidc id_token: nonce mismatch")
+	stub.ForceNonce("")
+
+	now := time.Now()
+	provider.Now = func() time.Time { return now }
+	authURL, state, err = provider.Begin(context.Background())
+	assert.NoError(t, err)
+	now = now.Add(time.Hour)
+	_, err = provider.Finish(context.Background(), state, authorize(t, authURL).Get("code"))
+	assert.EqualError(t, err, "invalid oidc state")
+}
@@ -0,0 +1,63 @@
+package oidc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"redditclone/pkg/dbcall"
+)
+
+type MySQLStateRepo struct {
+	DB *sql.DB
+}
+
+func NewMySQLStateRepo(db *sql.DB) *MySQLStateRepo {
+	return &MySQLStateRepo{DB: db}
+}
+
+func (r *MySQLStateRepo) Create(ctx context.Context, s *State) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "oidc_states", "Create")
+	defer done()
+	_, err := r.DB.ExecContext(ctx,
+		"INSERT INTO oidc_states (state_hash, verifier, nonce, expires_at) VALUES (?, ?, ?, ?)",
+		s.StateHash, s.Verifier, s.Nonce, s.ExpiresAt.UTC(),
+	)
+	return err
+}
+
+func (r *MySQLStateRepo) Take(ctx context.Context, stateHash string) (*State, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "oidc_states", "Take")
+	defer done()
+	s := &State{StateHash: stateHash}
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT verifier, nonce, expires_at FROM oidc_states WHERE state_hash = ?",
+		stateHash,
+	).Scan(&s.Verifier, &s.Nonce, &s.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidState
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// only the caller that deletes the row gets to use it
+	res, err := r.DB.ExecContext(ctx, "DELETE FROM oidc_states WHERE state_hash = ?", stateHash)
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrInvalidState
+	}
+
+	// expired rows of abandoned logins go away with the next login
+	if _, err := r.DB.ExecContext(ctx, "DELETE FROM oidc_states WHERE expires_at < ?", time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
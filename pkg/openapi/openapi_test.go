@@ -0,0 +1,65 @@
+package openapi_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"redditclone/pkg/openapi"
+)
+
+func TestPath(t *testing.T) {
+	path, params := openapi.Path("/api/post/{post_id:[a-z0-9]{24}}/{action:(?:up|down)}/{raw}")
+
+	assert.Equal(t, "/api/post/{post_id}/{action}/{raw}", path)
+	assert.Equal(t, []openapi.Parameter{
+		{Name: "post_id", In: "path", Required: true, Schema: &openapi.Schema{Type: "string", Pattern: "^[a-z0-9]{24}$"}},
+		{Name: "action", In: "path", Required: true, Schema: &openapi.Schema{Type: "string", Pattern: "^(?:up|down)$"}},
+		{Name: "raw", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}},
+	}, params)
+
+	path, params = openapi.Path("/api/posts/")
+	assert.Equal(t, "/api/posts/", path)
+	assert.Empty(t, params)
+}
+
+type node struct {
+	Name     string    `json:"name"`
+	Note     string    `json:"note,omitempty"`
+	Count    int64     `json:"count,string"`
+	Created  time.Time `json:"created"`
+	Parent   *node     `json:"parent"`
+	Children []node    `json:"children"`
+	Secret   string    `json:"-"`
+	hidden   string
+}
+
+func TestSchemas(t *testing.T) {
+	schemas := openapi.Schemas{}
+
+	ref := schemas.Of([]node{})
+	assert.Equal(t, &openapi.Schema{Type: "array", Items: &openapi.Schema{Ref: "#/components/schemas/openapi_test.node"}}, ref)
+
+	assert.Equal(t, &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"name":     {Type: "string"},
+			"note":     {Type: "string"},
+			"count":    {Type: "string"},
+			"created":  {Type: "string", Format: "date-time"},
+			"parent":   {Ref: "#/components/schemas/openapi_test.node"},
+			"children": {Type: "array", Items: &openapi.Schema{Ref: "#/components/schemas/openapi_test.node"}},
+		},
+		Required: []string{"name", "count", "created", "parent", "children"},
+	}, schemas["openapi_test.node"])
+
+	inline := schemas.Of(struct {
+		Token *string `json:"token"`
+	}{})
+	assert.Equal(t, &openapi.Schema{
+		Type:       "object",
+		Properties: map[string]*openapi.Schema{"token": {Type: "string", Nullable: true}},
+		Required:   []string{"token"},
+	}, inline)
+	assert.Len(t, schemas, 1)
+}
@@ -0,0 +1,52 @@
+package openapi
+
+import "strings"
+
+// Path turns a gorilla/mux path template into an OpenAPI path and its path
+// parameters, e.g. "/post/{post_id:[a-z0-9]+}" into "/post/{post_id}" with
+// a post_id parameter of pattern "^[a-z0-9]+$".
+func Path(template string) (string, []Parameter) {
+	var b strings.Builder
+	var params []Parameter
+	for {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			b.WriteString(template)
+			return b.String(), params
+		}
+		end := closingBrace(template, start)
+		if end < 0 {
+			b.WriteString(template)
+			return b.String(), params
+		}
+
+		name, pattern, _ := strings.Cut(template[start+1:end], ":")
+		schema := &Schema{Type: "string"}
+		if pattern != "" {
+			schema.Pattern = "^" + pattern + "$"
+		}
+		params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+
+		b.WriteString(template[:start])
+		b.WriteString("{" + name + "}")
+		template = template[end+1:]
+	}
+}
+
+// closingBrace returns the index of the brace closing the one at start, the
+// pattern of a variable may hold braces of its own, e.g. "{id:[0-9]{24}}".
+func closingBrace(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
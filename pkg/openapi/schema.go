@@ -0,0 +1,106 @@
+package openapi
+
+import (
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schemas are the component schemas of a document, keyed by the package and
+// name of the Go type, e.g. "post.Post".
+type Schemas map[string]*Schema
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// Of returns the schema of the JSON encoding of v. Named structs are added
+// to s and referenced, anonymous ones are described inline.
+func (s Schemas) Of(v any) *Schema {
+	return s.of(reflect.TypeOf(v))
+}
+
+func (s Schemas) of(t reflect.Type) *Schema {
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := s.of(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		name := path.Base(t.PkgPath()) + "." + t.Name()
+		if _, ok := s[name]; !ok {
+			// reserved first, a type may refer to itself
+			s[name] = &Schema{}
+			*s[name] = *s.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	// interfaces and anything else hold any value
+	return &Schema{}
+}
+
+// object follows the rules of encoding/json: unexported and "-" fields are
+// left out, fields without omitempty are required.
+func (s Schemas) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			embedded := s.object(f.Type)
+			for k, v := range embedded.Properties {
+				schema.Properties[k] = v
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		if strings.Contains(opts, "string") {
+			schema.Properties[name] = &Schema{Type: "string"}
+		} else {
+			schema.Properties[name] = s.of(f.Type)
+		}
+		if !strings.Contains(opts, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
@@ -0,0 +1,15 @@
+package post
+
+import "redditclone/pkg/apperr"
+
+var (
+	ErrNotFound      = apperr.New(apperr.NotFound, "post_not_found", "post not found")
+	ErrExists        = apperr.New(apperr.Conflict, "post_exists", "post already exists")
+	ErrInvalidID     = apperr.New(apperr.Invalid, "invalid_id", "invalid ID format")
+	ErrVoteNotFound  = apperr.New(apperr.NotFound, "vote_not_found", "vote not found")
+	ErrInvalidAction = apperr.New(apperr.Invalid, "invalid_action", "invalid action")
+	ErrNoVoter       = apperr.New(apperr.Invalid, "missing_username", "missing username")
+	ErrBlocked       = apperr.New(apperr.Forbidden, "blocked_by_author", "blocked by author")
+	ErrNotAuthor     = apperr.New(apperr.Forbidden, "not_author", "only the author can do this")
+	ErrNoComment     = apperr.New(apperr.NotFound, "comment_not_found", "comment not found")
+)
@@ -3,6 +3,7 @@
 package mocks
 
 import (
+	context "context"
 	post "redditclone/pkg/post"
 
 	mock "github.com/stretchr/testify/mock"
@@ -13,9 +14,9 @@ type RepoPost struct {
 	mock.Mock
 }
 
-// AddComment provides a mock function with given fields: postID, comment
-func (_m *RepoPost) AddComment(postID string, comment post.Comment) (*post.Post, error) {
-	ret := _m.Called(postID, comment)
+// AddComment provides a mock function with given fields: ctx, postID, comment
+func (_m *RepoPost) AddComment(ctx context.Context, postID string, comment post.Comment) (*post.Post, error) {
+	ret := _m.Called(ctx, postID, comment)
 
 	if len(ret) == 0 {
 		panic("no return value specified for AddComment")
@@ -23,19 +24,19 @@ func (_m *RepoPost) AddComment(postID string, comment post.Comment) (*post.Post,
 
 	var r0 *post.Post
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string, post.Comment) (*post.Post, error)); ok {
-		return rf(postID, comment)
+	if rf, ok := ret.Get(0).(func(context.Context, string, post.Comment) (*post.Post, error)); ok {
+		return rf(ctx, postID, comment)
 	}
-	if rf, ok := ret.Get(0).(func(string, post.Comment) *post.Post); ok {
-		r0 = rf(postID, comment)
+	if rf, ok := ret.Get(0).(func(context.Context, string, post.Comment) *post.Post); ok {
+		r0 = rf(ctx, postID, comment)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*post.Post)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string, post.Comment) error); ok {
-		r1 = rf(postID, comment)
+	if rf, ok := ret.Get(1).(func(context.Context, string, post.Comment) error); ok {
+		r1 = rf(ctx, postID, comment)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -43,9 +44,9 @@ func (_m *RepoPost) AddComment(postID string, comment post.Comment) (*post.Post,
 	return r0, r1
 }
 
-// AddVote provides a mock function with given fields: postID, vote
-func (_m *RepoPost) AddVote(postID string, vote post.Voting) (*post.Post, error) {
-	ret := _m.Called(postID, vote)
+// AddVote provides a mock function with given fields: ctx, postID, vote
+func (_m *RepoPost) AddVote(ctx context.Context, postID string, vote post.Voting) (*post.Post, error) {
+	ret := _m.Called(ctx, postID, vote)
 
 	if len(ret) == 0 {
 		panic("no return value specified for AddVote")
@@ -53,19 +54,19 @@ func (_m *RepoPost) AddVote(postID string, vote post.Voting) (*post.Post, error)
 
 	var r0 *post.Post
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string, post.Voting) (*post.Post, error)); ok {
-		return rf(postID, vote)
+	if rf, ok := ret.Get(0).(func(context.Context, string, post.Voting) (*post.Post, error)); ok {
+		return rf(ctx, postID, vote)
 	}
-	if rf, ok := ret.Get(0).(func(string, post.Voting) *post.Post); ok {
-		r0 = rf(postID, vote)
+	if rf, ok := ret.Get(0).(func(context.Context, string, post.Voting) *post.Post); ok {
+		r0 = rf(ctx, postID, vote)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*post.Post)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string, post.Voting) error); ok {
-		r1 = rf(postID, vote)
+	if rf, ok := ret.Get(1).(func(context.Context, string, post.Voting) error); ok {
+		r1 = rf(ctx, postID, vote)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -73,9 +74,9 @@ func (_m *RepoPost) AddVote(postID string, vote post.Voting) (*post.Post, error)
 	return r0, r1
 }
 
-// CancelVote provides a mock function with given fields: postID, user
-func (_m *RepoPost) CancelVote(postID string, user string) (*post.Post, error) {
-	ret := _m.Called(postID, user)
+// CancelVote provides a mock function with given fields: ctx, postID, user
+func (_m *RepoPost) CancelVote(ctx context.Context, postID string, user string) (*post.Post, error) {
+	ret := _m.Called(ctx, postID, user)
 
 	if len(ret) == 0 {
 		panic("no return value specified for CancelVote")
@@ -83,19 +84,19 @@ func (_m *RepoPost) CancelVote(postID string, user string) (*post.Post, error) {
 
 	var r0 *post.Post
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string, string) (*post.Post, error)); ok {
-		return rf(postID, user)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*post.Post, error)); ok {
+		return rf(ctx, postID, user)
 	}
-	if rf, ok := ret.Get(0).(func(string, string) *post.Post); ok {
-		r0 = rf(postID, user)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *post.Post); ok {
+		r0 = rf(ctx, postID, user)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*post.Post)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string, string) error); ok {
-		r1 = rf(postID, user)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, postID, user)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -103,17 +104,17 @@ func (_m *RepoPost) CancelVote(postID string, user string) (*post.Post, error) {
 	return r0, r1
 }
 
-// Create provides a mock function with given fields: _a0
-func (_m *RepoPost) Create(_a0 *post.Post) error {
-	ret := _m.Called(_a0)
+// Create provides a mock function with given fields: ctx, _a1
+func (_m *RepoPost) Create(ctx context.Context, _a1 *post.Post) error {
+	ret := _m.Called(ctx, _a1)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Create")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*post.Post) error); ok {
-		r0 = rf(_a0)
+	if rf, ok := ret.Get(0).(func(context.Context, *post.Post) error); ok {
+		r0 = rf(ctx, _a1)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -121,17 +122,17 @@ func (_m *RepoPost) Create(_a0 *post.Post) error {
 	return r0
 }
 
-// Delete provides a mock function with given fields: postID
-func (_m *RepoPost) Delete(postID string) error {
-	ret := _m.Called(postID)
+// Delete provides a mock function with given fields: ctx, postID
+func (_m *RepoPost) Delete(ctx context.Context, postID string) error {
+	ret := _m.Called(ctx, postID)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Delete")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		r0 = rf(postID)
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, postID)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -139,49 +140,99 @@ func (_m *RepoPost) Delete(postID string) error {
 	return r0
 }
 
-// GetAll provides a mock function with no fields
-func (_m *RepoPost) GetAll() []*post.Post {
-	ret := _m.Called()
+// FindByID provides a mock function with given fields: ctx, id
+func (_m *RepoPost) FindByID(ctx context.Context, id string) (*post.Post, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindByID")
+	}
+
+	var r0 *post.Post
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*post.Post, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *post.Post); ok {
+		r0 = rf(ctx, id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*post.Post)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAll provides a mock function with given fields: ctx
+func (_m *RepoPost) GetAll(ctx context.Context) ([]*post.Post, error) {
+	ret := _m.Called(ctx)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetAll")
 	}
 
 	var r0 []*post.Post
-	if rf, ok := ret.Get(0).(func() []*post.Post); ok {
-		r0 = rf()
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]*post.Post, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []*post.Post); ok {
+		r0 = rf(ctx)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*post.Post)
 		}
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
-// GetByCategory provides a mock function with given fields: category
-func (_m *RepoPost) GetByCategory(category string) []*post.Post {
-	ret := _m.Called(category)
+// GetByCategory provides a mock function with given fields: ctx, category
+func (_m *RepoPost) GetByCategory(ctx context.Context, category string) ([]*post.Post, error) {
+	ret := _m.Called(ctx, category)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetByCategory")
 	}
 
 	var r0 []*post.Post
-	if rf, ok := ret.Get(0).(func(string) []*post.Post); ok {
-		r0 = rf(category)
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]*post.Post, error)); ok {
+		return rf(ctx, category)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*post.Post); ok {
+		r0 = rf(ctx, category)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*post.Post)
 		}
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, category)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
-// GetByID provides a mock function with given fields: id
-func (_m *RepoPost) GetByID(id string) (*post.Post, error) {
-	ret := _m.Called(id)
+// GetByID provides a mock function with given fields: ctx, id
+func (_m *RepoPost) GetByID(ctx context.Context, id string) (*post.Post, error) {
+	ret := _m.Called(ctx, id)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetByID")
@@ -189,19 +240,19 @@ func (_m *RepoPost) GetByID(id string) (*post.Post, error) {
 
 	var r0 *post.Post
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (*post.Post, error)); ok {
-		return rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*post.Post, error)); ok {
+		return rf(ctx, id)
 	}
-	if rf, ok := ret.Get(0).(func(string) *post.Post); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, string) *post.Post); ok {
+		r0 = rf(ctx, id)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*post.Post)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(id)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -209,29 +260,76 @@ func (_m *RepoPost) GetByID(id string) (*post.Post, error) {
 	return r0, r1
 }
 
-// GetByUser provides a mock function with given fields: username
-func (_m *RepoPost) GetByUser(username string) []*post.Post {
-	ret := _m.Called(username)
+// GetByUser provides a mock function with given fields: ctx, userID
+func (_m *RepoPost) GetByUser(ctx context.Context, userID string) ([]*post.Post, error) {
+	ret := _m.Called(ctx, userID)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetByUser")
 	}
 
 	var r0 []*post.Post
-	if rf, ok := ret.Get(0).(func(string) []*post.Post); ok {
-		r0 = rf(username)
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]*post.Post, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*post.Post); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*post.Post)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPage provides a mock function with given fields: ctx, filter, skip, limit
+func (_m *RepoPost) GetPage(ctx context.Context, filter post.ListFilter, skip int, limit int) ([]*post.Post, int, error) {
+	ret := _m.Called(ctx, filter, skip, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPage")
+	}
+
+	var r0 []*post.Post
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, post.ListFilter, int, int) ([]*post.Post, int, error)); ok {
+		return rf(ctx, filter, skip, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, post.ListFilter, int, int) []*post.Post); ok {
+		r0 = rf(ctx, filter, skip, limit)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*post.Post)
 		}
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(context.Context, post.ListFilter, int, int) int); ok {
+		r1 = rf(ctx, filter, skip, limit)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, post.ListFilter, int, int) error); ok {
+		r2 = rf(ctx, filter, skip, limit)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
-// RemoveComment provides a mock function with given fields: postID, commentID
-func (_m *RepoPost) RemoveComment(postID string, commentID string) (*post.Post, error) {
-	ret := _m.Called(postID, commentID)
+// RemoveComment provides a mock function with given fields: ctx, postID, commentID
+func (_m *RepoPost) RemoveComment(ctx context.Context, postID string, commentID string) (*post.Post, error) {
+	ret := _m.Called(ctx, postID, commentID)
 
 	if len(ret) == 0 {
 		panic("no return value specified for RemoveComment")
@@ -239,19 +337,19 @@ func (_m *RepoPost) RemoveComment(postID string, commentID string) (*post.Post,
 
 	var r0 *post.Post
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string, string) (*post.Post, error)); ok {
-		return rf(postID, commentID)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*post.Post, error)); ok {
+		return rf(ctx, postID, commentID)
 	}
-	if rf, ok := ret.Get(0).(func(string, string) *post.Post); ok {
-		r0 = rf(postID, commentID)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *post.Post); ok {
+		r0 = rf(ctx, postID, commentID)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*post.Post)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string, string) error); ok {
-		r1 = rf(postID, commentID)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, postID, commentID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -259,6 +357,24 @@ func (_m *RepoPost) RemoveComment(postID string, commentID string) (*post.Post,
 	return r0, r1
 }
 
+// Restore provides a mock function with given fields: ctx, postID
+func (_m *RepoPost) Restore(ctx context.Context, postID string) error {
+	ret := _m.Called(ctx, postID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Restore")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, postID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewRepoPost creates a new instance of RepoPost. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewRepoPost(t interface {
@@ -3,6 +3,7 @@
 package mocks
 
 import (
+	context "context"
 	claims "redditclone/pkg/claims"
 
 	mock "github.com/stretchr/testify/mock"
@@ -15,9 +16,9 @@ type ServicePost struct {
 	mock.Mock
 }
 
-// AddComment provides a mock function with given fields: postID, comment, _a2
-func (_m *ServicePost) AddComment(postID string, comment string, _a2 *claims.Claims) (*post.Post, error) {
-	ret := _m.Called(postID, comment, _a2)
+// AddComment provides a mock function with given fields: ctx, postID, comment, _a3
+func (_m *ServicePost) AddComment(ctx context.Context, postID string, comment string, _a3 *claims.Claims) (*post.Post, error) {
+	ret := _m.Called(ctx, postID, comment, _a3)
 
 	if len(ret) == 0 {
 		panic("no return value specified for AddComment")
@@ -25,19 +26,19 @@ func (_m *ServicePost) AddComment(postID string, comment string, _a2 *claims.Cla
 
 	var r0 *post.Post
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string, string, *claims.Claims) (*post.Post, error)); ok {
-		return rf(postID, comment, _a2)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, *claims.Claims) (*post.Post, error)); ok {
+		return rf(ctx, postID, comment, _a3)
 	}
-	if rf, ok := ret.Get(0).(func(string, string, *claims.Claims) *post.Post); ok {
-		r0 = rf(postID, comment, _a2)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, *claims.Claims) *post.Post); ok {
+		r0 = rf(ctx, postID, comment, _a3)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*post.Post)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string, string, *claims.Claims) error); ok {
-		r1 = rf(postID, comment, _a2)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, *claims.Claims) error); ok {
+		r1 = rf(ctx, postID, comment, _a3)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -45,9 +46,9 @@ func (_m *ServicePost) AddComment(postID string, comment string, _a2 *claims.Cla
 	return r0, r1
 }
 
-// AddVote provides a mock function with given fields: postID, username, action
-func (_m *ServicePost) AddVote(postID string, username string, action string) (*post.Post, error) {
-	ret := _m.Called(postID, username, action)
+// AddVote provides a mock function with given fields: ctx, postID, username, action
+func (_m *ServicePost) AddVote(ctx context.Context, postID string, username string, action string) (*post.Post, error) {
+	ret := _m.Called(ctx, postID, username, action)
 
 	if len(ret) == 0 {
 		panic("no return value specified for AddVote")
@@ -55,19 +56,19 @@ func (_m *ServicePost) AddVote(postID string, username string, action string) (*
 
 	var r0 *post.Post
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string, string, string) (*post.Post, error)); ok {
-		return rf(postID, username, action)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) (*post.Post, error)); ok {
+		return rf(ctx, postID, username, action)
 	}
-	if rf, ok := ret.Get(0).(func(string, string, string) *post.Post); ok {
-		r0 = rf(postID, username, action)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) *post.Post); ok {
+		r0 = rf(ctx, postID, username, action)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*post.Post)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string, string, string) error); ok {
-		r1 = rf(postID, username, action)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string) error); ok {
+		r1 = rf(ctx, postID, username, action)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -75,17 +76,17 @@ func (_m *ServicePost) AddVote(postID string, username string, action string) (*
 	return r0, r1
 }
 
-// CreatePost provides a mock function with given fields: _a0, username, id
-func (_m *ServicePost) CreatePost(_a0 *post.Post, username string, id string) error {
-	ret := _m.Called(_a0, username, id)
+// CreatePost provides a mock function with given fields: ctx, _a1, username, id
+func (_m *ServicePost) CreatePost(ctx context.Context, _a1 *post.Post, username string, id string) error {
+	ret := _m.Called(ctx, _a1, username, id)
 
 	if len(ret) == 0 {
 		panic("no return value specified for CreatePost")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*post.Post, string, string) error); ok {
-		r0 = rf(_a0, username, id)
+	if rf, ok := ret.Get(0).(func(context.Context, *post.Post, string, string) error); ok {
+		r0 = rf(ctx, _a1, username, id)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -93,17 +94,17 @@ func (_m *ServicePost) CreatePost(_a0 *post.Post, username string, id string) er
 	return r0
 }
 
-// Delete provides a mock function with given fields: postID
-func (_m *ServicePost) Delete(postID string) error {
-	ret := _m.Called(postID)
+// Delete provides a mock function with given fields: ctx, postID
+func (_m *ServicePost) Delete(ctx context.Context, postID string) error {
+	ret := _m.Called(ctx, postID)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Delete")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		r0 = rf(postID)
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, postID)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -111,49 +112,87 @@ func (_m *ServicePost) Delete(postID string) error {
 	return r0
 }
 
-// GetAll provides a mock function with no fields
-func (_m *ServicePost) GetAll() []*post.Post {
-	ret := _m.Called()
+// DeleteAs provides a mock function with given fields: ctx, postID, userID
+func (_m *ServicePost) DeleteAs(ctx context.Context, postID string, userID string) error {
+	ret := _m.Called(ctx, postID, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteAs")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, postID, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetAll provides a mock function with given fields: ctx, viewerID
+func (_m *ServicePost) GetAll(ctx context.Context, viewerID string) ([]*post.Post, error) {
+	ret := _m.Called(ctx, viewerID)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetAll")
 	}
 
 	var r0 []*post.Post
-	if rf, ok := ret.Get(0).(func() []*post.Post); ok {
-		r0 = rf()
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]*post.Post, error)); ok {
+		return rf(ctx, viewerID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []*post.Post); ok {
+		r0 = rf(ctx, viewerID)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*post.Post)
 		}
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, viewerID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
-// GetByCategory provides a mock function with given fields: category
-func (_m *ServicePost) GetByCategory(category string) []*post.Post {
-	ret := _m.Called(category)
+// GetByCategory provides a mock function with given fields: ctx, category, viewerID
+func (_m *ServicePost) GetByCategory(ctx context.Context, category string, viewerID string) ([]*post.Post, error) {
+	ret := _m.Called(ctx, category, viewerID)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetByCategory")
 	}
 
 	var r0 []*post.Post
-	if rf, ok := ret.Get(0).(func(string) []*post.Post); ok {
-		r0 = rf(category)
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) ([]*post.Post, error)); ok {
+		return rf(ctx, category, viewerID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) []*post.Post); ok {
+		r0 = rf(ctx, category, viewerID)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*post.Post)
 		}
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, category, viewerID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
-// GetByID provides a mock function with given fields: id
-func (_m *ServicePost) GetByID(id string) (*post.Post, error) {
-	ret := _m.Called(id)
+// GetByID provides a mock function with given fields: ctx, id, viewerID
+func (_m *ServicePost) GetByID(ctx context.Context, id string, viewerID string) (*post.Post, error) {
+	ret := _m.Called(ctx, id, viewerID)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetByID")
@@ -161,19 +200,19 @@ func (_m *ServicePost) GetByID(id string) (*post.Post, error) {
 
 	var r0 *post.Post
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (*post.Post, error)); ok {
-		return rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*post.Post, error)); ok {
+		return rf(ctx, id, viewerID)
 	}
-	if rf, ok := ret.Get(0).(func(string) *post.Post); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *post.Post); ok {
+		r0 = rf(ctx, id, viewerID)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*post.Post)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(id)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, id, viewerID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -181,29 +220,76 @@ func (_m *ServicePost) GetByID(id string) (*post.Post, error) {
 	return r0, r1
 }
 
-// GetByUser provides a mock function with given fields: username
-func (_m *ServicePost) GetByUser(username string) []*post.Post {
-	ret := _m.Called(username)
+// GetByUser provides a mock function with given fields: ctx, username, viewerID
+func (_m *ServicePost) GetByUser(ctx context.Context, username string, viewerID string) ([]*post.Post, error) {
+	ret := _m.Called(ctx, username, viewerID)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetByUser")
 	}
 
 	var r0 []*post.Post
-	if rf, ok := ret.Get(0).(func(string) []*post.Post); ok {
-		r0 = rf(username)
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) ([]*post.Post, error)); ok {
+		return rf(ctx, username, viewerID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) []*post.Post); ok {
+		r0 = rf(ctx, username, viewerID)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*post.Post)
 		}
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, username, viewerID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPage provides a mock function with given fields: ctx, filter, viewerID, page, perPage
+func (_m *ServicePost) GetPage(ctx context.Context, filter post.ListFilter, viewerID string, page int, perPage int) ([]*post.Post, int, error) {
+	ret := _m.Called(ctx, filter, viewerID, page, perPage)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPage")
+	}
+
+	var r0 []*post.Post
+	var r1 int
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, post.ListFilter, string, int, int) ([]*post.Post, int, error)); ok {
+		return rf(ctx, filter, viewerID, page, perPage)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, post.ListFilter, string, int, int) []*post.Post); ok {
+		r0 = rf(ctx, filter, viewerID, page, perPage)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*post.Post)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, post.ListFilter, string, int, int) int); ok {
+		r1 = rf(ctx, filter, viewerID, page, perPage)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, post.ListFilter, string, int, int) error); ok {
+		r2 = rf(ctx, filter, viewerID, page, perPage)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
 }
 
-// RemoveComment provides a mock function with given fields: postID, commID
-func (_m *ServicePost) RemoveComment(postID string, commID string) (*post.Post, error) {
-	ret := _m.Called(postID, commID)
+// RemoveComment provides a mock function with given fields: ctx, postID, commID
+func (_m *ServicePost) RemoveComment(ctx context.Context, postID string, commID string) (*post.Post, error) {
+	ret := _m.Called(ctx, postID, commID)
 
 	if len(ret) == 0 {
 		panic("no return value specified for RemoveComment")
@@ -211,19 +297,49 @@ func (_m *ServicePost) RemoveComment(postID string, commID string) (*post.Post,
 
 	var r0 *post.Post
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string, string) (*post.Post, error)); ok {
-		return rf(postID, commID)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*post.Post, error)); ok {
+		return rf(ctx, postID, commID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *post.Post); ok {
+		r0 = rf(ctx, postID, commID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*post.Post)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, postID, commID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RemoveCommentAs provides a mock function with given fields: ctx, postID, commID, userID
+func (_m *ServicePost) RemoveCommentAs(ctx context.Context, postID string, commID string, userID string) (*post.Post, error) {
+	ret := _m.Called(ctx, postID, commID, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveCommentAs")
+	}
+
+	var r0 *post.Post
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) (*post.Post, error)); ok {
+		return rf(ctx, postID, commID, userID)
 	}
-	if rf, ok := ret.Get(0).(func(string, string) *post.Post); ok {
-		r0 = rf(postID, commID)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) *post.Post); ok {
+		r0 = rf(ctx, postID, commID, userID)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*post.Post)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string, string) error); ok {
-		r1 = rf(postID, commID)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string) error); ok {
+		r1 = rf(ctx, postID, commID, userID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -1,12 +1,21 @@
 package post
 
 import (
+	"context"
+	"slices"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"redditclone/pkg/user"
 )
 
+// Categories are the ones a post can be filed under.
+var Categories = []string{"music", "funny", "videos", "programming", "news", "fashion"}
+
+func ValidCategory(category string) bool {
+	return slices.Contains(Categories, category)
+}
+
 type Comment struct {
 	Created time.Time `json:"created" bson:"created"`
 	Author  user.User `json:"author" bson:"author"`
@@ -34,17 +43,38 @@ type Post struct {
 	UpvotePercentage int                `json:"upvotePercentage"`
 	ID               string             `json:"id" bson:"-"`
 	URL              *string            `json:"url,omitempty" bson:"url,omitempty"`
+	// Deleted is set while the post is soft deleted, every read skips it.
+	Deleted *time.Time `json:"-" bson:"deleted,omitempty"`
 }
 
 type Repository interface {
-	Create(post *Post) error
-	GetByID(id string) (*Post, error)
-	GetAll() []*Post
-	GetByUser(userID string) []*Post
-	GetByCategory(category string) []*Post
-	Delete(postID string) error
-	AddComment(postID string, comment Comment) (*Post, error)
-	RemoveComment(postID string, commentID string) (*Post, error)
-	AddVote(postID string, vote Voting) (*Post, error)
-	CancelVote(postID string, user string) (*Post, error)
+	Create(ctx context.Context, post *Post) error
+	GetByID(ctx context.Context, id string) (*Post, error)
+	FindByID(ctx context.Context, id string) (*Post, error)
+	GetAll(ctx context.Context) ([]*Post, error)
+	GetByUser(ctx context.Context, userID string) ([]*Post, error)
+	GetByCategory(ctx context.Context, category string) ([]*Post, error)
+	// GetPage returns the posts of filter from skip on, at most limit of
+	// them, with how many match in all.
+	GetPage(ctx context.Context, filter ListFilter, skip, limit int) ([]*Post, int, error)
+	Delete(ctx context.Context, postID string) error
+	Restore(ctx context.Context, postID string) error
+	AddComment(ctx context.Context, postID string, comment Comment) (*Post, error)
+	RemoveComment(ctx context.Context, postID string, commentID string) (*Post, error)
+	AddVote(ctx context.Context, postID string, vote Voting) (*Post, error)
+	CancelVote(ctx context.Context, postID string, user string) (*Post, error)
+}
+
+// ListFilter narrows a list of posts, empty fields match every post.
+type ListFilter struct {
+	Category string
+	Username string
+	// HiddenAuthors are the IDs of users whose posts are left out.
+	HiddenAuthors []string
+}
+
+// BlockList tells the service whose content a viewer does not want to see.
+type BlockList interface {
+	BlockedIDs(ctx context.Context, userID string) ([]string, error)
+	IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error)
 }
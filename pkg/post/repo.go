@@ -4,13 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"sort"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"redditclone/pkg/dbcall"
 )
 
 type MongoRepo struct {
@@ -23,13 +25,14 @@ func NewMongoRepo(db *mongo.Database) *MongoRepo {
 	}
 }
 
-func (r *MongoRepo) Create(post *Post) error {
-	ctx := context.TODO()
+func (r *MongoRepo) Create(ctx context.Context, post *Post) error {
+	ctx, done := dbcall.Start(ctx, "mongo", "posts", "Create")
+	defer done()
 
 	result, err := r.collection.InsertOne(ctx, post)
 	if err != nil {
 		if mongo.IsDuplicateKeyError(err) {
-			return errors.New("post already exists")
+			return ErrExists
 		}
 		return err
 	}
@@ -44,23 +47,24 @@ func (r *MongoRepo) Create(post *Post) error {
 	return nil
 }
 
-func (r *MongoRepo) GetByID(id string) (*Post, error) {
-	ctx := context.TODO()
+func (r *MongoRepo) GetByID(ctx context.Context, id string) (*Post, error) {
+	ctx, done := dbcall.Start(ctx, "mongo", "posts", "GetByID")
+	defer done()
 	var post Post
 
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return nil, errors.New("invalid ID format")
+		return nil, ErrInvalidID
 	}
 
 	err = r.collection.FindOneAndUpdate(
 		ctx,
-		bson.M{"_id": objectID},
+		live(bson.M{"_id": objectID}),
 		bson.M{"$inc": bson.M{"views": 1}},
 		options.FindOneAndUpdate().SetReturnDocument(options.After),
 	).Decode(&post)
 	if errors.Is(err, mongo.ErrNoDocuments) {
-		return nil, errors.New("post not found")
+		return nil, ErrNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to increment views and fetch post: %w", err)
@@ -70,11 +74,12 @@ func (r *MongoRepo) GetByID(id string) (*Post, error) {
 	return &post, nil
 }
 
-func (r *MongoRepo) GetAll() []*Post {
-	ctx := context.TODO()
-	cursor, err := r.collection.Find(ctx, bson.D{})
+func (r *MongoRepo) GetAll(ctx context.Context) ([]*Post, error) {
+	ctx, done := dbcall.Start(ctx, "mongo", "posts", "GetAll")
+	defer done()
+	cursor, err := r.collection.Find(ctx, live(bson.M{}))
 	if err != nil {
-		return nil
+		return nil, err
 	}
 	defer cursor.Close(ctx)
 
@@ -92,14 +97,15 @@ func (r *MongoRepo) GetAll() []*Post {
 		return posts[i].Score > posts[j].Score
 	})
 
-	return posts
+	return posts, cursor.Err()
 }
 
-func (r *MongoRepo) GetByUser(username string) []*Post {
-	ctx := context.TODO()
-	cursor, err := r.collection.Find(ctx, bson.M{"author.username": username})
+func (r *MongoRepo) GetByUser(ctx context.Context, username string) ([]*Post, error) {
+	ctx, done := dbcall.Start(ctx, "mongo", "posts", "GetByUser")
+	defer done()
+	cursor, err := r.collection.Find(ctx, live(bson.M{"author.username": username}))
 	if err != nil {
-		return nil
+		return nil, err
 	}
 	defer cursor.Close(ctx)
 
@@ -111,14 +117,15 @@ func (r *MongoRepo) GetByUser(username string) []*Post {
 			posts = append(posts, &post)
 		}
 	}
-	return posts
+	return posts, cursor.Err()
 }
 
-func (r *MongoRepo) GetByCategory(category string) []*Post {
-	ctx := context.TODO()
-	cursor, err := r.collection.Find(ctx, bson.M{"category": category})
+func (r *MongoRepo) GetByCategory(ctx context.Context, category string) ([]*Post, error) {
+	ctx, done := dbcall.Start(ctx, "mongo", "posts", "GetByCategory")
+	defer done()
+	cursor, err := r.collection.Find(ctx, live(bson.M{"category": category}))
 	if err != nil {
-		return nil
+		return nil, err
 	}
 	defer cursor.Close(ctx)
 
@@ -131,34 +138,114 @@ func (r *MongoRepo) GetByCategory(category string) []*Post {
 		}
 	}
 
-	return posts
+	return posts, cursor.Err()
 }
 
-func (r *MongoRepo) Delete(postID string) error {
-	ctx := context.TODO()
+// GetPage sorts by score like GetAll, the ID breaks ties so that pages do not
+// overlap.
+func (r *MongoRepo) GetPage(ctx context.Context, filter ListFilter, skip, limit int) ([]*Post, int, error) {
+	ctx, done := dbcall.Start(ctx, "mongo", "posts", "GetPage")
+	defer done()
+
+	query := bson.M{}
+	if filter.Category != "" {
+		query["category"] = filter.Category
+	}
+	if filter.Username != "" {
+		query["author.username"] = filter.Username
+	}
+	if len(filter.HiddenAuthors) > 0 {
+		query["author.id"] = bson.M{"$nin": filter.HiddenAuthors}
+	}
+	query = live(query)
+
+	total, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cursor, err := r.collection.Find(ctx, query, options.Find().
+		SetSort(bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(skip)).
+		SetLimit(int64(limit)))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	posts := make([]*Post, 0, limit)
+	for cursor.Next(ctx) {
+		var post Post
+		if cursor.Decode(&post) == nil {
+			post.ID = post.MongoID.Hex()
+			posts = append(posts, &post)
+		}
+	}
+	return posts, int(total), cursor.Err()
+}
+
+// Delete hides the post, it stays in the collection until an operator
+// restores it.
+func (r *MongoRepo) Delete(ctx context.Context, postID string) error {
+	ctx, done := dbcall.Start(ctx, "mongo", "posts", "Delete")
+	defer done()
 
 	objectID, err := primitive.ObjectIDFromHex(postID)
 	if err != nil {
-		return errors.New("invalid ID format")
+		return ErrInvalidID
 	}
 
-	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": objectID})
+	res, err := r.collection.UpdateOne(ctx,
+		live(bson.M{"_id": objectID}),
+		bson.M{"$set": bson.M{"deleted": time.Now()}},
+	)
 	if err != nil {
 		return err
 	}
-	if res.DeletedCount == 0 {
-		return errors.New("post not found")
+	if res.MatchedCount == 0 {
+		return ErrNotFound
 	}
 
 	return nil
 }
 
-func (r *MongoRepo) AddComment(postID string, comment Comment) (*Post, error) {
-	ctx := context.TODO()
+// Restore brings back a post removed by Delete.
+func (r *MongoRepo) Restore(ctx context.Context, postID string) error {
+	ctx, done := dbcall.Start(ctx, "mongo", "posts", "Restore")
+	defer done()
 
 	objectID, err := primitive.ObjectIDFromHex(postID)
 	if err != nil {
-		return nil, errors.New("invalid ID format")
+		return ErrInvalidID
+	}
+
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": objectID, "deleted": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"deleted": ""}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// live narrows filter to the posts that are not deleted.
+func live(filter bson.M) bson.M {
+	filter["deleted"] = bson.M{"$exists": false}
+	return filter
+}
+
+func (r *MongoRepo) AddComment(ctx context.Context, postID string, comment Comment) (*Post, error) {
+	ctx, done := dbcall.Start(ctx, "mongo", "posts", "AddComment")
+	defer done()
+
+	objectID, err := primitive.ObjectIDFromHex(postID)
+	if err != nil {
+		return nil, ErrInvalidID
 	}
 
 	comment.ID = primitive.NewObjectID().Hex()
@@ -172,12 +259,12 @@ func (r *MongoRepo) AddComment(postID string, comment Comment) (*Post, error) {
 	var updatedPost Post
 	err = r.collection.FindOneAndUpdate(
 		ctx,
-		bson.M{"_id": objectID},
+		live(bson.M{"_id": objectID}),
 		update,
 		options.FindOneAndUpdate().SetReturnDocument(options.After),
 	).Decode(&updatedPost)
 	if err == mongo.ErrNoDocuments {
-		return nil, errors.New("post not found")
+		return nil, ErrNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to add comment: %w", err)
@@ -185,17 +272,16 @@ func (r *MongoRepo) AddComment(postID string, comment Comment) (*Post, error) {
 
 	updatedPost.ID = updatedPost.MongoID.Hex()
 
-	log.Println("updated post with comment:", updatedPost)
-
 	return &updatedPost, nil
 }
 
-func (r *MongoRepo) RemoveComment(postID, commentID string) (*Post, error) {
-	ctx := context.TODO()
+func (r *MongoRepo) RemoveComment(ctx context.Context, postID, commentID string) (*Post, error) {
+	ctx, done := dbcall.Start(ctx, "mongo", "posts", "RemoveComment")
+	defer done()
 
 	objectID, err := primitive.ObjectIDFromHex(postID)
 	if err != nil {
-		return nil, errors.New("invalid post ID format")
+		return nil, ErrInvalidID
 	}
 
 	update := bson.M{
@@ -207,12 +293,12 @@ func (r *MongoRepo) RemoveComment(postID, commentID string) (*Post, error) {
 	var updatedPost Post
 	err = r.collection.FindOneAndUpdate(
 		ctx,
-		bson.M{"_id": objectID},
+		live(bson.M{"_id": objectID}),
 		update,
 		options.FindOneAndUpdate().SetReturnDocument(options.After),
 	).Decode(&updatedPost)
 	if err == mongo.ErrNoDocuments {
-		return nil, errors.New("post not found")
+		return nil, ErrNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to remove comment: %w", err)
@@ -222,96 +308,123 @@ func (r *MongoRepo) RemoveComment(postID, commentID string) (*Post, error) {
 	return &updatedPost, nil
 }
 
-func (r *MongoRepo) AddVote(postID string, vote Voting) (*Post, error) {
-	ctx := context.TODO()
+// AddVote sets the vote of vote.User on a live post and recounts score and
+// upvote percentage in the same update, so parallel votes are not lost and a
+// deleted post stays deleted.
+func (r *MongoRepo) AddVote(ctx context.Context, postID string, vote Voting) (*Post, error) {
+	ctx, done := dbcall.Start(ctx, "mongo", "posts", "AddVote")
+	defer done()
 
-	post, err := r.FindByID(postID)
+	objectID, err := primitive.ObjectIDFromHex(postID)
 	if err != nil {
-		return nil, err
+		return nil, ErrInvalidID
 	}
 
-	override := false
-	for i, v := range post.Votes {
-		if v.User == vote.User {
-			diff := int(vote.Vote - v.Vote)
-			post.Votes[i].Vote = vote.Vote
-			post.Score += diff
-			override = true
-			break
-		}
+	// $literal keeps a username starting with $ from being read as a field
+	user := bson.M{"$literal": vote.User}
+	ballot := bson.M{"$literal": vote}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"votes": bson.M{"$ifNull": bson.A{"$votes", bson.A{}}}}}},
+		{{Key: "$set", Value: bson.M{"votes": bson.M{"$cond": bson.A{
+			bson.M{"$in": bson.A{user, "$votes.user"}},
+			bson.M{"$map": bson.M{
+				"input": "$votes",
+				"in":    bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$$this.user", user}}, ballot, "$$this"}},
+			}},
+			bson.M{"$concatArrays": bson.A{"$votes", bson.A{ballot}}},
+		}}}}},
+		voteTotals,
 	}
 
-	if !override {
-		post.Votes = append(post.Votes, vote)
-		post.Score += int(vote.Vote)
+	var updatedPost Post
+	err = r.collection.FindOneAndUpdate(
+		ctx,
+		live(bson.M{"_id": objectID}),
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updatedPost)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
 	}
-
-	r.updateUpvotePercentage(post)
-
-	objectID, err := primitive.ObjectIDFromHex(postID)
 	if err != nil {
-		return nil, fmt.Errorf("failed attempt to generate a mongo id")
+		return nil, fmt.Errorf("failed to add vote: %w", err)
 	}
 
-	_, err = r.collection.ReplaceOne(ctx, bson.M{"_id": objectID}, post)
-	return post, err
+	updatedPost.ID = updatedPost.MongoID.Hex()
+	return &updatedPost, nil
 }
 
-func (r *MongoRepo) CancelVote(postID string, user string) (*Post, error) {
-	ctx := context.TODO()
-	post, err := r.FindByID(postID)
+// CancelVote drops the vote of user from a live post and recounts it, like
+// AddVote.
+func (r *MongoRepo) CancelVote(ctx context.Context, postID string, user string) (*Post, error) {
+	ctx, done := dbcall.Start(ctx, "mongo", "posts", "CancelVote")
+	defer done()
+
+	objectID, err := primitive.ObjectIDFromHex(postID)
 	if err != nil {
-		return nil, err
+		return nil, ErrInvalidID
 	}
 
-	for i, v := range post.Votes {
-		if v.User == user {
-			post.Score -= int(v.Vote)
-			post.Votes = append(post.Votes[:i], post.Votes[i+1:]...)
-
-			r.updateUpvotePercentage(post)
-
-			objectID, err := primitive.ObjectIDFromHex(postID)
-			if err != nil {
-				return nil, fmt.Errorf("failed attempt to generate a mongo id")
-			}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"votes": bson.M{"$filter": bson.M{
+			"input": "$votes",
+			"cond":  bson.M{"$ne": bson.A{"$$this.user", bson.M{"$literal": user}}},
+		}}}}},
+		voteTotals,
+	}
 
-			_, err = r.collection.ReplaceOne(ctx, bson.M{"_id": objectID}, post)
-			return post, err
+	var updatedPost Post
+	err = r.collection.FindOneAndUpdate(
+		ctx,
+		live(bson.M{"_id": objectID, "votes.user": user}),
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updatedPost)
+	if err == mongo.ErrNoDocuments {
+		// tell a missing post apart from a missing vote
+		if _, err := r.FindByID(ctx, postID); err != nil {
+			return nil, err
 		}
+		return nil, ErrVoteNotFound
 	}
-
-	return nil, errors.New("vote not found")
-}
-
-func (r *MongoRepo) updateUpvotePercentage(post *Post) {
-	total := len(post.Votes)
-	if total == 0 {
-		post.UpvotePercentage = 0
-		return
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel vote: %w", err)
 	}
 
-	upvotes := 0
-	for _, v := range post.Votes {
-		if v.Vote == 1 {
-			upvotes++
-		}
-	}
-	post.UpvotePercentage = (upvotes * 100) / total
+	updatedPost.ID = updatedPost.MongoID.Hex()
+	return &updatedPost, nil
 }
 
-func (r *MongoRepo) FindByID(id string) (*Post, error) {
-	ctx := context.TODO()
+// voteTotals is the update stage deriving score and upvote percentage from
+// the votes.
+var voteTotals = bson.D{{Key: "$set", Value: bson.M{
+	"score": bson.M{"$sum": "$votes.vote"},
+	"upvotepercentage": bson.M{"$cond": bson.A{
+		bson.M{"$eq": bson.A{bson.M{"$size": "$votes"}, 0}},
+		0,
+		bson.M{"$toInt": bson.M{"$floor": bson.M{"$divide": bson.A{
+			bson.M{"$multiply": bson.A{100, bson.M{"$size": bson.M{"$filter": bson.M{
+				"input": "$votes",
+				"cond":  bson.M{"$eq": bson.A{"$$this.vote", 1}},
+			}}}}},
+			bson.M{"$size": "$votes"},
+		}}}},
+	}},
+}}}
+
+func (r *MongoRepo) FindByID(ctx context.Context, id string) (*Post, error) {
+	ctx, done := dbcall.Start(ctx, "mongo", "posts", "FindByID")
+	defer done()
 	var post Post
 
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return nil, errors.New("invalid ID format")
+		return nil, ErrInvalidID
 	}
 
-	err = r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&post)
+	err = r.collection.FindOne(ctx, live(bson.M{"_id": objectID})).Decode(&post)
 	if errors.Is(err, mongo.ErrNoDocuments) {
-		return nil, errors.New("post not found")
+		return nil, ErrNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch post: %w", err)
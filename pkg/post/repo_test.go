@@ -1,6 +1,7 @@
 package post_test
 
 import (
+	"context"
 	"encoding/binary"
 	"testing"
 
@@ -13,6 +14,7 @@ import (
 )
 
 func TestGetAllRepo(t *testing.T) {
+	ctx := context.Background()
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 
 	mt.Run("success with non valid json", func(mt *mtest.T) {
@@ -21,10 +23,11 @@ func TestGetAllRepo(t *testing.T) {
 			{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "score", Value: 20}},
 			{{Key: "_id", Value: "oops"}, {Key: "score", Value: 20}},
 		}
-		mt.AddMockResponses(mtest.CreateCursorResponse(1, "posts.foo", mtest.FirstBatch, posts...))
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "posts.foo", mtest.FirstBatch, posts...))
 		repo := post.NewMongoRepo(mt.DB)
 
-		results := repo.GetAll()
+		results, err := repo.GetAll(ctx)
+		assert.NoError(t, err)
 
 		assert.Len(t, results, 2)
 		assert.GreaterOrEqual(t, results[0].Score, results[1].Score)
@@ -38,13 +41,15 @@ func TestGetAllRepo(t *testing.T) {
 			Message: "some error",
 		}))
 
-		results := repo.GetAll()
+		results, err := repo.GetAll(ctx)
 
+		assert.EqualError(t, err, "some error")
 		assert.Nil(t, results)
 	})
 }
 
 func TestGetByUserRepo(t *testing.T) {
+	ctx := context.Background()
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 
 	mt.Run("success", func(mt *mtest.T) {
@@ -55,10 +60,11 @@ func TestGetByUserRepo(t *testing.T) {
 				{Key: "author", Value: bson.M{"username": user}},
 			},
 		}
-		mt.AddMockResponses(mtest.CreateCursorResponse(1, "posts.foo", mtest.FirstBatch, posts...))
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "posts.foo", mtest.FirstBatch, posts...))
 
 		repo := post.NewMongoRepo(mt.DB)
-		results := repo.GetByUser(user)
+		results, err := repo.GetByUser(ctx, user)
+		assert.NoError(t, err)
 
 		assert.Len(t, results, 1)
 		assert.Equal(t, user, results[0].Author.Username)
@@ -72,7 +78,7 @@ func TestGetByUserRepo(t *testing.T) {
 			Message: "error",
 		}))
 
-		result, err := repo.GetByID(validID)
+		result, err := repo.GetByID(ctx, validID)
 
 		assert.Error(t, err)
 		assert.Nil(t, result)
@@ -81,6 +87,7 @@ func TestGetByUserRepo(t *testing.T) {
 }
 
 func TestGetByCategoryRepo(t *testing.T) {
+	ctx := context.Background()
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 
 	mt.Run("success", func(mt *mtest.T) {
@@ -91,10 +98,11 @@ func TestGetByCategoryRepo(t *testing.T) {
 				{Key: "category", Value: category},
 			},
 		}
-		mt.AddMockResponses(mtest.CreateCursorResponse(1, "posts.foo", mtest.FirstBatch, posts...))
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "posts.foo", mtest.FirstBatch, posts...))
 
 		repo := post.NewMongoRepo(mt.DB)
-		results := repo.GetByCategory(category)
+		results, err := repo.GetByCategory(ctx, category)
+		assert.NoError(t, err)
 
 		assert.Len(t, results, 1)
 		assert.Equal(t, category, results[0].Category)
@@ -102,11 +110,12 @@ func TestGetByCategoryRepo(t *testing.T) {
 }
 
 func TestDeleteRepo(t *testing.T) {
+	ctx := context.Background()
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 
 	mt.Run("invalid ID format", func(mt *mtest.T) {
 		repo := post.NewMongoRepo(mt.DB)
-		err := repo.Delete("invalid")
+		err := repo.Delete(ctx, "invalid")
 		assert.EqualError(t, err, "invalid ID format")
 	})
 
@@ -116,7 +125,7 @@ func TestDeleteRepo(t *testing.T) {
 			bson.E{Key: "ok", Value: 1},
 		))
 		repo := post.NewMongoRepo(mt.DB)
-		err := repo.Delete(primitive.NewObjectID().Hex())
+		err := repo.Delete(ctx, primitive.NewObjectID().Hex())
 		assert.NoError(t, err)
 	})
 
@@ -128,7 +137,7 @@ func TestDeleteRepo(t *testing.T) {
 			Message: "simulated delete error",
 		}))
 
-		err := repo.Delete(primitive.NewObjectID().Hex())
+		err := repo.Delete(ctx, primitive.NewObjectID().Hex())
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "simulated delete error")
@@ -142,13 +151,45 @@ func TestDeleteRepo(t *testing.T) {
 			bson.E{Key: "n", Value: 0},
 		))
 
-		err := repo.Delete(primitive.NewObjectID().Hex())
+		err := repo.Delete(ctx, primitive.NewObjectID().Hex())
+
+		assert.EqualError(t, err, "post not found")
+	})
+}
+
+func TestRestoreRepo(t *testing.T) {
+	ctx := context.Background()
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("invalid ID format", func(mt *mtest.T) {
+		repo := post.NewMongoRepo(mt.DB)
+		err := repo.Restore(ctx, "invalid")
+		assert.EqualError(t, err, "invalid ID format")
+	})
+
+	mt.Run("restore success", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(
+			bson.E{Key: "n", Value: 1},
+			bson.E{Key: "ok", Value: 1},
+		))
+		repo := post.NewMongoRepo(mt.DB)
+		err := repo.Restore(ctx, primitive.NewObjectID().Hex())
+		assert.NoError(t, err)
+	})
 
+	mt.Run("post not deleted", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(
+			bson.E{Key: "ok", Value: 1},
+			bson.E{Key: "n", Value: 0},
+		))
+		repo := post.NewMongoRepo(mt.DB)
+		err := repo.Restore(ctx, primitive.NewObjectID().Hex())
 		assert.EqualError(t, err, "post not found")
 	})
 }
 
 func TestMongoRepo_AddComment(t *testing.T) {
+	ctx := context.Background()
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 
 	mt.Run("success", func(mt *mtest.T) {
@@ -177,7 +218,7 @@ func TestMongoRepo_AddComment(t *testing.T) {
 			},
 		)
 
-		resp, err := repo.AddComment(hexMongoID, commentArg)
+		resp, err := repo.AddComment(ctx, hexMongoID, commentArg)
 
 		assert.NoError(t, err)
 		assert.Equal(t, 1, len(resp.Comments))
@@ -186,7 +227,7 @@ func TestMongoRepo_AddComment(t *testing.T) {
 
 	mt.Run("bad post id", func(mt *mtest.T) {
 		repo := post.NewMongoRepo(mt.DB)
-		_, err := repo.AddComment("🦧", post.Comment{})
+		_, err := repo.AddComment(ctx, "🦧", post.Comment{})
 
 		assert.Error(t, err)
 	})
@@ -201,7 +242,7 @@ func TestMongoRepo_AddComment(t *testing.T) {
 			},
 		)
 
-		_, err := repo.AddComment("507f1f77bcf86cd799439011", post.Comment{
+		_, err := repo.AddComment(ctx, "507f1f77bcf86cd799439011", post.Comment{
 			Body: "test comment",
 		})
 
@@ -220,7 +261,7 @@ func TestMongoRepo_AddComment(t *testing.T) {
 			},
 		))
 
-		_, err := repo.AddComment("507f1f77bcf86cd799439011", post.Comment{
+		_, err := repo.AddComment(ctx, "507f1f77bcf86cd799439011", post.Comment{
 			Body: "test comment",
 		})
 
@@ -230,6 +271,7 @@ func TestMongoRepo_AddComment(t *testing.T) {
 }
 
 func TestRemoveCommentRepo(t *testing.T) {
+	ctx := context.Background()
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 
 	mt.Run("success", func(mt *mtest.T) {
@@ -250,7 +292,7 @@ func TestRemoveCommentRepo(t *testing.T) {
 			},
 		)
 
-		resp, err := repo.RemoveComment(hexMongoID, "123456789012345678901234")
+		resp, err := repo.RemoveComment(ctx, hexMongoID, "123456789012345678901234")
 
 		assert.NoError(t, err)
 		assert.Equal(t, 0, len(resp.Comments))
@@ -258,7 +300,7 @@ func TestRemoveCommentRepo(t *testing.T) {
 
 	mt.Run("bad post id", func(mt *mtest.T) {
 		repo := post.NewMongoRepo(mt.DB)
-		_, err := repo.RemoveComment("🦧", "ugabuga")
+		_, err := repo.RemoveComment(ctx, "🦧", "ugabuga")
 
 		assert.Error(t, err)
 	})
@@ -273,7 +315,7 @@ func TestRemoveCommentRepo(t *testing.T) {
 			},
 		)
 
-		_, err := repo.RemoveComment("507f1f77bcf86cd799439011", "🦧")
+		_, err := repo.RemoveComment(ctx, "507f1f77bcf86cd799439011", "🦧")
 
 		assert.Error(t, err)
 		assert.Equal(t, "post not found", err.Error())
@@ -290,13 +332,14 @@ func TestRemoveCommentRepo(t *testing.T) {
 			},
 		))
 
-		_, err := repo.RemoveComment("507f1f77bcf86cd799439011", "🦧")
+		_, err := repo.RemoveComment(ctx, "507f1f77bcf86cd799439011", "🦧")
 
 		assert.Error(t, err)
 	})
 }
 
 func TestAddVoteRepo(t *testing.T) {
+	ctx := context.Background()
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 
 	vote := post.Voting{
@@ -307,160 +350,137 @@ func TestAddVoteRepo(t *testing.T) {
 	mt.Run("success", func(mt *mtest.T) {
 		repo := post.NewMongoRepo(mt.DB)
 		mongoID := primitive.NewObjectID()
-		hexMongoID := mongoID.Hex()
 
-		update := bson.D{
+		updated := bson.D{
 			{Key: "_id", Value: mongoID},
-			{Key: "comments", Value: bson.A{
-				bson.D{
-					{Key: "body", Value: "lyalyalya"},
-				},
-			}},
+			{Key: "score", Value: 2},
 			{Key: "votes", Value: bson.A{
-				bson.D{
-					{Key: "user", Value: "ugabuga"},
-					{Key: "vote", Value: 1},
-				},
-				bson.D{
-					{Key: "user", Value: "test_user"},
-					{Key: "vote", Value: -1},
-				},
+				bson.D{{Key: "user", Value: "ugabuga"}, {Key: "vote", Value: 1}},
+				bson.D{{Key: "user", Value: "test_user"}, {Key: "vote", Value: 1}},
 			}},
+			{Key: "upvotepercentage", Value: 100},
 		}
+		mt.AddMockResponses(bson.D{
+			{Key: "ok", Value: 1},
+			{Key: "value", Value: updated},
+		})
 
-		mt.AddMockResponses(
-			mtest.CreateCursorResponse(1, "foo.bar", mtest.FirstBatch, update),
-			mtest.CreateCursorResponse(0, "foo.bar", mtest.NextBatch),
-			bson.D{ // ReplaceOne
-				{Key: "ok", Value: 1},
-				{Key: "n", Value: 1},
-				{Key: "nModified", Value: 1},
-			},
-		)
-
-		_, err := repo.AddVote(hexMongoID, vote)
+		resp, err := repo.AddVote(ctx, mongoID.Hex(), vote)
 
 		assert.NoError(t, err)
+		assert.Equal(t, mongoID.Hex(), resp.ID)
+		assert.Equal(t, 2, resp.Score)
+		assert.Equal(t, 100, resp.UpvotePercentage)
+
+		// one update, which leaves deleted posts alone
+		started := mt.GetStartedEvent()
+		assert.Equal(t, "findAndModify", started.CommandName)
+		query := started.Command.Lookup("query").Document()
+		assert.Equal(t, false, query.Lookup("deleted", "$exists").Boolean())
 	})
 
 	mt.Run("bad id", func(mt *mtest.T) {
 		repo := post.NewMongoRepo(mt.DB)
-		_, err := repo.AddVote("🦧", vote)
-
-		assert.Error(t, err)
+		_, err := repo.AddVote(ctx, "🦧", vote)
 
+		assert.ErrorIs(t, err, post.ErrInvalidID)
 	})
 
-	mt.Run("success", func(mt *mtest.T) {
+	mt.Run("deleted or missing post", func(mt *mtest.T) {
 		repo := post.NewMongoRepo(mt.DB)
-		mongoID := primitive.NewObjectID()
-		hexMongoID := mongoID.Hex()
+		mt.AddMockResponses(bson.D{
+			{Key: "ok", Value: 1},
+			{Key: "value", Value: nil},
+		})
 
-		update := bson.D{
-			{Key: "_id", Value: mongoID},
-			{Key: "comments", Value: bson.A{
-				bson.D{
-					{Key: "body", Value: "lyalyalya"},
-				},
-			}},
-			{Key: "votes", Value: bson.A{}},
-		}
+		_, err := repo.AddVote(ctx, "507f1f77bcf86cd799439011", vote)
 
-		mt.AddMockResponses(
-			mtest.CreateCursorResponse(1, "foo.bar", mtest.FirstBatch, update),
-			mtest.CreateCursorResponse(0, "foo.bar", mtest.NextBatch),
-			bson.D{
-				{Key: "ok", Value: 1},
-				{Key: "n", Value: 1},
-				{Key: "nModified", Value: 1},
-			},
-		)
+		assert.ErrorIs(t, err, post.ErrNotFound)
+	})
+
+	mt.Run("unexpected mongo error", func(mt *mtest.T) {
+		repo := post.NewMongoRepo(mt.DB)
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{
+			Code:    91,
+			Message: "server is shutting down",
+			Name:    "ShutdownInProgress",
+		}))
 
-		_, err := repo.AddVote(hexMongoID, vote)
+		_, err := repo.AddVote(ctx, "507f1f77bcf86cd799439011", vote)
 
-		assert.NoError(t, err)
+		assert.ErrorContains(t, err, "failed to add vote")
 	})
 }
 
 func TestCancelVoteRepo(t *testing.T) {
+	ctx := context.Background()
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 
 	mt.Run("success", func(mt *mtest.T) {
 		repo := post.NewMongoRepo(mt.DB)
 		mongoID := primitive.NewObjectID()
-		hexMongoID := mongoID.Hex()
 
-		update := bson.D{
+		updated := bson.D{
 			{Key: "_id", Value: mongoID},
-			{Key: "comments", Value: bson.A{
-				bson.D{
-					{Key: "body", Value: "lyalyalya"},
-				},
-			}},
-			{Key: "votes", Value: bson.A{
-				bson.D{
-					{Key: "user", Value: "test_user"},
-					{Key: "vote", Value: 1},
-				},
-			}},
+			{Key: "score", Value: 0},
+			{Key: "votes", Value: bson.A{}},
 		}
+		mt.AddMockResponses(bson.D{
+			{Key: "ok", Value: 1},
+			{Key: "value", Value: updated},
+		})
 
-		mt.AddMockResponses(
-			mtest.CreateCursorResponse(1, "foo.bar", mtest.FirstBatch, update),
-			mtest.CreateCursorResponse(0, "foo.bar", mtest.NextBatch),
-			bson.D{
-				{Key: "ok", Value: 1},
-				{Key: "n", Value: 1},
-				{Key: "nModified", Value: 1},
-			},
-		)
-
-		_, err := repo.CancelVote(hexMongoID, "test_user")
+		resp, err := repo.CancelVote(ctx, mongoID.Hex(), "test_user")
 
 		assert.NoError(t, err)
+		assert.Equal(t, mongoID.Hex(), resp.ID)
+		assert.Empty(t, resp.Votes)
+
+		query := mt.GetStartedEvent().Command.Lookup("query").Document()
+		assert.Equal(t, "test_user", query.Lookup("votes.user").StringValue())
+		assert.Equal(t, false, query.Lookup("deleted", "$exists").Boolean())
 	})
 
-	mt.Run("success", func(mt *mtest.T) {
+	mt.Run("bad id", func(mt *mtest.T) {
 		repo := post.NewMongoRepo(mt.DB)
-		_, err := repo.CancelVote("🦧", "test_user")
+		_, err := repo.CancelVote(ctx, "🦧", "test_user")
 
-		assert.Error(t, err)
+		assert.ErrorIs(t, err, post.ErrInvalidID)
 	})
 
-	mt.Run("success", func(mt *mtest.T) {
-
+	mt.Run("no vote", func(mt *mtest.T) {
 		repo := post.NewMongoRepo(mt.DB)
 		mongoID := primitive.NewObjectID()
-		hexMongoID := mongoID.Hex()
-
-		update := bson.D{
-			{Key: "_id", Value: mongoID},
-			{Key: "comments", Value: bson.A{
-				bson.D{
-					{Key: "body", Value: "lyalyalya"},
-				},
-			}},
-			{Key: "votes", Value: bson.A{}},
-		}
 
 		mt.AddMockResponses(
-			mtest.CreateCursorResponse(1, "foo.bar", mtest.FirstBatch, update),
-			mtest.CreateCursorResponse(0, "foo.bar", mtest.NextBatch),
-			bson.D{
-				{Key: "ok", Value: 1},
-				{Key: "n", Value: 1},
-				{Key: "nModified", Value: 1},
-			},
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+			mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: mongoID},
+				{Key: "votes", Value: bson.A{}},
+			}),
 		)
 
-		_, err := repo.CancelVote(hexMongoID, "test_user")
+		_, err := repo.CancelVote(ctx, mongoID.Hex(), "test_user")
 
-		assert.Error(t, err)
+		assert.ErrorIs(t, err, post.ErrVoteNotFound)
 	})
 
+	mt.Run("deleted or missing post", func(mt *mtest.T) {
+		repo := post.NewMongoRepo(mt.DB)
+
+		mt.AddMockResponses(
+			bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}},
+			mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch),
+		)
+
+		_, err := repo.CancelVote(ctx, "507f1f77bcf86cd799439011", "test_user")
+
+		assert.ErrorIs(t, err, post.ErrNotFound)
+	})
 }
 
 func TestMongoRepo_Create(t *testing.T) {
+	ctx := context.Background()
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 
 	mt.Run("successfully insert post", func(mt *mtest.T) {
@@ -474,7 +494,7 @@ func TestMongoRepo_Create(t *testing.T) {
 			{Key: "insertedId", Value: expectedID},
 		})
 
-		err := repo.Create(&p)
+		err := repo.Create(ctx, &p)
 
 		// это проверка на то, что дейтсвитедбно создался новый пост с новым внутренним монгоID
 		// три последних байта отвечают за инкремент, после каждого инсерта он должен быть на 1 больше
@@ -516,7 +536,7 @@ func TestMongoRepo_Create(t *testing.T) {
 			{Key: "insertedId", Value: nil},
 		})
 
-		err := repo.Create(&p)
+		err := repo.Create(ctx, &p)
 
 		assert.Error(t, err)
 	})
@@ -538,10 +558,53 @@ func TestMongoRepo_Create(t *testing.T) {
 			),
 		)
 
-		err := repo.Create(p)
+		err := repo.Create(ctx, p)
 
 		assert.Error(t, err)
 		assert.EqualError(t, err, "post already exists")
 	})
 
 }
+
+func TestGetPageRepo(t *testing.T) {
+	ctx := context.Background()
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("skip and limit go to the server", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "posts.foo", mtest.FirstBatch, bson.D{{Key: "n", Value: int32(7)}}),
+			mtest.CreateCursorResponse(0, "posts.foo", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "score", Value: 3}},
+				bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "score", Value: 2}},
+			),
+		)
+		repo := post.NewMongoRepo(mt.DB)
+
+		filter := post.ListFilter{Category: "news", HiddenAuthors: []string{"blocked"}}
+		results, total, err := repo.GetPage(ctx, filter, 4, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 7, total)
+		assert.Len(t, results, 2)
+
+		find := mt.GetStartedEvent()
+		for find != nil && find.CommandName != "find" {
+			find = mt.GetStartedEvent()
+		}
+		if assert.NotNil(t, find) {
+			assert.Equal(t, int64(4), find.Command.Lookup("skip").Int64())
+			assert.Equal(t, int64(2), find.Command.Lookup("limit").Int64())
+			query := find.Command.Lookup("filter").Document()
+			assert.Equal(t, "news", query.Lookup("category").StringValue())
+			assert.Equal(t, "blocked", query.Lookup("author.id", "$nin", "0").StringValue())
+		}
+	})
+
+	mt.Run("count error", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 123, Message: "some error"}))
+		repo := post.NewMongoRepo(mt.DB)
+
+		results, _, err := repo.GetPage(ctx, post.ListFilter{}, 0, 25)
+		assert.EqualError(t, err, "some error")
+		assert.Nil(t, results)
+	})
+}
@@ -1,38 +1,55 @@
 package post
 
 import (
-	"errors"
+	"context"
+	"slices"
 	"time"
 
 	"redditclone/pkg/claims"
+	"redditclone/pkg/metrics"
+	"redditclone/pkg/tracing"
 	"redditclone/pkg/user"
 )
 
 type ServicePost interface {
-	GetAll() []*Post
-	CreatePost(post *Post, username, id string) error
-	GetByID(id string) (*Post, error)
-	AddComment(postID, comment string, claims *claims.Claims) (*Post, error)
-	RemoveComment(postID, commID string) (*Post, error)
-	Delete(postID string) error
-	AddVote(postID, username, action string) (*Post, error)
-	GetByUser(username string) []*Post
-	GetByCategory(category string) []*Post
+	GetAll(ctx context.Context, viewerID string) ([]*Post, error)
+	CreatePost(ctx context.Context, post *Post, username, id string) error
+	GetByID(ctx context.Context, id, viewerID string) (*Post, error)
+	AddComment(ctx context.Context, postID, comment string, claims *claims.Claims) (*Post, error)
+	RemoveComment(ctx context.Context, postID, commID string) (*Post, error)
+	RemoveCommentAs(ctx context.Context, postID, commID, userID string) (*Post, error)
+	Delete(ctx context.Context, postID string) error
+	DeleteAs(ctx context.Context, postID, userID string) error
+	AddVote(ctx context.Context, postID, username, action string) (*Post, error)
+	GetByUser(ctx context.Context, username, viewerID string) ([]*Post, error)
+	GetByCategory(ctx context.Context, category, viewerID string) ([]*Post, error)
+	GetPage(ctx context.Context, filter ListFilter, viewerID string, page, perPage int) ([]*Post, int, error)
 }
 
 type PostService struct {
-	Repo Repository
+	Repo   Repository
+	Blocks BlockList
 }
 
 func NewService(repo Repository) *PostService {
 	return &PostService{Repo: repo}
 }
 
-func (s *PostService) GetAll() []*Post {
-	return s.Repo.GetAll()
+func (s *PostService) GetAll(ctx context.Context, viewerID string) ([]*Post, error) {
+	ctx, span := tracing.Start(ctx, "post.PostService.GetAll")
+	defer span.End()
+
+	posts, err := s.Repo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.filterPosts(ctx, posts, viewerID), nil
 }
 
-func (s *PostService) CreatePost(post *Post, username, id string) error {
+func (s *PostService) CreatePost(ctx context.Context, post *Post, username, id string) error {
+	ctx, span := tracing.Start(ctx, "post.PostService.CreatePost")
+	defer span.End()
+
 	post.Score = 1
 	post.Views = 0
 	post.Author = user.User{
@@ -47,14 +64,46 @@ func (s *PostService) CreatePost(post *Post, username, id string) error {
 	комменатрий под постом, post.Comments = []Comments{} не работает */
 	post.Comments = make([]Comment, 0, 1)
 
-	return s.Repo.Create(post)
+	if err := s.Repo.Create(ctx, post); err != nil {
+		return err
+	}
+	metrics.PostsCreated.Inc()
+	return nil
 }
 
-func (s *PostService) GetByID(id string) (*Post, error) {
-	return s.Repo.GetByID(id)
+func (s *PostService) GetByID(ctx context.Context, id, viewerID string) (*Post, error) {
+	ctx, span := tracing.Start(ctx, "post.PostService.GetByID")
+	defer span.End()
+
+	post, err := s.Repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if hidden := s.hiddenAuthors(ctx, viewerID); len(hidden) > 0 {
+		post.Comments = filterComments(post.Comments, hidden)
+	}
+	return post, nil
 }
 
-func (s *PostService) AddComment(postID, comment string, claims *claims.Claims) (*Post, error) {
+func (s *PostService) AddComment(ctx context.Context, postID, comment string, claims *claims.Claims) (*Post, error) {
+	ctx, span := tracing.Start(ctx, "post.PostService.AddComment")
+	defer span.End()
+
+	if s.Blocks != nil {
+		post, err := s.Repo.FindByID(ctx, postID)
+		if err != nil {
+			return nil, err
+		}
+		blocked, err := s.Blocks.IsBlocked(ctx, post.Author.ID, claims.User.ID)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, ErrBlocked
+		}
+	}
+
 	ReadyComment := Comment{
 		Created: time.Now(),
 		Author: user.User{
@@ -64,40 +113,186 @@ func (s *PostService) AddComment(postID, comment string, claims *claims.Claims)
 		Body: comment,
 	}
 
-	return s.Repo.AddComment(postID, ReadyComment)
+	post, err := s.Repo.AddComment(ctx, postID, ReadyComment)
+	if err != nil {
+		return nil, err
+	}
+	metrics.CommentsCreated.Inc()
+	return post, nil
+}
+
+func (s *PostService) RemoveComment(ctx context.Context, postID, commID string) (*Post, error) {
+	ctx, span := tracing.Start(ctx, "post.PostService.RemoveComment")
+	defer span.End()
+
+	return s.Repo.RemoveComment(ctx, postID, commID)
 }
 
-func (s *PostService) RemoveComment(postID, commID string) (*Post, error) {
-	return s.Repo.RemoveComment(postID, commID)
+// RemoveCommentAs is RemoveComment for the user userID, who has to be the
+// author of the comment.
+func (s *PostService) RemoveCommentAs(ctx context.Context, postID, commID, userID string) (*Post, error) {
+	ctx, span := tracing.Start(ctx, "post.PostService.RemoveCommentAs")
+	defer span.End()
+
+	post, err := s.Repo.FindByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(post.Comments, func(c Comment) bool { return c.ID == commID })
+	if i < 0 {
+		return nil, ErrNoComment
+	}
+	if post.Comments[i].Author.ID != userID {
+		return nil, ErrNotAuthor
+	}
+
+	return s.Repo.RemoveComment(ctx, postID, commID)
+}
+
+func (s *PostService) Delete(ctx context.Context, postID string) error {
+	ctx, span := tracing.Start(ctx, "post.PostService.Delete")
+	defer span.End()
+
+	return s.Repo.Delete(ctx, postID)
+}
+
+// DeleteAs is Delete for the user userID, who has to be the author of the
+// post.
+func (s *PostService) DeleteAs(ctx context.Context, postID, userID string) error {
+	ctx, span := tracing.Start(ctx, "post.PostService.DeleteAs")
+	defer span.End()
+
+	post, err := s.Repo.FindByID(ctx, postID)
+	if err != nil {
+		return err
+	}
+	if post.Author.ID != userID {
+		return ErrNotAuthor
+	}
+
+	return s.Repo.Delete(ctx, postID)
 }
 
-func (s *PostService) Delete(postID string) error {
-	return s.Repo.Delete(postID)
+// Restore undoes Delete, it is an operator action without a route.
+func (s *PostService) Restore(ctx context.Context, postID string) error {
+	ctx, span := tracing.Start(ctx, "post.PostService.Restore")
+	defer span.End()
+
+	return s.Repo.Restore(ctx, postID)
 }
 
-func (s *PostService) AddVote(postID, username, action string) (post *Post, err error) {
+func (s *PostService) AddVote(ctx context.Context, postID, username, action string) (post *Post, err error) {
+	ctx, span := tracing.Start(ctx, "post.PostService.AddVote")
+	defer span.End()
+
 	if username == "" {
-		return nil, errors.New("missing username")
+		return nil, ErrNoVoter
 	}
 
 	switch action {
 	case "upvote":
-		post, err = s.Repo.AddVote(postID, Voting{User: username, Vote: 1})
+		post, err = s.Repo.AddVote(ctx, postID, Voting{User: username, Vote: 1})
 	case "downvote":
-		post, err = s.Repo.AddVote(postID, Voting{User: username, Vote: -1})
+		post, err = s.Repo.AddVote(ctx, postID, Voting{User: username, Vote: -1})
 	case "unvote":
-		post, err = s.Repo.CancelVote(postID, username)
+		post, err = s.Repo.CancelVote(ctx, postID, username)
 	default:
-		return nil, errors.New("invalid action")
+		return nil, ErrInvalidAction
+	}
+	if err == nil {
+		metrics.Votes.WithLabelValues(action).Inc()
 	}
 
 	return post, err
 }
 
-func (s *PostService) GetByUser(username string) []*Post {
-	return s.Repo.GetByUser(username)
+func (s *PostService) GetByUser(ctx context.Context, username, viewerID string) ([]*Post, error) {
+	ctx, span := tracing.Start(ctx, "post.PostService.GetByUser")
+	defer span.End()
+
+	posts, err := s.Repo.GetByUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return s.filterPosts(ctx, posts, viewerID), nil
 }
 
-func (s *PostService) GetByCategory(category string) []*Post {
-	return s.Repo.GetByCategory(category)
+func (s *PostService) GetByCategory(ctx context.Context, category, viewerID string) ([]*Post, error) {
+	ctx, span := tracing.Start(ctx, "post.PostService.GetByCategory")
+	defer span.End()
+
+	posts, err := s.Repo.GetByCategory(ctx, category)
+	if err != nil {
+		return nil, err
+	}
+	return s.filterPosts(ctx, posts, viewerID), nil
+}
+
+// GetPage returns page, counted from 1, of the posts of filter the viewer
+// wants to see, and how many there are in all.
+func (s *PostService) GetPage(ctx context.Context, filter ListFilter, viewerID string, page, perPage int) ([]*Post, int, error) {
+	ctx, span := tracing.Start(ctx, "post.PostService.GetPage")
+	defer span.End()
+
+	hidden := s.hiddenAuthors(ctx, viewerID)
+	for id := range hidden {
+		filter.HiddenAuthors = append(filter.HiddenAuthors, id)
+	}
+
+	posts, total, err := s.Repo.GetPage(ctx, filter, (page-1)*perPage, perPage)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(hidden) > 0 {
+		for _, p := range posts {
+			p.Comments = filterComments(p.Comments, hidden)
+		}
+	}
+	return posts, total, nil
+}
+
+// hiddenAuthors returns the IDs of users blocked or muted by the viewer.
+// Anonymous viewers and lookup failures hide nothing.
+func (s *PostService) hiddenAuthors(ctx context.Context, viewerID string) map[string]struct{} {
+	if s.Blocks == nil || viewerID == "" {
+		return nil
+	}
+
+	ids, err := s.Blocks.BlockedIDs(ctx, viewerID)
+	if err != nil || len(ids) == 0 {
+		return nil
+	}
+
+	hidden := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		hidden[id] = struct{}{}
+	}
+	return hidden
+}
+
+func (s *PostService) filterPosts(ctx context.Context, posts []*Post, viewerID string) []*Post {
+	hidden := s.hiddenAuthors(ctx, viewerID)
+	if len(hidden) == 0 {
+		return posts
+	}
+
+	visible := make([]*Post, 0, len(posts))
+	for _, p := range posts {
+		if _, ok := hidden[p.Author.ID]; ok {
+			continue
+		}
+		p.Comments = filterComments(p.Comments, hidden)
+		visible = append(visible, p)
+	}
+	return visible
+}
+
+func filterComments(comments []Comment, hidden map[string]struct{}) []Comment {
+	visible := make([]Comment, 0, len(comments))
+	for _, c := range comments {
+		if _, ok := hidden[c.Author.ID]; !ok {
+			visible = append(visible, c)
+		}
+	}
+	return visible
 }
@@ -1,6 +1,7 @@
 package post_test
 
 import (
+	"context"
 	"errors"
 	"os"
 	"testing"
@@ -44,13 +45,14 @@ func TestMain(m *testing.M) {
 }
 
 func TestCreatePost(t *testing.T) {
+	ctx := context.Background()
 	t.Run("success", func(t *testing.T) {
 		defer resetMock(mockRepo)
 
 		p := &post.Post{Title: "Test"}
-		mockRepo.On("Create", mock.AnythingOfType("*post.Post")).Return(nil)
+		mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*post.Post")).Return(nil)
 
-		err := service.CreatePost(p, "user", "id")
+		err := service.CreatePost(ctx, p, "user", "id")
 
 		assert.NoError(t, err)
 		assert.Equal(t, 1, p.Score)
@@ -65,9 +67,9 @@ func TestCreatePost(t *testing.T) {
 		defer resetMock(mockRepo)
 
 		p := &post.Post{Title: "Test"}
-		mockRepo.On("Create", mock.AnythingOfType("*post.Post")).Return(errors.New("mongo_err"))
+		mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*post.Post")).Return(errors.New("mongo_err"))
 
-		err := service.CreatePost(p, "user", "id")
+		err := service.CreatePost(ctx, p, "user", "id")
 
 		assert.Error(t, err)
 		assert.Equal(t, "user", p.Author.Username)
@@ -78,24 +80,27 @@ func TestCreatePost(t *testing.T) {
 }
 
 func TestGetAll(t *testing.T) {
+	ctx := context.Background()
 	defer resetMock(mockRepo)
 
 	mockPosts := []*post.Post{{Title: "A"}, {Title: "B"}}
-	mockRepo.On("GetAll").Return(mockPosts)
+	mockRepo.On("GetAll", mock.Anything).Return(mockPosts, nil)
 
-	res := service.GetAll()
+	res, err := service.GetAll(ctx, "")
+	assert.NoError(t, err)
 
 	assert.Equal(t, 2, len(res))
 	mockRepo.AssertExpectations(t)
 }
 
 func TestGetByID(t *testing.T) {
+	ctx := context.Background()
 	t.Run("success", func(t *testing.T) {
 		defer resetMock(mockRepo)
 
-		mockRepo.On("GetByID", "123").Return(expected, nil)
+		mockRepo.On("GetByID", mock.Anything, "123").Return(expected, nil)
 
-		res, err := service.GetByID("123")
+		res, err := service.GetByID(ctx, "123", "")
 
 		assert.NoError(t, err)
 		assert.Equal(t, expected, res)
@@ -105,9 +110,9 @@ func TestGetByID(t *testing.T) {
 	t.Run("GetById fail", func(t *testing.T) {
 		defer resetMock(mockRepo)
 
-		mockRepo.On("GetByID", "123").Return(nil, errors.New("mongo error"))
+		mockRepo.On("GetByID", mock.Anything, "123").Return(nil, errors.New("mongo error"))
 
-		res, err := service.GetByID("123")
+		res, err := service.GetByID(ctx, "123", "")
 
 		assert.Error(t, err)
 		assert.Nil(t, res)
@@ -117,12 +122,13 @@ func TestGetByID(t *testing.T) {
 }
 
 func TestAddComment(t *testing.T) {
+	ctx := context.Background()
 	t.Run("success", func(t *testing.T) {
 		defer resetMock(mockRepo)
 
-		mockRepo.On("AddComment", "123", mock.AnythingOfType("post.Comment")).Return(expected, nil)
+		mockRepo.On("AddComment", mock.Anything, "123", mock.AnythingOfType("post.Comment")).Return(expected, nil)
 
-		res, err := service.AddComment("123", "Nice post!", defaultClaims)
+		res, err := service.AddComment(ctx, "123", "Nice post!", defaultClaims)
 
 		assert.NoError(t, err)
 		assert.Equal(t, expected, res)
@@ -132,9 +138,9 @@ func TestAddComment(t *testing.T) {
 	t.Run("AddComment fail", func(t *testing.T) {
 		defer resetMock(mockRepo)
 
-		mockRepo.On("AddComment", "123", mock.AnythingOfType("post.Comment")).Return(nil, errors.New("mongo error"))
+		mockRepo.On("AddComment", mock.Anything, "123", mock.AnythingOfType("post.Comment")).Return(nil, errors.New("mongo error"))
 
-		res, err := service.AddComment("123", "Nice post!", defaultClaims)
+		res, err := service.AddComment(ctx, "123", "Nice post!", defaultClaims)
 
 		assert.Error(t, err)
 		assert.Nil(t, res)
@@ -143,12 +149,13 @@ func TestAddComment(t *testing.T) {
 }
 
 func TestRemoveComment(t *testing.T) {
+	ctx := context.Background()
 	t.Run("success", func(t *testing.T) {
 		defer resetMock(mockRepo)
 
-		mockRepo.On("RemoveComment", "123", "c1").Return(expected, nil)
+		mockRepo.On("RemoveComment", mock.Anything, "123", "c1").Return(expected, nil)
 
-		res, err := service.RemoveComment("123", "c1")
+		res, err := service.RemoveComment(ctx, "123", "c1")
 
 		assert.NoError(t, err)
 		assert.Equal(t, expected, res)
@@ -158,9 +165,9 @@ func TestRemoveComment(t *testing.T) {
 	t.Run("remove comment fail", func(t *testing.T) {
 		defer resetMock(mockRepo)
 
-		mockRepo.On("RemoveComment", "123", "c1").Return(nil, errors.New("mongo error"))
+		mockRepo.On("RemoveComment", mock.Anything, "123", "c1").Return(nil, errors.New("mongo error"))
 
-		res, err := service.RemoveComment("123", "c1")
+		res, err := service.RemoveComment(ctx, "123", "c1")
 
 		assert.Error(t, err)
 		assert.Nil(t, res)
@@ -170,12 +177,13 @@ func TestRemoveComment(t *testing.T) {
 }
 
 func TestDelete(t *testing.T) {
+	ctx := context.Background()
 	t.Run("success", func(t *testing.T) {
 		defer resetMock(mockRepo)
 
-		mockRepo.On("Delete", "123").Return(nil)
+		mockRepo.On("Delete", mock.Anything, "123").Return(nil)
 
-		err := service.Delete("123")
+		err := service.Delete(ctx, "123")
 
 		assert.NoError(t, err)
 		mockRepo.AssertExpectations(t)
@@ -184,9 +192,9 @@ func TestDelete(t *testing.T) {
 	t.Run("delete fail", func(t *testing.T) {
 		defer resetMock(mockRepo)
 
-		mockRepo.On("Delete", "123").Return(errors.New("mongo error"))
+		mockRepo.On("Delete", mock.Anything, "123").Return(errors.New("mongo error"))
 
-		err := service.Delete("123")
+		err := service.Delete(ctx, "123")
 
 		assert.Error(t, err)
 		mockRepo.AssertExpectations(t)
@@ -194,13 +202,89 @@ func TestDelete(t *testing.T) {
 
 }
 
+func TestDeleteAs(t *testing.T) {
+	ctx := context.Background()
+	owned := &post.Post{ID: "123", Author: user.User{ID: "user123"}}
+
+	t.Run("author", func(t *testing.T) {
+		defer resetMock(mockRepo)
+
+		mockRepo.On("FindByID", mock.Anything, "123").Return(owned, nil)
+		mockRepo.On("Delete", mock.Anything, "123").Return(nil)
+
+		assert.NoError(t, service.DeleteAs(ctx, "123", "user123"))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("someone else", func(t *testing.T) {
+		defer resetMock(mockRepo)
+
+		mockRepo.On("FindByID", mock.Anything, "123").Return(owned, nil)
+
+		assert.ErrorIs(t, service.DeleteAs(ctx, "123", "other"), post.ErrNotAuthor)
+		mockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+}
+
+func TestRemoveCommentAs(t *testing.T) {
+	ctx := context.Background()
+	// the author of the post does not own the comments of others
+	commented := &post.Post{ID: "123", Author: user.User{ID: "user123"}, Comments: []post.Comment{
+		{ID: "c1", Author: user.User{ID: "user123"}},
+		{ID: "c2", Author: user.User{ID: "other"}},
+	}}
+
+	t.Run("author", func(t *testing.T) {
+		defer resetMock(mockRepo)
+
+		mockRepo.On("FindByID", mock.Anything, "123").Return(commented, nil)
+		mockRepo.On("RemoveComment", mock.Anything, "123", "c1").Return(expected, nil)
+
+		res, err := service.RemoveCommentAs(ctx, "123", "c1", "user123")
+		assert.NoError(t, err)
+		assert.Equal(t, expected, res)
+		mockRepo.AssertExpectations(t)
+	})
+
+	for name, tt := range map[string]struct {
+		commID string
+		err    error
+	}{
+		"someone else": {commID: "c2", err: post.ErrNotAuthor},
+		"missing":      {commID: "c3", err: post.ErrNoComment},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer resetMock(mockRepo)
+
+			mockRepo.On("FindByID", mock.Anything, "123").Return(commented, nil)
+
+			_, err := service.RemoveCommentAs(ctx, "123", tt.commID, "user123")
+			assert.ErrorIs(t, err, tt.err)
+			mockRepo.AssertNotCalled(t, "RemoveComment", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+	defer resetMock(mockRepo)
+
+	mockRepo.On("Restore", mock.Anything, "123").Return(errors.New("post not found"))
+
+	err := service.Restore(ctx, "123")
+
+	assert.EqualError(t, err, "post not found")
+	mockRepo.AssertExpectations(t)
+}
+
 func TestAddVote(t *testing.T) {
+	ctx := context.Background()
 	t.Run("success", func(t *testing.T) {
 		defer resetMock(mockRepo)
 
-		mockRepo.On("AddVote", "123", post.Voting{User: "u", Vote: 1}).Return(expected, nil)
+		mockRepo.On("AddVote", mock.Anything, "123", post.Voting{User: "u", Vote: 1}).Return(expected, nil)
 
-		res, err := service.AddVote("123", "u", "upvote")
+		res, err := service.AddVote(ctx, "123", "u", "upvote")
 
 		assert.NoError(t, err)
 		assert.Equal(t, expected, res)
@@ -210,20 +294,20 @@ func TestAddVote(t *testing.T) {
 	t.Run("cancel/error", func(t *testing.T) {
 		defer resetMock(mockRepo)
 
-		mockRepo.On("CancelVote", "123", "u").Return(expected, nil)
-		mockRepo.On("CancelVote", "123", "oops").Return(nil, errors.New("invalid action"))
+		mockRepo.On("CancelVote", mock.Anything, "123", "u").Return(expected, nil)
+		mockRepo.On("CancelVote", mock.Anything, "123", "oops").Return(nil, errors.New("invalid action"))
 
-		res, err := service.AddVote("123", "u", "unvote")
+		res, err := service.AddVote(ctx, "123", "u", "unvote")
 
 		assert.NoError(t, err)
 		assert.Equal(t, expected, res)
 
-		res, err = service.AddVote("123", "oops", "unvote")
+		res, err = service.AddVote(ctx, "123", "oops", "unvote")
 
 		assert.Error(t, err)
 		assert.Nil(t, res)
 
-		res, err = service.AddVote("123", "user", "bad_action")
+		res, err = service.AddVote(ctx, "123", "user", "bad_action")
 
 		assert.Error(t, err)
 		assert.Equal(t, "invalid action", err.Error())
@@ -235,24 +319,122 @@ func TestAddVote(t *testing.T) {
 }
 
 func TestGetByUser(t *testing.T) {
+	ctx := context.Background()
 	defer resetMock(mockRepo)
 
 	posts := []*post.Post{{Author: user.User{Username: "u"}}}
-	mockRepo.On("GetByUser", "u").Return(posts)
+	mockRepo.On("GetByUser", mock.Anything, "u").Return(posts, nil)
 
-	res := service.GetByUser("u")
+	res, err := service.GetByUser(ctx, "u", "")
+	assert.NoError(t, err)
 
 	assert.Equal(t, posts, res)
 	mockRepo.AssertExpectations(t)
 }
 
 func TestGetByCategory(t *testing.T) {
+	ctx := context.Background()
 	defer resetMock(mockRepo)
 	posts := []*post.Post{{Category: "tech"}}
-	mockRepo.On("GetByCategory", "tech").Return(posts)
+	mockRepo.On("GetByCategory", mock.Anything, "tech").Return(posts, nil)
 
-	res := service.GetByCategory("tech")
+	res, err := service.GetByCategory(ctx, "tech", "")
+	assert.NoError(t, err)
 
 	assert.Equal(t, posts, res)
 	mockRepo.AssertExpectations(t)
 }
+
+type fakeBlocks struct {
+	hidden  map[string][]string
+	blocked map[string]bool
+}
+
+func (f *fakeBlocks) BlockedIDs(ctx context.Context, userID string) ([]string, error) {
+	return f.hidden[userID], nil
+}
+
+func (f *fakeBlocks) IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error) {
+	return f.blocked[blockerID+"/"+blockedID], nil
+}
+
+func TestBlockFiltering(t *testing.T) {
+	ctx := context.Background()
+	blocks := &fakeBlocks{
+		hidden:  map[string][]string{"viewer": {"troll"}},
+		blocked: map[string]bool{"author/troll": true},
+	}
+	svc := &post.PostService{Repo: mockRepo, Blocks: blocks}
+
+	newPosts := func() []*post.Post {
+		return []*post.Post{
+			{Title: "A", Author: user.User{ID: "author"}, Comments: []post.Comment{
+				{ID: "c1", Author: user.User{ID: "troll"}},
+				{ID: "c2", Author: user.User{ID: "friend"}},
+			}},
+			{Title: "B", Author: user.User{ID: "troll"}},
+		}
+	}
+
+	t.Run("feed hides blocked authors and comments", func(t *testing.T) {
+		defer resetMock(mockRepo)
+		mockRepo.On("GetAll", mock.Anything).Return(newPosts(), nil)
+
+		res, err := svc.GetAll(ctx, "viewer")
+		assert.NoError(t, err)
+
+		assert.Len(t, res, 1)
+		assert.Equal(t, "A", res[0].Title)
+		assert.Len(t, res[0].Comments, 1)
+		assert.Equal(t, "c2", res[0].Comments[0].ID)
+	})
+
+	t.Run("anonymous viewer sees everything", func(t *testing.T) {
+		defer resetMock(mockRepo)
+		mockRepo.On("GetAll", mock.Anything).Return(newPosts(), nil)
+
+		res, err := svc.GetAll(ctx, "")
+		assert.NoError(t, err)
+
+		assert.Len(t, res, 2)
+		assert.Len(t, res[0].Comments, 2)
+	})
+
+	t.Run("pages leave blocked authors out in the query", func(t *testing.T) {
+		defer resetMock(mockRepo)
+		filter := post.ListFilter{Category: "news", HiddenAuthors: []string{"troll"}}
+		mockRepo.On("GetPage", mock.Anything, filter, 50, 25).Return(newPosts()[:1], 51, nil)
+
+		res, total, err := svc.GetPage(ctx, post.ListFilter{Category: "news"}, "viewer", 3, 25)
+		assert.NoError(t, err)
+
+		assert.Equal(t, 51, total)
+		assert.Len(t, res, 1)
+		assert.Len(t, res[0].Comments, 1)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("post comments filtered", func(t *testing.T) {
+		defer resetMock(mockRepo)
+		mockRepo.On("GetByID", mock.Anything, "123").Return(newPosts()[0], nil)
+
+		res, err := svc.GetByID(ctx, "123", "viewer")
+
+		assert.NoError(t, err)
+		assert.Len(t, res.Comments, 1)
+	})
+
+	t.Run("blocked user cannot comment", func(t *testing.T) {
+		defer resetMock(mockRepo)
+		mockRepo.On("FindByID", mock.Anything, "123").Return(newPosts()[0], nil)
+
+		troll := &claims.Claims{}
+		troll.User.ID = "troll"
+
+		res, err := svc.AddComment(ctx, "123", "hi", troll)
+
+		assert.Nil(t, res)
+		assert.EqualError(t, err, "blocked by author")
+		mockRepo.AssertNotCalled(t, "AddComment", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
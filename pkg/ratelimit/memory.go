@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	per    time.Duration
+}
+
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+	sweepAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithClock(time.Now)
+}
+
+func NewMemoryStoreWithClock(now func() time.Time) *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		now:     now,
+	}
+}
+
+func (s *MemoryStore) Take(key string, p Policy) (bool, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	rate := float64(p.Burst) / p.Per.Seconds()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(p.Burst), last: now, per: p.Per}
+		s.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > float64(p.Burst) {
+		b.tokens = float64(p.Burst)
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets that have refilled completely, they are
+// indistinguishable from new ones and would only grow the map.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Before(s.sweepAt) {
+		return
+	}
+	s.sweepAt = now.Add(time.Minute)
+
+	for key, b := range s.buckets {
+		if now.Sub(b.last) >= b.per {
+			delete(s.buckets, key)
+		}
+	}
+}
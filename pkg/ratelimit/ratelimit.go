@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy is a token bucket: Burst requests at once, refilled at Burst per Per.
+type Policy struct {
+	Burst int
+	Per   time.Duration
+}
+
+// Store keeps bucket state. MemoryStore is the only implementation for now,
+// a shared backend (e.g. Redis) can be plugged in later behind the same interface.
+type Store interface {
+	Take(key string, p Policy) (allowed bool, retryAfter time.Duration)
+}
+
+// ParsePolicy reads a policy written as "<burst>/<duration>", e.g. "5/1m".
+func ParsePolicy(s string) (Policy, error) {
+	burst, per, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("bad rate limit policy %q", s)
+	}
+
+	n, err := strconv.Atoi(burst)
+	if err != nil || n <= 0 {
+		return Policy{}, fmt.Errorf("bad rate limit burst %q", burst)
+	}
+
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Policy{}, fmt.Errorf("bad rate limit period %q", per)
+	}
+
+	return Policy{Burst: n, Per: d}, nil
+}
+
+// DefaultPolicies are the policies of the limited routes, keyed by mux
+// route name.
+var DefaultPolicies = map[string]string{
+	"login":                  "10/1m",
+	"login_2fa":              "10/1m",
+	"oidc_login":             "20/1m",
+	"oidc_callback":          "20/1m",
+	"2fa_confirm":            "10/10m",
+	"2fa_disable":            "5/10m",
+	"register":               "5/1h",
+	"posts":                  "10/1h",
+	"comment":                "30/10m",
+	"vote":                   "60/1m",
+	"password":               "5/10m",
+	"password_reset":         "5/1h",
+	"password_reset_confirm": "10/1h",
+	"email":                  "5/1h",
+	"email_resend":           "5/1h",
+	"email_verify":           "10/1h",
+	"tokens":                 "10/1h",
+}
+
+// EnvKey is the setting that overrides the policy of route.
+func EnvKey(route string) string {
+	return "RATE_LIMIT_" + strings.ToUpper(route)
+}
+
+// PoliciesFrom builds per-route policies keyed by mux route name. Every
+// route in DefaultPolicies has a default that RATE_LIMIT_<ROUTE>, read
+// through getenv, overrides. The error lists every bad policy.
+func PoliciesFrom(getenv func(string) string) (map[string]Policy, error) {
+	routes := make([]string, 0, len(DefaultPolicies))
+	for route := range DefaultPolicies {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	policies := make(map[string]Policy, len(routes))
+	var errs []error
+	for _, route := range routes {
+		raw := getenv(EnvKey(route))
+		if raw == "" {
+			raw = DefaultPolicies[route]
+		}
+		p, err := ParsePolicy(raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", EnvKey(route), err))
+			continue
+		}
+		policies[route] = p
+	}
+	return policies, errors.Join(errs...)
+}
@@ -0,0 +1,60 @@
+package ratelimit_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"redditclone/pkg/ratelimit"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePolicy(t *testing.T) {
+	p, err := ratelimit.ParsePolicy("5/1m")
+	assert.NoError(t, err)
+	assert.Equal(t, ratelimit.Policy{Burst: 5, Per: time.Minute}, p)
+
+	for _, bad := range []string{"", "5", "0/1m", "x/1m", "5/soon", "5/-1s"} {
+		_, err := ratelimit.ParsePolicy(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestPoliciesFrom(t *testing.T) {
+	t.Setenv("RATE_LIMIT_LOGIN", "3/30s")
+
+	policies, err := ratelimit.PoliciesFrom(os.Getenv)
+	assert.NoError(t, err)
+	assert.Equal(t, ratelimit.Policy{Burst: 3, Per: 30 * time.Second}, policies["login"])
+	assert.Contains(t, policies, "vote")
+
+	t.Setenv("RATE_LIMIT_VOTE", "lots")
+	_, err = ratelimit.PoliciesFrom(os.Getenv)
+	assert.ErrorContains(t, err, "RATE_LIMIT_VOTE")
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := ratelimit.NewMemoryStoreWithClock(func() time.Time { return now })
+	policy := ratelimit.Policy{Burst: 2, Per: time.Minute}
+
+	ok, _ := store.Take("ip:login:1.2.3.4", policy)
+	assert.True(t, ok)
+	ok, _ = store.Take("ip:login:1.2.3.4", policy)
+	assert.True(t, ok)
+
+	ok, retry := store.Take("ip:login:1.2.3.4", policy)
+	assert.False(t, ok)
+	assert.Equal(t, 30*time.Second, retry)
+
+	// other keys have their own bucket
+	ok, _ = store.Take("ip:login:5.6.7.8", policy)
+	assert.True(t, ok)
+
+	now = now.Add(30 * time.Second)
+	ok, _ = store.Take("ip:login:1.2.3.4", policy)
+	assert.True(t, ok)
+	ok, _ = store.Take("ip:login:1.2.3.4", policy)
+	assert.False(t, ok)
+}
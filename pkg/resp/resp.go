@@ -0,0 +1,292 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Error is an error reply sent by the server. It leaves the connection
+// usable, unlike network and protocol errors.
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+var ErrProtocol = errors.New("resp: protocol error")
+
+// Client speaks the Redis protocol over a small pool of connections. Replies
+// come back as string, int64, []any or nil.
+type Client struct {
+	Addr     string
+	Password string
+	DB       int
+	Timeout  time.Duration
+	MaxIdle  int
+
+	mu   sync.Mutex
+	idle []*Conn
+}
+
+func NewClient(addr, password string, db int) *Client {
+	return &Client{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+		Timeout:  3 * time.Second,
+		MaxIdle:  8,
+	}
+}
+
+// Do runs a single command.
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	var reply any
+	err := c.With(ctx, func(conn *Conn) error {
+		var err error
+		reply, err = conn.Do(args...)
+		return err
+	})
+	return reply, err
+}
+
+// With runs fn on one connection, for commands that have to share it such
+// as WATCH and MULTI. The deadline of ctx bounds every command, cancelling ctx
+// interrupts the one in flight.
+func (c *Client) With(ctx context.Context, fn func(conn *Conn) error) error {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	conn.ctx = ctx
+	stop := context.AfterFunc(ctx, func() { conn.nc.SetDeadline(time.Unix(1, 0)) })
+	err = fn(conn)
+	conn.ctx = nil
+	if !stop() {
+		// ctx ended while the connection was in use, it may be left in the
+		// middle of a reply
+		conn.Close()
+		if err != nil && !errors.Is(err, ctx.Err()) {
+			err = errors.Join(ctx.Err(), err)
+		}
+		return err
+	}
+	var reply Error
+	if err == nil || errors.As(err, &reply) {
+		c.put(conn)
+	} else {
+		conn.Close()
+	}
+	return err
+}
+
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, conn := range c.idle {
+		conn.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+func (c *Client) get(ctx context.Context) (*Conn, error) {
+	c.mu.Lock()
+	if n := len(c.idle); n > 0 {
+		conn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return conn, nil
+	}
+	c.mu.Unlock()
+	return c.dial(ctx)
+}
+
+func (c *Client) put(conn *Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.idle) >= c.MaxIdle {
+		conn.Close()
+		return
+	}
+	c.idle = append(c.idle, conn)
+}
+
+func (c *Client) dial(ctx context.Context) (*Conn, error) {
+	dialer := net.Dialer{Timeout: c.Timeout}
+	nc, err := dialer.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &Conn{
+		nc:      nc,
+		r:       bufio.NewReader(nc),
+		w:       bufio.NewWriter(nc),
+		timeout: c.Timeout,
+		ctx:     ctx,
+	}
+
+	if c.Password != "" {
+		if _, err := conn.Do("AUTH", c.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if c.DB != 0 {
+		if _, err := conn.Do("SELECT", strconv.Itoa(c.DB)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+type Conn struct {
+	nc      net.Conn
+	r       *bufio.Reader
+	w       *bufio.Writer
+	timeout time.Duration
+	// ctx is the context of the Client.With call using the connection.
+	ctx context.Context
+}
+
+func (c *Conn) Do(args ...string) (any, error) {
+	var deadline time.Time
+	if c.timeout > 0 {
+		deadline = time.Now().Add(c.timeout)
+	}
+	if c.ctx != nil {
+		if err := c.ctx.Err(); err != nil {
+			return nil, err
+		}
+		if d, ok := c.ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+			deadline = d
+		}
+	}
+	c.nc.SetDeadline(deadline)
+
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, c.interrupted(err)
+	}
+
+	reply, err := ReadReply(c.r)
+	return reply, c.interrupted(err)
+}
+
+// interrupted puts the context error in front of the I/O error it caused.
+func (c *Conn) interrupted(err error) error {
+	if err != nil && c.ctx != nil && c.ctx.Err() != nil && !errors.Is(err, c.ctx.Err()) {
+		return errors.Join(c.ctx.Err(), err)
+	}
+	return err
+}
+
+func (c *Conn) Close() error {
+	return c.nc.Close()
+}
+
+// ReadReply reads one reply. An error reply is returned as Error.
+func ReadReply(r *bufio.Reader) (any, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, ErrProtocol
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, ErrProtocol
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < -1 {
+			return nil, ErrProtocol
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < -1 {
+			return nil, ErrProtocol
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			item, err := ReadReply(r)
+			var reply Error
+			if err != nil && !errors.As(err, &reply) {
+				return nil, err
+			}
+			if err != nil {
+				items[i] = reply
+			} else {
+				items[i] = item
+			}
+		}
+		return items, nil
+	default:
+		return nil, ErrProtocol
+	}
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", ErrProtocol
+	}
+	return line[:len(line)-2], nil
+}
+
+// Strings converts an array reply of bulk strings.
+func Strings(reply any, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, nil
+	}
+	items, ok := reply.([]any)
+	if !ok {
+		return nil, fmt.Errorf("resp: unexpected reply %T", reply)
+	}
+	out := make([]string, len(items))
+	for i, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("resp: unexpected reply %T", item)
+		}
+		out[i] = s
+	}
+	return out, nil
+}
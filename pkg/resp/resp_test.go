@@ -0,0 +1,189 @@
+package resp_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"redditclone/pkg/resp"
+	"redditclone/pkg/resp/resptest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  any
+		err   error
+	}{
+		{name: "simple string", input: "+OK\r\n", want: "OK"},
+		{name: "error", input: "-ERR wrong type\r\n", err: resp.Error("ERR wrong type")},
+		{name: "integer", input: ":42\r\n", want: int64(42)},
+		{name: "bulk", input: "$12\r\nhello\r\nworld\r\n", want: "hello\r\nworld"},
+		{name: "empty bulk", input: "$0\r\n\r\n", want: ""},
+		{name: "nil bulk", input: "$-1\r\n", want: nil},
+		{name: "array", input: "*3\r\n$1\r\na\r\n:1\r\n*1\r\n+b\r\n", want: []any{"a", int64(1), []any{"b"}}},
+		{name: "empty array", input: "*0\r\n", want: []any{}},
+		{name: "nil array", input: "*-1\r\n", want: nil},
+		// EXEC answers a transaction with an error per failed command
+		{name: "array with error", input: "*2\r\n+OK\r\n-ERR no such key\r\n", want: []any{"OK", resp.Error("ERR no such key")}},
+		{name: "unknown type", input: "?x\r\n", err: resp.ErrProtocol},
+		{name: "bad integer", input: ":x\r\n", err: resp.ErrProtocol},
+		{name: "bad length", input: "$-2\r\n", err: resp.ErrProtocol},
+		{name: "no CR", input: "+OK\n", err: resp.ErrProtocol},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply, err := resp.ReadReply(bufio.NewReader(strings.NewReader(tt.input)))
+			if tt.err != nil {
+				assert.Equal(t, tt.err, err)
+				assert.Nil(t, reply)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, reply)
+		})
+	}
+}
+
+func TestReadReply_Truncated(t *testing.T) {
+	_, err := resp.ReadReply(bufio.NewReader(strings.NewReader("$5\r\nhel")))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestClient_ExecAborted(t *testing.T) {
+	ctx := context.Background()
+	srv := resptest.NewServer()
+	t.Cleanup(srv.Close)
+	client := resp.NewClient(srv.Addr, "", 0)
+	t.Cleanup(func() { client.Close() })
+
+	err := client.With(ctx, func(conn *resp.Conn) error {
+		if _, err := conn.Do("WATCH", "k"); err != nil {
+			return err
+		}
+		srv.Touched("k")
+		if _, err := conn.Do("MULTI"); err != nil {
+			return err
+		}
+		queued, err := conn.Do("SET", "k", "v")
+		assert.Equal(t, "QUEUED", queued)
+		if err != nil {
+			return err
+		}
+
+		reply, err := conn.Do("EXEC")
+		assert.Nil(t, reply)
+		return err
+	})
+	assert.NoError(t, err)
+
+	value, err := client.Do(ctx, "GET", "k")
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+}
+
+// scriptedServer answers the commands it reads with replies, in order, over
+// whichever connection asks next. It counts the connections it accepts.
+type scriptedServer struct {
+	ln      net.Listener
+	mu      sync.Mutex
+	replies []string
+	accepts int
+}
+
+func newScriptedServer(t *testing.T, replies ...string) *scriptedServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := &scriptedServer{ln: ln, replies: replies}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.accepts++
+			s.mu.Unlock()
+			go s.handle(nc)
+		}
+	}()
+	return s
+}
+
+func (s *scriptedServer) handle(nc net.Conn) {
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+	for {
+		if _, err := resp.ReadReply(r); err != nil {
+			return
+		}
+		s.mu.Lock()
+		reply := s.replies[0]
+		s.replies = s.replies[1:]
+		s.mu.Unlock()
+		if _, err := nc.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *scriptedServer) Accepts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accepts
+}
+
+func TestClient_ConnectionReuse(t *testing.T) {
+	ctx := context.Background()
+	srv := newScriptedServer(t,
+		"-WRONGTYPE Operation against a key holding the wrong kind of value\r\n",
+		"$1\r\nv\r\n",
+		"%garbage\r\n",
+		"+PONG\r\n",
+	)
+	client := resp.NewClient(srv.ln.Addr().String(), "", 0)
+	t.Cleanup(func() { client.Close() })
+
+	// an error reply leaves the connection in a known state, so it is kept
+	_, err := client.Do(ctx, "HGET", "k", "f")
+	var reply resp.Error
+	assert.True(t, errors.As(err, &reply))
+
+	v, err := client.Do(ctx, "GET", "k")
+	assert.NoError(t, err)
+	assert.Equal(t, "v", v)
+	assert.Equal(t, 1, srv.Accepts())
+
+	// after a protocol error the connection is dropped and a new one dialled
+	_, err = client.Do(ctx, "GET", "k")
+	assert.ErrorIs(t, err, resp.ErrProtocol)
+
+	v, err = client.Do(ctx, "PING")
+	assert.NoError(t, err)
+	assert.Equal(t, "PONG", v)
+	assert.Equal(t, 2, srv.Accepts())
+}
+
+func TestStrings(t *testing.T) {
+	out, err := resp.Strings([]any{"a", "b"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, out)
+
+	out, err = resp.Strings(nil, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, out)
+
+	_, err = resp.Strings([]any{"a", int64(1)}, nil)
+	assert.EqualError(t, err, "resp: unexpected reply int64")
+}
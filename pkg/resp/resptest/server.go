@@ -0,0 +1,378 @@
+// Package resptest runs an in-memory server speaking the Redis protocol for
+// tests. It knows the string, hash and expiry commands the app uses, plus
+// WATCH/MULTI/EXEC.
+package resptest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"redditclone/pkg/resp"
+)
+
+type entry struct {
+	value    string
+	hash     map[string]string
+	expireAt time.Time
+}
+
+type Server struct {
+	Addr     string
+	Password string
+
+	mu       sync.Mutex
+	now      func() time.Time
+	data     map[string]*entry
+	versions map[string]int
+	commands int
+	ln       net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+func NewServer() *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	s := &Server{
+		Addr:     ln.Addr().String(),
+		now:      time.Now,
+		data:     map[string]*entry{},
+		versions: map[string]int{},
+		ln:       ln,
+		conns:    map[net.Conn]struct{}{},
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// SetNow replaces the clock used for key expiry.
+func (s *Server) SetNow(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// Commands reports how many commands the server has run.
+func (s *Server) Commands() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands
+}
+
+// Touched bumps the version of key as if another client wrote it, which
+// aborts transactions watching it.
+func (s *Server) Touched(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.versions[key]++
+}
+
+func (s *Server) Close() {
+	s.ln.Close()
+	s.mu.Lock()
+	for nc := range s.conns {
+		nc.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[nc] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.handle(nc)
+	}
+}
+
+type client struct {
+	authed  bool
+	watched map[string]int
+	queue   [][]string
+	inMulti bool
+}
+
+func (s *Server) handle(nc net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, nc)
+		s.mu.Unlock()
+		nc.Close()
+	}()
+
+	r := bufio.NewReader(nc)
+	w := bufio.NewWriter(nc)
+	c := &client{authed: s.Password == ""}
+
+	for {
+		req, err := resp.ReadReply(r)
+		if err != nil {
+			return
+		}
+		args, err := resp.Strings(req, nil)
+		if err != nil || len(args) == 0 {
+			return
+		}
+
+		writeReply(w, s.exec(c, args))
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) exec(c *client, args []string) any {
+	cmd := strings.ToUpper(args[0])
+
+	if !c.authed && cmd != "AUTH" {
+		return resp.Error("NOAUTH Authentication required.")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands++
+
+	switch cmd {
+	case "AUTH":
+		if len(args) != 2 || args[1] != s.Password {
+			return resp.Error("WRONGPASS invalid password")
+		}
+		c.authed = true
+		return "OK"
+	case "WATCH":
+		if c.watched == nil {
+			c.watched = map[string]int{}
+		}
+		for _, key := range args[1:] {
+			c.watched[key] = s.versions[key]
+		}
+		return "OK"
+	case "UNWATCH":
+		c.watched = nil
+		return "OK"
+	case "MULTI":
+		c.inMulti = true
+		c.queue = nil
+		return "OK"
+	case "DISCARD":
+		c.inMulti, c.queue, c.watched = false, nil, nil
+		return "OK"
+	case "EXEC":
+		queue, watched := c.queue, c.watched
+		c.inMulti, c.queue, c.watched = false, nil, nil
+		for key, version := range watched {
+			if s.versions[key] != version {
+				return nil
+			}
+		}
+		replies := make([]any, len(queue))
+		for i, q := range queue {
+			replies[i] = s.run(q)
+		}
+		return replies
+	}
+
+	if c.inMulti {
+		c.queue = append(c.queue, args)
+		return "QUEUED"
+	}
+	return s.run(args)
+}
+
+func (s *Server) run(args []string) any {
+	cmd := strings.ToUpper(args[0])
+	now := s.now()
+	for key, e := range s.data {
+		if !e.expireAt.IsZero() && !now.Before(e.expireAt) {
+			delete(s.data, key)
+			s.versions[key]++
+		}
+	}
+
+	wrongArgs := resp.Error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd)))
+	wrongType := resp.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+	switch cmd {
+	case "PING":
+		return "PONG"
+	case "SELECT", "FLUSHDB":
+		if cmd == "FLUSHDB" {
+			s.data = map[string]*entry{}
+		}
+		return "OK"
+	case "GET":
+		if len(args) != 2 {
+			return wrongArgs
+		}
+		e, ok := s.data[args[1]]
+		if !ok {
+			return nil
+		}
+		if e.hash != nil {
+			return wrongType
+		}
+		return e.value
+	case "SET":
+		if len(args) != 3 {
+			return wrongArgs
+		}
+		s.data[args[1]] = &entry{value: args[2]}
+		s.versions[args[1]]++
+		return "OK"
+	case "DEL", "EXISTS":
+		var n int64
+		for _, key := range args[1:] {
+			if _, ok := s.data[key]; ok {
+				n++
+				if cmd == "DEL" {
+					delete(s.data, key)
+					s.versions[key]++
+				}
+			}
+		}
+		return n
+	case "HSET":
+		if len(args) < 4 || len(args)%2 != 0 {
+			return wrongArgs
+		}
+		e, ok := s.data[args[1]]
+		if !ok {
+			e = &entry{hash: map[string]string{}}
+			s.data[args[1]] = e
+		}
+		if e.hash == nil {
+			return wrongType
+		}
+		var added int64
+		for i := 2; i < len(args); i += 2 {
+			if _, ok := e.hash[args[i]]; !ok {
+				added++
+			}
+			e.hash[args[i]] = args[i+1]
+		}
+		s.versions[args[1]]++
+		return added
+	case "HDEL":
+		if len(args) < 3 {
+			return wrongArgs
+		}
+		e, ok := s.data[args[1]]
+		if !ok {
+			return int64(0)
+		}
+		if e.hash == nil {
+			return wrongType
+		}
+		var n int64
+		for _, field := range args[2:] {
+			if _, ok := e.hash[field]; ok {
+				delete(e.hash, field)
+				n++
+			}
+		}
+		if len(e.hash) == 0 {
+			delete(s.data, args[1])
+		}
+		s.versions[args[1]]++
+		return n
+	case "HGET":
+		if len(args) != 3 {
+			return wrongArgs
+		}
+		e, ok := s.data[args[1]]
+		if !ok {
+			return nil
+		}
+		if e.hash == nil {
+			return wrongType
+		}
+		if v, ok := e.hash[args[2]]; ok {
+			return v
+		}
+		return nil
+	case "HGETALL":
+		if len(args) != 2 {
+			return wrongArgs
+		}
+		e, ok := s.data[args[1]]
+		if !ok {
+			return []any{}
+		}
+		if e.hash == nil {
+			return wrongType
+		}
+		out := make([]any, 0, len(e.hash)*2)
+		for field, value := range e.hash {
+			out = append(out, field, value)
+		}
+		return out
+	case "PEXPIREAT":
+		if len(args) != 3 {
+			return wrongArgs
+		}
+		ms, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return resp.Error("ERR value is not an integer or out of range")
+		}
+		e, ok := s.data[args[1]]
+		if !ok {
+			return int64(0)
+		}
+		e.expireAt = time.UnixMilli(ms)
+		s.versions[args[1]]++
+		return int64(1)
+	case "PTTL":
+		if len(args) != 2 {
+			return wrongArgs
+		}
+		e, ok := s.data[args[1]]
+		if !ok {
+			return int64(-2)
+		}
+		if e.expireAt.IsZero() {
+			return int64(-1)
+		}
+		return e.expireAt.Sub(now).Milliseconds()
+	default:
+		return resp.Error(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+}
+
+func writeReply(w *bufio.Writer, reply any) {
+	switch v := reply.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case resp.Error:
+		fmt.Fprintf(w, "-%s\r\n", string(v))
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case string:
+		if v == "OK" || v == "PONG" || v == "QUEUED" {
+			fmt.Fprintf(w, "+%s\r\n", v)
+		} else {
+			fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+		}
+	case []any:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeReply(w, item)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package secretbox_test
+
+import (
+	"testing"
+
+	"redditclone/pkg/secretbox"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBox(t *testing.T) {
+	_, err := secretbox.New("c2hvcnQ=")
+	assert.Error(t, err)
+
+	box, err := secretbox.New("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	assert.NoError(t, err)
+
+	sealed, err := box.Seal("JBSWY3DPEHPK3PXP")
+	assert.NoError(t, err)
+	assert.NotContains(t, sealed, "JBSWY3DPEHPK3PXP")
+
+	again, err := box.Seal("JBSWY3DPEHPK3PXP")
+	assert.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "nonce must be random")
+
+	plain, err := box.Open(sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", plain)
+
+	tampered := []byte(sealed)
+	tampered[len(tampered)-3] ^= 1
+	_, err = box.Open(string(tampered))
+	assert.Error(t, err)
+}
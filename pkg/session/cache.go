@@ -0,0 +1,142 @@
+package session
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultCacheSize        = 10000
+	DefaultCacheTTL         = 5 * time.Second
+	DefaultCacheNegativeTTL = time.Second
+)
+
+// cacheEntry holds what is known about the sessions of a user, so writes
+// can drop all of them at once.
+type cacheEntry struct {
+	userID   string
+	sessions map[string]cachedSession
+	touched  map[string]time.Time
+}
+
+type cachedSession struct {
+	valid bool
+	until time.Time
+}
+
+// Cache sits in front of a Repository and answers IsValid from memory for a
+// short while, TTL for live sessions and NegativeTTL for missing ones. It
+// also skips Touch calls for sessions touched less than TTL ago.
+//
+// Writes through the cache drop the user from it right away, writes made by
+// other instances show up once the entry runs out.
+type Cache struct {
+	Repo        Repository
+	Size        int
+	TTL         time.Duration
+	NegativeTTL time.Duration
+	Now         func() time.Time
+
+	mu    sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+}
+
+func NewCache(repo Repository, size int, ttl, negativeTTL time.Duration) *Cache {
+	return &Cache{
+		Repo:        repo,
+		Size:        size,
+		TTL:         ttl,
+		NegativeTTL: negativeTTL,
+		Now:         time.Now,
+		order:       list.New(),
+		items:       make(map[string]*list.Element),
+	}
+}
+
+func (c *Cache) Create(ctx context.Context, userID string, sessionID string) (string, error) {
+	id, err := c.Repo.Create(ctx, userID, sessionID)
+	c.forget(userID)
+	return id, err
+}
+
+func (c *Cache) IsValid(ctx context.Context, userID, sessionID string) (bool, error) {
+	now := c.Now()
+
+	c.mu.Lock()
+	if el, ok := c.items[userID]; ok {
+		cached, ok := el.Value.(*cacheEntry).sessions[sessionID]
+		if ok && now.Before(cached.until) {
+			c.order.MoveToFront(el)
+			c.mu.Unlock()
+			return cached.valid, nil
+		}
+	}
+	c.mu.Unlock()
+
+	valid, err := c.Repo.IsValid(ctx, userID, sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	ttl := c.NegativeTTL
+	if valid {
+		ttl = c.TTL
+	}
+	if ttl > 0 {
+		c.mu.Lock()
+		c.entry(userID).sessions[sessionID] = cachedSession{valid: valid, until: now.Add(ttl)}
+		c.mu.Unlock()
+	}
+	return valid, nil
+}
+
+func (c *Cache) Invalidate(ctx context.Context, userID string) error {
+	err := c.Repo.Invalidate(ctx, userID)
+	c.forget(userID)
+	return err
+}
+
+func (c *Cache) Touch(ctx context.Context, userID, sessionID string) error {
+	now := c.Now()
+
+	c.mu.Lock()
+	e := c.entry(userID)
+	if now.Sub(e.touched[sessionID]) < c.TTL {
+		c.mu.Unlock()
+		return nil
+	}
+	e.touched[sessionID] = now
+	c.mu.Unlock()
+
+	return c.Repo.Touch(ctx, userID, sessionID)
+}
+
+// entry returns the entry of userID, adding an expired one and evicting the
+// least recently used if it is missing. c.mu must be held.
+func (c *Cache) entry(userID string) *cacheEntry {
+	if el, ok := c.items[userID]; ok {
+		c.order.MoveToFront(el)
+		return el.Value.(*cacheEntry)
+	}
+
+	e := &cacheEntry{userID: userID, sessions: map[string]cachedSession{}, touched: map[string]time.Time{}}
+	c.items[userID] = c.order.PushFront(e)
+	for c.order.Len() > c.Size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).userID)
+	}
+	return e
+}
+
+func (c *Cache) forget(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[userID]; ok {
+		c.order.Remove(el)
+		delete(c.items, userID)
+	}
+}
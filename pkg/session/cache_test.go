@@ -0,0 +1,133 @@
+package session_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"redditclone/pkg/session"
+)
+
+// countingRepo keeps the live sessions as "user/session".
+type countingRepo struct {
+	valid   map[string]bool
+	checks  int
+	touches int
+}
+
+func (r *countingRepo) Create(ctx context.Context, userID, sessionID string) (string, error) {
+	r.valid[userID+"/"+sessionID] = true
+	return sessionID, nil
+}
+
+func (r *countingRepo) IsValid(ctx context.Context, userID, sessionID string) (bool, error) {
+	r.checks++
+	return r.valid[userID+"/"+sessionID], nil
+}
+
+func (r *countingRepo) Invalidate(ctx context.Context, userID string) error {
+	for key := range r.valid {
+		if strings.HasPrefix(key, userID+"/") {
+			delete(r.valid, key)
+		}
+	}
+	return nil
+}
+
+func (r *countingRepo) Touch(ctx context.Context, userID, sessionID string) error {
+	r.touches++
+	return nil
+}
+
+func TestCache_IsValid(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo := &countingRepo{valid: map[string]bool{"alice/s1": true}}
+	cache := session.NewCache(repo, 10, 5*time.Second, time.Second)
+	cache.Now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		valid, err := cache.IsValid(ctx, "alice", "s1")
+		assert.NoError(t, err)
+		assert.True(t, valid)
+	}
+	assert.Equal(t, 1, repo.checks)
+
+	// misses are cached for the shorter negative TTL
+	valid, err := cache.IsValid(ctx, "bob", "s1")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+	now = now.Add(2 * time.Second)
+	_, _ = cache.IsValid(ctx, "bob", "s1")
+	_, _ = cache.IsValid(ctx, "alice", "s1")
+	assert.Equal(t, 3, repo.checks)
+
+	now = now.Add(4 * time.Second)
+	_, _ = cache.IsValid(ctx, "alice", "s1")
+	assert.Equal(t, 4, repo.checks)
+}
+
+func TestCache_WritesDropEntries(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo := &countingRepo{valid: map[string]bool{"alice/s1": true}}
+	cache := session.NewCache(repo, 10, time.Minute, time.Minute)
+	cache.Now = func() time.Time { return now }
+
+	_, _ = cache.IsValid(ctx, "alice", "s1")
+	assert.NoError(t, cache.Invalidate(ctx, "alice"))
+	valid, err := cache.IsValid(ctx, "alice", "s1")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+
+	_, err = cache.Create(ctx, "alice", "s2")
+	assert.NoError(t, err)
+	valid, err = cache.IsValid(ctx, "alice", "s2")
+	assert.NoError(t, err)
+	assert.True(t, valid)
+	// the other sessions stay dropped
+	valid, err = cache.IsValid(ctx, "alice", "s1")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+}
+
+func TestCache_Eviction(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo := &countingRepo{valid: map[string]bool{"a/s1": true, "b/s1": true, "c/s1": true}}
+	cache := session.NewCache(repo, 2, time.Minute, time.Minute)
+	cache.Now = func() time.Time { return now }
+
+	_, _ = cache.IsValid(ctx, "a", "s1")
+	_, _ = cache.IsValid(ctx, "b", "s1")
+	_, _ = cache.IsValid(ctx, "a", "s1")
+	_, _ = cache.IsValid(ctx, "c", "s1") // evicts b, the least recently used
+	assert.Equal(t, 3, repo.checks)
+
+	_, _ = cache.IsValid(ctx, "a", "s1")
+	assert.Equal(t, 3, repo.checks)
+	_, _ = cache.IsValid(ctx, "b", "s1")
+	assert.Equal(t, 4, repo.checks)
+}
+
+func TestCache_TouchThrottled(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo := &countingRepo{valid: map[string]bool{}}
+	cache := session.NewCache(repo, 10, 5*time.Second, time.Second)
+	cache.Now = func() time.Time { return now }
+
+	assert.NoError(t, cache.Touch(ctx, "alice", "s1"))
+	assert.NoError(t, cache.Touch(ctx, "alice", "s1"))
+	assert.Equal(t, 1, repo.touches)
+
+	// another session of the same user is not held back
+	assert.NoError(t, cache.Touch(ctx, "alice", "s2"))
+	assert.Equal(t, 2, repo.touches)
+
+	now = now.Add(5 * time.Second)
+	assert.NoError(t, cache.Touch(ctx, "alice", "s1"))
+	assert.Equal(t, 3, repo.touches)
+}
@@ -0,0 +1,10 @@
+package session
+
+import "redditclone/pkg/apperr"
+
+var (
+	// ErrInvalid is returned for tokens without a live session behind them.
+	ErrInvalid   = apperr.New(apperr.Unauthorized, "invalid_session", "invalid session")
+	ErrConflict  = apperr.New(apperr.Conflict, "session_conflict", "session conflict")
+	ErrMalformed = apperr.New(apperr.Internal, "malformed_session", "malformed session")
+)
@@ -0,0 +1,79 @@
+package session
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type Purger interface {
+	DeleteExpired(ctx context.Context, now time.Time, limit int) (int64, error)
+}
+
+// Janitor deletes expired sessions in the background, in batches so a large
+// backlog never holds long locks on the table.
+type Janitor struct {
+	Repo      Purger
+	Interval  time.Duration
+	BatchSize int
+	Logger    *slog.Logger
+	Now       func() time.Time
+}
+
+func NewJanitor(repo Purger, interval time.Duration, batchSize int, logger *slog.Logger) *Janitor {
+	return &Janitor{
+		Repo:      repo,
+		Interval:  interval,
+		BatchSize: batchSize,
+		Logger:    logger,
+		Now:       time.Now,
+	}
+}
+
+// Purge deletes batches until no expired session is left and returns how
+// many were removed.
+func (j *Janitor) Purge(ctx context.Context) (int64, error) {
+	now := j.Now()
+	var total int64
+	for {
+		n, err := j.Repo.DeleteExpired(ctx, now, j.BatchSize)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < int64(j.BatchSize) {
+			return total, nil
+		}
+	}
+}
+
+// Start runs Purge every Interval. The returned stop cancels a running purge
+// and waits for it to return.
+func (j *Janitor) Start() (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(j.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n, err := j.Purge(ctx)
+				if err != nil {
+					j.Logger.Error("session cleanup", "error", err, "deleted", n)
+				} else if n > 0 {
+					j.Logger.Info("session cleanup", "deleted", n)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-finished
+	}
+}
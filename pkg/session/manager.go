@@ -1,41 +1,120 @@
 package session
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"time"
+
+	"redditclone/pkg/dbcall"
 )
 
 type MySQLSessionRepo struct {
 	DB *sql.DB
+	// TTL is how long a session lives without activity.
+	TTL time.Duration
+	// MaxLifetime caps sliding expiration, counted from creation.
+	MaxLifetime time.Duration
+	Now         func() time.Time
 }
 
 func NewMySQLSessionRepo(db *sql.DB) *MySQLSessionRepo {
-	return &MySQLSessionRepo{DB: db}
+	return &MySQLSessionRepo{
+		DB:          db,
+		TTL:         DefaultTTL,
+		MaxLifetime: DefaultMaxLifetime,
+		Now:         time.Now,
+	}
 }
 
-func (r *MySQLSessionRepo) Create(userID string, sessionID string) (string, error) {
-	_, err := r.DB.Exec(`
+func (r *MySQLSessionRepo) Create(ctx context.Context, userID string, sessionID string) (string, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "sessions", "Create")
+	defer done()
+	now := r.Now().UTC()
+	_, err := r.DB.ExecContext(ctx, `
 		INSERT INTO sessions (id, user_id, created_at, expires_at)
 		VALUES (?, ?, ?, ?)
-	`, sessionID, userID, time.Now(), time.Now().Add(time.Hour*1))
+	`, sessionID, userID, now, now.Add(r.TTL))
 
 	return sessionID, err
 }
 
-func (r *MySQLSessionRepo) IsValid(userID string) (bool, error) {
+func (r *MySQLSessionRepo) IsValid(ctx context.Context, userID, sessionID string) (bool, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "sessions", "IsValid")
+	defer done()
 	var exists bool
-	err := r.DB.QueryRow(`
+	err := r.DB.QueryRowContext(ctx, `
 		SELECT EXISTS (
-			SELECT 1 FROM sessions 
-			WHERE user_id = ? AND expires_at > ?
+			SELECT 1 FROM sessions
+			WHERE id = ? AND user_id = ? AND expires_at > ?
 		)
-	`, userID, time.Now().UTC()).Scan(&exists)
+	`, sessionID, userID, r.Now().UTC()).Scan(&exists)
 	return exists, err
 }
 
-func (r *MySQLSessionRepo) Invalidate(userID string) error {
-	_, err := r.DB.Exec(`
+func (r *MySQLSessionRepo) Invalidate(ctx context.Context, userID string) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "sessions", "Invalidate")
+	defer done()
+	_, err := r.DB.ExecContext(ctx, `
 		DELETE FROM sessions WHERE user_id = ?
 	`, userID)
 	return err
 }
+
+// Touch slides the expiry of the session to TTL from now, never past
+// MaxLifetime. To keep writes rare a session is only extended once less than
+// half of its TTL is left.
+func (r *MySQLSessionRepo) Touch(ctx context.Context, userID, sessionID string) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "sessions", "Touch")
+	defer done()
+	now := r.Now().UTC()
+
+	var s Session
+	err := r.DB.QueryRowContext(ctx, `
+		SELECT created_at, expires_at FROM sessions
+		WHERE id = ? AND user_id = ? AND expires_at > ? AND expires_at < ?
+	`, sessionID, userID, now, now.Add(r.TTL/2)).Scan(&s.CreatedAt, &s.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	expires := now.Add(r.TTL)
+	if limit := s.CreatedAt.Add(r.MaxLifetime); r.MaxLifetime > 0 && expires.After(limit) {
+		expires = limit
+	}
+	if !expires.After(s.ExpiresAt) {
+		return nil
+	}
+	_, err = r.DB.ExecContext(ctx, "UPDATE sessions SET expires_at = ? WHERE id = ? AND user_id = ?", expires.UTC(), sessionID, userID)
+	return err
+}
+
+// DeleteExpired removes up to limit sessions that expired before now.
+func (r *MySQLSessionRepo) DeleteExpired(ctx context.Context, now time.Time, limit int) (int64, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "sessions", "DeleteExpired")
+	defer done()
+	// the derived table lets MySQL take a LIMIT in the subquery
+	res, err := r.DB.ExecContext(ctx, `
+		DELETE FROM sessions WHERE id IN (
+			SELECT id FROM (
+				SELECT id FROM sessions WHERE expires_at <= ? LIMIT ?
+			) AS expired
+		)
+	`, now.UTC(), limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// CountActive returns how many sessions have not expired yet.
+func (r *MySQLSessionRepo) CountActive(ctx context.Context) (int64, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "sessions", "CountActive")
+	defer done()
+	var n int64
+	err := r.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM sessions WHERE expires_at > ?", r.Now().UTC()).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,214 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"redditclone/pkg/dbcall"
+	"redditclone/pkg/resp"
+)
+
+// RedisSessionRepo keeps the sessions of a user in one hash, session id to
+// "created expires" in unix milliseconds. The key expires with the last of
+// them, so Redis does the cleanup.
+type RedisSessionRepo struct {
+	Client      *resp.Client
+	Prefix      string
+	TTL         time.Duration
+	MaxLifetime time.Duration
+	Now         func() time.Time
+}
+
+func NewRedisSessionRepo(client *resp.Client) *RedisSessionRepo {
+	return &RedisSessionRepo{
+		Client:      client,
+		Prefix:      "session:",
+		TTL:         DefaultTTL,
+		MaxLifetime: DefaultMaxLifetime,
+		Now:         time.Now,
+	}
+}
+
+func (r *RedisSessionRepo) Create(ctx context.Context, userID string, sessionID string) (string, error) {
+	ctx, done := dbcall.Start(ctx, "redis", "sessions", "Create")
+	defer done()
+
+	err := r.update(ctx, userID, func(now time.Time, sessions map[string]Session) []Session {
+		return []Session{{ID: sessionID, UserID: userID, CreatedAt: now, ExpiresAt: now.Add(r.TTL)}}
+	})
+	if errors.Is(err, errConflict) {
+		return "", ErrConflict
+	}
+	return sessionID, err
+}
+
+func (r *RedisSessionRepo) IsValid(ctx context.Context, userID, sessionID string) (bool, error) {
+	ctx, done := dbcall.Start(ctx, "redis", "sessions", "IsValid")
+	defer done()
+
+	reply, err := r.Client.Do(ctx, "HGET", r.key(userID), sessionID)
+	if err != nil || reply == nil {
+		return false, err
+	}
+	v, ok := reply.(string)
+	if !ok {
+		return false, fmt.Errorf("resp: unexpected reply %T", reply)
+	}
+	s, err := decodeSession(v)
+	if err != nil {
+		return false, fmt.Errorf("session %s of %s: %w", sessionID, userID, err)
+	}
+	return s.ExpiresAt.After(r.Now()), nil
+}
+
+func (r *RedisSessionRepo) Invalidate(ctx context.Context, userID string) error {
+	ctx, done := dbcall.Start(ctx, "redis", "sessions", "Invalidate")
+	defer done()
+
+	_, err := r.Client.Do(ctx, "DEL", r.key(userID))
+	return err
+}
+
+// Touch works like MySQLSessionRepo.Touch. Losing a race to another writer
+// only skips this extension.
+func (r *RedisSessionRepo) Touch(ctx context.Context, userID, sessionID string) error {
+	ctx, done := dbcall.Start(ctx, "redis", "sessions", "Touch")
+	defer done()
+
+	err := r.update(ctx, userID, func(now time.Time, sessions map[string]Session) []Session {
+		s, ok := sessions[sessionID]
+		if !ok || !s.ExpiresAt.After(now) || !s.ExpiresAt.Before(now.Add(r.TTL/2)) {
+			return nil
+		}
+		expires := now.Add(r.TTL)
+		if limit := s.CreatedAt.Add(r.MaxLifetime); r.MaxLifetime > 0 && expires.After(limit) {
+			expires = limit
+		}
+		if !expires.After(s.ExpiresAt) {
+			return nil
+		}
+		s.ExpiresAt = expires
+		return []Session{s}
+	})
+	if errors.Is(err, errConflict) {
+		return nil
+	}
+	return err
+}
+
+var errConflict = errors.New("concurrent session update")
+
+// update writes the sessions returned by fn and drops expired ones in a
+// WATCH/MULTI transaction, so a session removed by a concurrent Invalidate is
+// never written back.
+func (r *RedisSessionRepo) update(ctx context.Context, userID string, fn func(now time.Time, sessions map[string]Session) []Session) error {
+	key := r.key(userID)
+
+	for attempt := 0; attempt < 3; attempt++ {
+		var aborted bool
+		err := r.Client.With(ctx, func(conn *resp.Conn) error {
+			if _, err := conn.Do("WATCH", key); err != nil {
+				return err
+			}
+			sessions, err := r.load(conn.Do, userID)
+			if err != nil {
+				return err
+			}
+
+			now := r.Now()
+			changed := fn(now, sessions)
+			if len(changed) == 0 {
+				_, err := conn.Do("UNWATCH")
+				return err
+			}
+
+			var expired []string
+			for id, s := range sessions {
+				if !s.ExpiresAt.After(now) {
+					expired = append(expired, id)
+					delete(sessions, id)
+				}
+			}
+			set := []string{"HSET", key}
+			for _, s := range changed {
+				sessions[s.ID] = s
+				set = append(set, s.ID, encodeSession(s))
+			}
+			var last time.Time
+			for _, s := range sessions {
+				if s.ExpiresAt.After(last) {
+					last = s.ExpiresAt
+				}
+			}
+
+			cmds := [][]string{{"MULTI"}, set}
+			if len(expired) > 0 {
+				cmds = append(cmds, append([]string{"HDEL", key}, expired...))
+			}
+			cmds = append(cmds, []string{"PEXPIREAT", key, strconv.FormatInt(last.UnixMilli(), 10)})
+			for _, cmd := range cmds {
+				if _, err := conn.Do(cmd...); err != nil {
+					conn.Do("DISCARD")
+					return err
+				}
+			}
+			reply, err := conn.Do("EXEC")
+			if err != nil {
+				return err
+			}
+			aborted = reply == nil
+			return nil
+		})
+		if err != nil || !aborted {
+			return err
+		}
+	}
+	return errConflict
+}
+
+func (r *RedisSessionRepo) load(do func(args ...string) (any, error), userID string) (map[string]Session, error) {
+	fields, err := resp.Strings(do("HGETALL", r.key(userID)))
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make(map[string]Session, len(fields)/2)
+	for i := 0; i+1 < len(fields); i += 2 {
+		s, err := decodeSession(fields[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("session %s of %s: %w", fields[i], userID, err)
+		}
+		s.ID = fields[i]
+		s.UserID = userID
+		sessions[s.ID] = s
+	}
+	return sessions, nil
+}
+
+func (r *RedisSessionRepo) key(userID string) string {
+	return r.Prefix + userID
+}
+
+func encodeSession(s Session) string {
+	return strconv.FormatInt(s.CreatedAt.UnixMilli(), 10) + " " + strconv.FormatInt(s.ExpiresAt.UnixMilli(), 10)
+}
+
+func decodeSession(v string) (Session, error) {
+	created, expires, ok := strings.Cut(v, " ")
+	if !ok {
+		return Session{}, ErrMalformed
+	}
+	c, err := strconv.ParseInt(created, 10, 64)
+	if err != nil {
+		return Session{}, ErrMalformed
+	}
+	e, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return Session{}, ErrMalformed
+	}
+	return Session{CreatedAt: time.UnixMilli(c), ExpiresAt: time.UnixMilli(e)}, nil
+}
@@ -0,0 +1,134 @@
+package session_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"redditclone/pkg/resp"
+	"redditclone/pkg/resp/resptest"
+	"redditclone/pkg/session"
+)
+
+func setupRedisRepo(t *testing.T, now *time.Time) (*session.RedisSessionRepo, *resptest.Server) {
+	srv := resptest.NewServer()
+	srv.Password = "secret"
+	srv.SetNow(func() time.Time { return *now })
+	t.Cleanup(srv.Close)
+
+	client := resp.NewClient(srv.Addr, "secret", 1)
+	t.Cleanup(func() { client.Close() })
+
+	repo := session.NewRedisSessionRepo(client)
+	repo.TTL = time.Hour
+	repo.MaxLifetime = 3 * time.Hour
+	repo.Now = func() time.Time { return *now }
+	return repo, srv
+}
+
+func TestRedisSession_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo, _ := setupRedisRepo(t, &now)
+
+	valid, err := repo.IsValid(ctx, "uid", "s1")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+
+	id, err := repo.Create(ctx, "uid", "s1")
+	assert.NoError(t, err)
+	assert.Equal(t, "s1", id)
+
+	valid, err = repo.IsValid(ctx, "uid", "s1")
+	assert.NoError(t, err)
+	assert.True(t, valid)
+	// only that session, not every session of the user
+	valid, err = repo.IsValid(ctx, "uid", "s2")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+
+	// the key expires with the session
+	pttl, err := repo.Client.Do(ctx, "PTTL", "session:uid")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour.Milliseconds(), pttl)
+
+	assert.NoError(t, repo.Invalidate(ctx, "uid"))
+	valid, err = repo.IsValid(ctx, "uid", "s1")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+}
+
+func TestRedisSession_SlidingExpiration(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo, _ := setupRedisRepo(t, &now)
+
+	_, err := repo.Create(ctx, "uid", "s1")
+	assert.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		now = now.Add(40 * time.Minute)
+		assert.NoError(t, repo.Touch(ctx, "uid", "s1"))
+		valid, err := repo.IsValid(ctx, "uid", "s1")
+		assert.NoError(t, err)
+		assert.True(t, valid, "after %d touches", i+1)
+	}
+
+	now = time.Date(2025, 1, 1, 14, 50, 0, 0, time.UTC)
+	assert.NoError(t, repo.Touch(ctx, "uid", "s1"))
+	now = time.Date(2025, 1, 1, 15, 0, 0, 0, time.UTC)
+	valid, err := repo.IsValid(ctx, "uid", "s1")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+
+	// Redis dropped the key by now
+	exists, err := repo.Client.Do(ctx, "EXISTS", "session:uid")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), exists)
+}
+
+func TestRedisSession_TouchExtendsOnlyThatSession(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo, _ := setupRedisRepo(t, &now)
+
+	for _, id := range []string{"s1", "s2"} {
+		_, err := repo.Create(ctx, "uid", id)
+		assert.NoError(t, err)
+	}
+
+	now = now.Add(40 * time.Minute)
+	assert.NoError(t, repo.Touch(ctx, "uid", "s1"))
+	now = now.Add(30 * time.Minute)
+
+	valid, err := repo.IsValid(ctx, "uid", "s1")
+	assert.NoError(t, err)
+	assert.True(t, valid)
+	valid, err = repo.IsValid(ctx, "uid", "s2")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+}
+
+func TestRedisSession_TouchAfterInvalidate(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo, srv := setupRedisRepo(t, &now)
+
+	_, err := repo.Create(ctx, "uid", "s1")
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Invalidate(ctx, "uid"))
+
+	now = now.Add(40 * time.Minute)
+	assert.NoError(t, repo.Touch(ctx, "uid", "s1"))
+	valid, err := repo.IsValid(ctx, "uid", "s1")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+
+	// a write racing the transaction makes Touch give up quietly
+	_, err = repo.Create(ctx, "uid", "s2")
+	assert.NoError(t, err)
+	now = now.Add(40 * time.Minute)
+	srv.Touched("session:uid")
+	assert.NoError(t, repo.Touch(ctx, "uid", "s2"))
+}
@@ -1,6 +1,22 @@
 package session
 
-import "time"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"redditclone/pkg/resp"
+)
+
+const (
+	DefaultTTL             = time.Hour
+	DefaultMaxLifetime     = 7 * 24 * time.Hour
+	DefaultCleanupInterval = 10 * time.Minute
+	DefaultCleanupBatch    = 500
+)
 
 type Session struct {
 	ID        string
@@ -10,7 +26,144 @@ type Session struct {
 }
 
 type Repository interface {
-	Create(userID, sessionID string) (string, error)
-	IsValid(userID string) (bool, error)
-	Invalidate(userID string) error
+	Create(ctx context.Context, userID, sessionID string) (string, error)
+	// IsValid reports whether the session sessionID of the user is live.
+	IsValid(ctx context.Context, userID, sessionID string) (bool, error)
+	// Invalidate drops every session of the user.
+	Invalidate(ctx context.Context, userID string) error
+	// Touch extends the session sessionID of an active user, other sessions of
+	// the user keep their expiry.
+	Touch(ctx context.Context, userID, sessionID string) error
+}
+
+// Counter is implemented by stores that can count live sessions.
+type Counter interface {
+	CountActive(ctx context.Context) (int64, error)
+}
+
+type Config struct {
+	// Store is "mysql" or "redis".
+	Store           string
+	TTL             time.Duration
+	MaxLifetime     time.Duration
+	CleanupInterval time.Duration
+	CleanupBatch    int
+
+	RedisAddr     string
+	RedisPassword string
+	RedisDB       int
+
+	// CacheSize of 0 turns the in-process cache off.
+	CacheSize        int
+	CacheTTL         time.Duration
+	CacheNegativeTTL time.Duration
+}
+
+// ConfigFrom reads SESSION_STORE, SESSION_TTL, SESSION_MAX_LIFETIME,
+// SESSION_CLEANUP_INTERVAL, SESSION_CLEANUP_BATCH, the SESSION_CACHE_*
+// settings and, for the redis store, REDIS_ADDR, REDIS_PASSWORD and REDIS_DB
+// through getenv over the defaults. The error lists every bad setting.
+func ConfigFrom(getenv func(string) string) (Config, error) {
+	cfg := Config{
+		Store:            "mysql",
+		TTL:              DefaultTTL,
+		MaxLifetime:      DefaultMaxLifetime,
+		CleanupInterval:  DefaultCleanupInterval,
+		CleanupBatch:     DefaultCleanupBatch,
+		RedisAddr:        getenv("REDIS_ADDR"),
+		RedisPassword:    getenv("REDIS_PASSWORD"),
+		CacheSize:        DefaultCacheSize,
+		CacheTTL:         DefaultCacheTTL,
+		CacheNegativeTTL: DefaultCacheNegativeTTL,
+	}
+	var errs []error
+
+	switch v := getenv("SESSION_STORE"); v {
+	case "", "mysql":
+	case "redis":
+		if cfg.RedisAddr == "" {
+			errs = append(errs, fmt.Errorf("SESSION_STORE=redis needs REDIS_ADDR"))
+		}
+		cfg.Store = v
+	default:
+		errs = append(errs, fmt.Errorf("unknown SESSION_STORE %q", v))
+	}
+
+	durations := []struct {
+		env string
+		dst *time.Duration
+		// the cache may be turned off with zeros
+		zero bool
+	}{
+		{"SESSION_TTL", &cfg.TTL, false},
+		{"SESSION_MAX_LIFETIME", &cfg.MaxLifetime, false},
+		{"SESSION_CLEANUP_INTERVAL", &cfg.CleanupInterval, false},
+		{"SESSION_CACHE_TTL", &cfg.CacheTTL, true},
+		{"SESSION_CACHE_NEGATIVE_TTL", &cfg.CacheNegativeTTL, true},
+	}
+	for _, d := range durations {
+		v := getenv(d.env)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed < 0 || (parsed == 0 && !d.zero) {
+			errs = append(errs, fmt.Errorf("bad %s %q", d.env, v))
+			continue
+		}
+		*d.dst = parsed
+	}
+
+	ints := []struct {
+		env string
+		dst *int
+		min int
+	}{
+		{"SESSION_CLEANUP_BATCH", &cfg.CleanupBatch, 1},
+		{"REDIS_DB", &cfg.RedisDB, 0},
+		{"SESSION_CACHE_SIZE", &cfg.CacheSize, 0},
+	}
+	for _, i := range ints {
+		v := getenv(i.env)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < i.min {
+			errs = append(errs, fmt.Errorf("bad %s %q", i.env, v))
+			continue
+		}
+		*i.dst = n
+	}
+
+	if cfg.MaxLifetime < cfg.TTL {
+		errs = append(errs, fmt.Errorf("SESSION_MAX_LIFETIME is shorter than SESSION_TTL"))
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// NewStore opens the store named by cfg.Store, behind the cache unless it is
+// turned off. The returned Purger is nil for stores that expire sessions on
+// their own.
+func NewStore(cfg Config, db *sql.DB) (Repository, Purger) {
+	var store Repository
+	var purger Purger
+
+	switch cfg.Store {
+	case "redis":
+		redis := NewRedisSessionRepo(resp.NewClient(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB))
+		redis.TTL = cfg.TTL
+		redis.MaxLifetime = cfg.MaxLifetime
+		store = redis
+	default:
+		mysql := NewMySQLSessionRepo(db)
+		mysql.TTL = cfg.TTL
+		mysql.MaxLifetime = cfg.MaxLifetime
+		store, purger = mysql, mysql
+	}
+
+	if cfg.CacheSize > 0 && (cfg.CacheTTL > 0 || cfg.CacheNegativeTTL > 0) {
+		store = NewCache(store, cfg.CacheSize, cfg.CacheTTL, cfg.CacheNegativeTTL)
+	}
+	return store, purger
 }
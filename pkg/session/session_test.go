@@ -0,0 +1,170 @@
+package session_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"redditclone/pkg/session"
+)
+
+func setupRepo(t *testing.T, now *time.Time) *session.MySQLSessionRepo {
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`
+	CREATE TABLE sessions (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		created_at DATETIME,
+		expires_at DATETIME
+	);`)
+	assert.NoError(t, err)
+
+	repo := session.NewMySQLSessionRepo(db)
+	repo.TTL = time.Hour
+	repo.MaxLifetime = 3 * time.Hour
+	repo.Now = func() time.Time { return *now }
+	return repo
+}
+
+func TestSession_SlidingExpiration(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo := setupRepo(t, &now)
+
+	_, err := repo.Create(ctx, "uid", "s1")
+	assert.NoError(t, err)
+
+	// activity early in the session does not write anything
+	now = now.Add(10 * time.Minute)
+	assert.NoError(t, repo.Touch(ctx, "uid", "s1"))
+	now = now.Add(55 * time.Minute)
+	valid, err := repo.IsValid(ctx, "uid", "s1")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+
+	now = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	_, err = repo.Create(ctx, "uid2", "s2")
+	assert.NoError(t, err)
+
+	// keep the user active past the TTL
+	for i := 0; i < 4; i++ {
+		now = now.Add(40 * time.Minute)
+		assert.NoError(t, repo.Touch(ctx, "uid2", "s2"))
+		valid, err := repo.IsValid(ctx, "uid2", "s2")
+		assert.NoError(t, err)
+		assert.True(t, valid, "after %d touches", i+1)
+	}
+
+	// but never past the maximum lifetime
+	now = time.Date(2025, 1, 1, 14, 50, 0, 0, time.UTC)
+	assert.NoError(t, repo.Touch(ctx, "uid2", "s2"))
+	now = time.Date(2025, 1, 1, 15, 0, 0, 0, time.UTC)
+	valid, err = repo.IsValid(ctx, "uid2", "s2")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+}
+
+func TestSession_IsValidChecksTheSession(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo := setupRepo(t, &now)
+
+	_, err := repo.Create(ctx, "uid", "s1")
+	assert.NoError(t, err)
+
+	valid, err := repo.IsValid(ctx, "uid", "s1")
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	// a live session of the user does not make other session IDs valid
+	assert.NoError(t, repo.Invalidate(ctx, "uid"))
+	_, err = repo.Create(ctx, "uid", "s2")
+	assert.NoError(t, err)
+	valid, err = repo.IsValid(ctx, "uid", "s1")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+	valid, err = repo.IsValid(ctx, "other", "s2")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+}
+
+func TestJanitor_PurgesInBatches(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo := setupRepo(t, &now)
+
+	for i := 0; i < 7; i++ {
+		_, err := repo.Create(ctx, fmt.Sprintf("old%d", i), fmt.Sprintf("old%d", i))
+		assert.NoError(t, err)
+	}
+	now = now.Add(30 * time.Minute)
+	_, err := repo.Create(ctx, "active", "active")
+	assert.NoError(t, err)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{}))
+	janitor := session.NewJanitor(repo, time.Minute, 3, logger)
+	janitor.Now = func() time.Time { return now.Add(40 * time.Minute) }
+
+	deleted, err := janitor.Purge(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), deleted)
+
+	now = now.Add(time.Minute)
+	valid, err := repo.IsValid(ctx, "active", "active")
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	deleted, err = janitor.Purge(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+}
+
+func TestJanitor_StartStop(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo := setupRepo(t, &now)
+	_, err := repo.Create(ctx, "uid", "s1")
+	assert.NoError(t, err)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{}))
+	janitor := session.NewJanitor(repo, 5*time.Millisecond, 10, logger)
+	janitor.Now = func() time.Time { return now.Add(2 * time.Hour) }
+
+	stop := janitor.Start()
+	assert.Eventually(t, func() bool {
+		var n int
+		assert.NoError(t, repo.DB.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&n))
+		return n == 0
+	}, time.Second, 5*time.Millisecond)
+	stop()
+}
+
+func TestSession_TouchExtendsOnlyThatSession(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo := setupRepo(t, &now)
+
+	for _, id := range []string{"s1", "s2"} {
+		_, err := repo.Create(ctx, "uid", id)
+		assert.NoError(t, err)
+	}
+
+	now = now.Add(40 * time.Minute)
+	assert.NoError(t, repo.Touch(ctx, "uid", "s1"))
+	now = now.Add(30 * time.Minute)
+
+	valid, err := repo.IsValid(ctx, "uid", "s1")
+	assert.NoError(t, err)
+	assert.True(t, valid)
+	valid, err = repo.IsValid(ctx, "uid", "s2")
+	assert.NoError(t, err)
+	assert.False(t, valid)
+}
@@ -0,0 +1,156 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"redditclone/pkg/claims"
+	"redditclone/pkg/jwtkeys"
+)
+
+// Issuer creates access tokens for a signed in user.
+type Issuer interface {
+	Issue(username, userID, sessionID string) (string, error)
+}
+
+// Verifier checks an access token and returns who it was issued to.
+type Verifier interface {
+	Verify(ctx context.Context, raw string) (*claims.Claims, error)
+}
+
+// tokenClaims is the payload on the wire.
+type tokenClaims struct {
+	claims.Claims
+	jwt.RegisteredClaims
+}
+
+// JWT issues and verifies tokens signed with the keys of a keyring.
+type JWT struct {
+	Keys     *jwtkeys.Keyring
+	Issuer   string
+	Audience string
+	TTL      time.Duration
+	// Leeway is the clock skew tolerated on exp, nbf and iat.
+	Leeway time.Duration
+	Now    func() time.Time
+}
+
+func NewJWT(keys *jwtkeys.Keyring) *JWT {
+	return &JWT{
+		Keys:     keys,
+		Issuer:   "redditclone",
+		Audience: "redditclone-api",
+		TTL:      time.Hour,
+		Leeway:   30 * time.Second,
+		Now:      time.Now,
+	}
+}
+
+type Config struct {
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+}
+
+// ConfigFrom reads JWT_ISSUER, JWT_AUDIENCE and JWT_LEEWAY through getenv over
+// the defaults of NewJWT.
+func ConfigFrom(getenv func(string) string) (Config, error) {
+	defaults := NewJWT(nil)
+	cfg := Config{Issuer: defaults.Issuer, Audience: defaults.Audience, Leeway: defaults.Leeway}
+	if v := getenv("JWT_ISSUER"); v != "" {
+		cfg.Issuer = v
+	}
+	if v := getenv("JWT_AUDIENCE"); v != "" {
+		cfg.Audience = v
+	}
+	if v := getenv("JWT_LEEWAY"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return Config{}, fmt.Errorf("bad JWT_LEEWAY %q", v)
+		}
+		cfg.Leeway = d
+	}
+	return cfg, nil
+}
+
+// New returns a JWT with the settings of cfg.
+func New(keys *jwtkeys.Keyring, cfg Config) *JWT {
+	t := NewJWT(keys)
+	t.Issuer = cfg.Issuer
+	t.Audience = cfg.Audience
+	t.Leeway = cfg.Leeway
+	return t
+}
+
+func (t *JWT) Issue(username, userID, sessionID string) (string, error) {
+	key, err := t.Keys.Signing()
+	if err != nil {
+		return "", err
+	}
+
+	now := t.Now()
+	c := tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    t.Issuer,
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{t.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(t.TTL)),
+		},
+	}
+	c.User.Username = username
+	c.User.ID = userID
+	c.SessionID = sessionID
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Alg), c)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(key.Private)
+}
+
+func (t *JWT) Verify(ctx context.Context, raw string) (*claims.Claims, error) {
+	c := &tokenClaims{}
+	keyfunc := func(token *jwt.Token) (any, error) { return t.keyfunc(ctx, token) }
+	_, err := jwt.ParseWithClaims(raw, c, keyfunc,
+		jwt.WithValidMethods([]string{t.Keys.Alg}),
+		jwt.WithIssuer(t.Issuer),
+		jwt.WithAudience(t.Audience),
+		jwt.WithLeeway(t.Leeway),
+		jwt.WithTimeFunc(t.Now),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	if c.User.Username == "" || c.User.ID == "" || c.User.ID != c.Subject || c.SessionID == "" {
+		return nil, errors.New("invalid token")
+	}
+	c.Claims.ExpiresAt = c.RegisteredClaims.ExpiresAt.Time
+	return &c.Claims, nil
+}
+
+// keyfunc only accepts the algorithm the named key was made for, so a public
+// key can never be used as an HMAC secret.
+func (t *JWT) keyfunc(ctx context.Context, token *jwt.Token) (any, error) {
+	var key *jwtkeys.Key
+	var err error
+	if t.Keys.Alg == jwtkeys.AlgHS256 {
+		key, err = t.Keys.Signing()
+	} else {
+		kid, _ := token.Header["kid"].(string)
+		key, err = t.Keys.Verification(ctx, kid)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if token.Method.Alg() != key.Alg {
+		return nil, errors.New("bad sign method")
+	}
+	return key.Public, nil
+}
@@ -0,0 +1,150 @@
+package token_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"redditclone/pkg/jwtkeys"
+	"redditclone/pkg/secretbox"
+	"redditclone/pkg/token"
+)
+
+func asymmetricKeys(t *testing.T, alg string) *jwtkeys.Keyring {
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`
+	CREATE TABLE jwt_keys (
+		kid TEXT PRIMARY KEY,
+		alg TEXT NOT NULL,
+		private_key TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL
+	);`)
+	assert.NoError(t, err)
+
+	box, err := secretbox.New("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	assert.NoError(t, err)
+	keys, err := jwtkeys.NewKeyring(alg, jwtkeys.NewMySQLStore(db), box)
+	assert.NoError(t, err)
+	assert.NoError(t, keys.Rotate(context.Background()))
+	return keys
+}
+
+func TestJWT_RoundTrip(t *testing.T) {
+	for name, keys := range map[string]*jwtkeys.Keyring{
+		"HS256": jwtkeys.NewHMAC([]byte("secret")),
+		"RS256": asymmetricKeys(t, jwtkeys.AlgRS256),
+		"EdDSA": asymmetricKeys(t, jwtkeys.AlgEdDSA),
+	} {
+		t.Run(name, func(t *testing.T) {
+			tokens := token.NewJWT(keys)
+
+			raw, err := tokens.Issue("alice", "uid", "sid")
+			assert.NoError(t, err)
+
+			c, err := tokens.Verify(context.Background(), raw)
+			assert.NoError(t, err)
+			assert.Equal(t, "alice", c.User.Username)
+			assert.Equal(t, "uid", c.User.ID)
+			assert.Equal(t, "sid", c.SessionID)
+
+			// tokens outside of a session are not accepted
+			raw, err = tokens.Issue("alice", "uid", "")
+			assert.NoError(t, err)
+			_, err = tokens.Verify(context.Background(), raw)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestJWT_Expiry(t *testing.T) {
+	now := time.Now()
+	tokens := token.NewJWT(jwtkeys.NewHMAC([]byte("secret")))
+	tokens.Now = func() time.Time { return now }
+
+	raw, err := tokens.Issue("alice", "uid", "sid")
+	assert.NoError(t, err)
+
+	// within the tolerated clock skew
+	now = now.Add(time.Hour + 20*time.Second)
+	_, err = tokens.Verify(context.Background(), raw)
+	assert.NoError(t, err)
+
+	now = now.Add(time.Minute)
+	_, err = tokens.Verify(context.Background(), raw)
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+}
+
+func TestJWT_IssuerAndAudience(t *testing.T) {
+	keys := jwtkeys.NewHMAC([]byte("secret"))
+
+	other := token.NewJWT(keys)
+	other.Audience = "another-service"
+	raw, err := other.Issue("alice", "uid", "sid")
+	assert.NoError(t, err)
+	_, err = token.NewJWT(keys).Verify(context.Background(), raw)
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidAudience)
+
+	other = token.NewJWT(keys)
+	other.Issuer = "someone-else"
+	raw, err = other.Issue("alice", "uid", "sid")
+	assert.NoError(t, err)
+	_, err = token.NewJWT(keys).Verify(context.Background(), raw)
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidIssuer)
+}
+
+func TestJWT_RejectsForgedTokens(t *testing.T) {
+	keys := asymmetricKeys(t, jwtkeys.AlgEdDSA)
+	tokens := token.NewJWT(keys)
+
+	key, err := keys.Signing()
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(key.Public.(ed25519.PublicKey))
+	assert.NoError(t, err)
+
+	claims := jwt.MapClaims{
+		"user": map[string]string{"username": "admin", "id": "admin"},
+		"sub":  "admin",
+		"iss":  "redditclone",
+		"aud":  "redditclone-api",
+		"iat":  time.Now().Unix(),
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	}
+
+	// an HMAC token keyed with the published public key
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = key.ID
+	raw, err := forged.SignedString(der)
+	assert.NoError(t, err)
+	_, err = tokens.Verify(context.Background(), raw)
+	assert.Error(t, err)
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	assert.NoError(t, err)
+	_, err = tokens.Verify(context.Background(), unsigned)
+	assert.Error(t, err)
+
+	// the user in the payload has to match the subject
+	_, other, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	claims["sub"] = "uid"
+	mismatched := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	mismatched.Header["kid"] = key.ID
+	raw, err = mismatched.SignedString(key.Private)
+	assert.NoError(t, err)
+	_, err = tokens.Verify(context.Background(), raw)
+	assert.EqualError(t, err, "invalid token")
+
+	raw, err = mismatched.SignedString(other)
+	assert.NoError(t, err)
+	_, err = tokens.Verify(context.Background(), raw)
+	assert.Error(t, err)
+}
@@ -0,0 +1,54 @@
+package totp_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"redditclone/pkg/totp"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// base32 of the RFC 6238 SHA1 seed "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode_RFC6238(t *testing.T) {
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		code, err := totp.Code(rfcSecret, totp.Step(time.Unix(unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, want, code, unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	step, ok := totp.Validate(rfcSecret, "081804", now, 1)
+	assert.True(t, ok)
+	assert.Equal(t, totp.Step(now), step)
+
+	// previous period is accepted with skew
+	_, ok = totp.Validate(rfcSecret, "081804", now.Add(30*time.Second), 1)
+	assert.True(t, ok)
+	_, ok = totp.Validate(rfcSecret, "081804", now.Add(90*time.Second), 1)
+	assert.False(t, ok)
+
+	_, ok = totp.Validate(rfcSecret, "12345", now, 1)
+	assert.False(t, ok)
+}
+
+func TestGenerateSecretAndURI(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	uri := totp.URI("redditclone", "bob", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/redditclone:bob?"))
+	assert.Contains(t, uri, "secret="+secret)
+}
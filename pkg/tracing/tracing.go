@@ -0,0 +1,99 @@
+// Package tracing sets up OpenTelemetry and opens the spans of services and
+// repositories.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentation = "redditclone"
+
+type Config struct {
+	// Exporter is "none", "otlp" or "stdout".
+	Exporter    string
+	ServiceName string
+	// SampleRatio is the share of new traces that are recorded, a sampled
+	// parent is always followed.
+	SampleRatio float64
+}
+
+// ConfigFrom reads OTEL_TRACES_EXPORTER, OTEL_SERVICE_NAME and
+// OTEL_TRACES_SAMPLER_ARG through getenv. The OTLP exporter picks its
+// endpoint from the standard OTEL_EXPORTER_OTLP_* variables.
+func ConfigFrom(getenv func(string) string) (Config, error) {
+	cfg := Config{
+		Exporter:    "none",
+		ServiceName: "redditclone",
+		SampleRatio: 1,
+	}
+	var errs []error
+
+	switch v := getenv("OTEL_TRACES_EXPORTER"); v {
+	case "":
+	case "none", "otlp", "stdout":
+		cfg.Exporter = v
+	default:
+		errs = append(errs, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q", v))
+	}
+	if v := getenv("OTEL_SERVICE_NAME"); v != "" {
+		cfg.ServiceName = v
+	}
+	if v := getenv("OTEL_TRACES_SAMPLER_ARG"); v != "" {
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			errs = append(errs, fmt.Errorf("bad OTEL_TRACES_SAMPLER_ARG %q", v))
+		} else {
+			cfg.SampleRatio = ratio
+		}
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// Setup installs the W3C trace context propagator and, unless the exporter
+// is "none", a tracer provider. The stdout exporter writes to w. shutdown
+// flushes pending spans.
+func Setup(ctx context.Context, cfg Config, w io.Writer) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	default:
+		return func(context.Context) error { return nil }, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("trace exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start opens a span named after the service method it covers.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, opts...)
+}
@@ -0,0 +1,55 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"redditclone/pkg/tracing"
+)
+
+func TestConfigFrom(t *testing.T) {
+	cfg, err := tracing.ConfigFrom(os.Getenv)
+	assert.NoError(t, err)
+	assert.Equal(t, tracing.Config{Exporter: "none", ServiceName: "redditclone", SampleRatio: 1}, cfg)
+
+	t.Setenv("OTEL_TRACES_EXPORTER", "stdout")
+	t.Setenv("OTEL_SERVICE_NAME", "api")
+	t.Setenv("OTEL_TRACES_SAMPLER_ARG", "0.25")
+	cfg, err = tracing.ConfigFrom(os.Getenv)
+	assert.NoError(t, err)
+	assert.Equal(t, tracing.Config{Exporter: "stdout", ServiceName: "api", SampleRatio: 0.25}, cfg)
+
+	t.Setenv("OTEL_TRACES_SAMPLER_ARG", "2")
+	_, err = tracing.ConfigFrom(os.Getenv)
+	assert.EqualError(t, err, `bad OTEL_TRACES_SAMPLER_ARG "2"`)
+
+	t.Setenv("OTEL_TRACES_EXPORTER", "zipkin")
+	_, err = tracing.ConfigFrom(os.Getenv)
+	assert.EqualError(t, err, "unknown OTEL_TRACES_EXPORTER \"zipkin\"\nbad OTEL_TRACES_SAMPLER_ARG \"2\"")
+}
+
+func TestSetup_Stdout(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	var buf bytes.Buffer
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    "stdout",
+		ServiceName: "redditclone",
+		SampleRatio: 1,
+	}, &buf)
+	assert.NoError(t, err)
+
+	ctx, span := tracing.Start(context.Background(), "post.PostService.GetAll")
+	_, child := tracing.Start(ctx, "posts.GetAll")
+	child.End()
+	span.End()
+
+	assert.NoError(t, shutdown(context.Background()))
+	assert.Contains(t, buf.String(), `"Name":"posts.GetAll"`)
+	assert.Contains(t, buf.String(), `"Name":"post.PostService.GetAll"`)
+}
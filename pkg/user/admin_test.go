@@ -0,0 +1,58 @@
+package user_test
+
+import (
+	"context"
+	"testing"
+
+	"redditclone/pkg/audit"
+	"redditclone/pkg/user"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminService(t *testing.T) {
+	ctx := context.Background()
+	db := setupUserDB(t)
+	repo := user.NewMySQLRepo(db)
+	sessions := new(mockSession)
+	svc := user.NewAdminService(repo, sessions, audit.Nop{})
+
+	_, err := svc.Create(ctx, "mod", "longenough", "", "owner")
+	assert.EqualError(t, err, `unknown role "owner"`)
+	_, err = svc.Create(ctx, "mod", "short", "", user.RoleModerator)
+	assert.EqualError(t, err, "password too short")
+
+	created, err := svc.Create(ctx, "mod", "longenough", "Mod@Example.com", user.RoleModerator)
+	assert.NoError(t, err)
+	found, err := repo.FindByUsername(ctx, "mod")
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, found.ID)
+	assert.Equal(t, user.RoleModerator, found.Role)
+	assert.Equal(t, "mod@example.com", found.Email)
+	assert.True(t, found.Verified)
+
+	assert.NoError(t, svc.SetRole(ctx, "mod", user.RoleAdmin))
+	found, _ = repo.FindByUsername(ctx, "mod")
+	assert.Equal(t, user.RoleAdmin, found.Role)
+
+	sessions.On("Invalidate", created.ID).Return(nil)
+	assert.EqualError(t, svc.ResetPassword(ctx, "mod", "short"), "password too short")
+	assert.NoError(t, svc.ResetPassword(ctx, "mod", "anotherpassword"))
+	_, err = db.Exec("INSERT INTO sessions (id, user_id) VALUES ('s1', ?)", created.ID)
+	assert.NoError(t, err)
+	_, err = db.Exec("INSERT INTO user_blocks (blocker_id, blocked_id) VALUES ('other', ?)", created.ID)
+	assert.NoError(t, err)
+
+	assert.NoError(t, svc.Delete(ctx, "mod"))
+	_, err = repo.FindByUsername(ctx, "mod")
+	assert.EqualError(t, err, "user not found")
+	var left int
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&left))
+	assert.Zero(t, left)
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM user_blocks").Scan(&left))
+	assert.Zero(t, left)
+	sessions.AssertExpectations(t)
+
+	assert.EqualError(t, svc.Delete(ctx, "mod"), "user not found")
+	assert.EqualError(t, repo.Delete(ctx, created.ID), "user not found")
+}
@@ -0,0 +1,227 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"redditclone/pkg/audit"
+	"redditclone/pkg/dbcall"
+	"redditclone/pkg/generator"
+	"redditclone/pkg/mailer"
+)
+
+const verifyTokenLen = 40
+
+type Verification struct {
+	TokenHash string
+	UserID    string
+	Email     string
+	ExpiresAt time.Time
+}
+
+type VerificationRepository interface {
+	Create(ctx context.Context, v *Verification) error
+	FindByHash(ctx context.Context, tokenHash string) (*Verification, error)
+	DeleteByUser(ctx context.Context, userID string) error
+}
+
+type EmailServiceInterface interface {
+	SetEmail(ctx context.Context, userID, email string) error
+	Resend(ctx context.Context, userID string) error
+	Verify(ctx context.Context, token string) error
+}
+
+type EmailService struct {
+	Repo          Repository
+	Verifications VerificationRepository
+	Mailer        mailer.Mailer
+	Audit         audit.Logger
+	TTL           time.Duration
+	// VerifyURL is prefixed to the token in verification emails.
+	VerifyURL string
+	Now       func() time.Time
+}
+
+func NewEmailService(repo Repository, verifications VerificationRepository, m mailer.Mailer, auditLog audit.Logger) *EmailService {
+	return &EmailService{
+		Repo:          repo,
+		Verifications: verifications,
+		Mailer:        m,
+		Audit:         auditLog,
+		TTL:           24 * time.Hour,
+		Now:           time.Now,
+	}
+}
+
+// NormalizeEmail validates the address and lowercases it, "" stays "".
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", nil
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > 254 {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(email), nil
+}
+
+// CheckAvailable fails when another account already uses the address.
+func CheckAvailable(ctx context.Context, repo Repository, email, userID string) error {
+	other, err := repo.FindByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if other.ID != userID {
+		return ErrEmailInUse
+	}
+	return nil
+}
+
+func (s *EmailService) SetEmail(ctx context.Context, userID, email string) error {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	if email == "" {
+		return ErrInvalidEmail
+	}
+	if err := CheckAvailable(ctx, s.Repo, email, userID); err != nil {
+		return err
+	}
+
+	if err := s.Repo.SetEmail(ctx, userID, email); err != nil {
+		return err
+	}
+
+	user, err := s.Repo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.SendVerification(ctx, user)
+}
+
+func (s *EmailService) Resend(ctx context.Context, userID string) error {
+	user, err := s.Repo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Email == "" {
+		return ErrNoEmail
+	}
+	if user.Verified {
+		return ErrEmailVerified
+	}
+	return s.SendVerification(ctx, user)
+}
+
+// SendVerification replaces any pending token of the user and mails a new one.
+func (s *EmailService) SendVerification(ctx context.Context, user *User) error {
+	token, err := generator.GenerateRandomID(verifyTokenLen)
+	if err != nil {
+		return fmt.Errorf("verification token gen error: %s", err)
+	}
+
+	if err := s.Verifications.DeleteByUser(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := s.Verifications.Create(ctx, &Verification{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		Email:     user.Email,
+		ExpiresAt: s.Now().Add(s.TTL),
+	}); err != nil {
+		return err
+	}
+
+	err = s.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nconfirm this address by opening:\n\n%s%s\n",
+			user.Username, s.VerifyURL, token,
+		),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send verification mail: %w", err)
+	}
+	return nil
+}
+
+func (s *EmailService) Verify(ctx context.Context, token string) error {
+	v, err := s.Verifications.FindByHash(ctx, hashToken(token))
+	if err != nil {
+		return err
+	}
+	if !s.Now().Before(v.ExpiresAt) {
+		return ErrInvalidVerificationToken
+	}
+
+	if err := s.Repo.MarkVerified(ctx, v.UserID, v.Email); err != nil {
+		return err
+	}
+
+	s.Audit.Record("email_verified", "user", v.UserID)
+	return s.Verifications.DeleteByUser(ctx, v.UserID)
+}
+
+// IsVerified is used by the middleware enforcing the unverified user policy.
+func (s *EmailService) IsVerified(ctx context.Context, userID string) (bool, error) {
+	user, err := s.Repo.FindByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.Verified, nil
+}
+
+type MySQLVerificationRepo struct {
+	DB *sql.DB
+}
+
+func NewMySQLVerificationRepo(db *sql.DB) *MySQLVerificationRepo {
+	return &MySQLVerificationRepo{DB: db}
+}
+
+func (r *MySQLVerificationRepo) Create(ctx context.Context, v *Verification) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "email_verifications", "Create")
+	defer done()
+	_, err := r.DB.ExecContext(ctx,
+		"INSERT INTO email_verifications (token_hash, user_id, email, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		v.TokenHash, v.UserID, v.Email, time.Now().UTC(), v.ExpiresAt.UTC(),
+	)
+	return err
+}
+
+func (r *MySQLVerificationRepo) FindByHash(ctx context.Context, tokenHash string) (*Verification, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "email_verifications", "FindByHash")
+	defer done()
+	v := &Verification{TokenHash: tokenHash}
+
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT user_id, email, expires_at FROM email_verifications WHERE token_hash = ?",
+		tokenHash,
+	).Scan(&v.UserID, &v.Email, &v.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (r *MySQLVerificationRepo) DeleteByUser(ctx context.Context, userID string) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "email_verifications", "DeleteByUser")
+	defer done()
+	_, err := r.DB.ExecContext(ctx, "DELETE FROM email_verifications WHERE user_id = ?", userID)
+	return err
+}
@@ -0,0 +1,78 @@
+package user_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"redditclone/pkg/audit"
+	"redditclone/pkg/mailer"
+	"redditclone/pkg/user"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	email, err := user.NormalizeEmail(" Bob@Example.com ")
+	assert.NoError(t, err)
+	assert.Equal(t, "bob@example.com", email)
+
+	email, err = user.NormalizeEmail("")
+	assert.NoError(t, err)
+	assert.Empty(t, email)
+
+	for _, bad := range []string{"bob", "Bob <bob@example.com>", "bob@"} {
+		_, err := user.NormalizeEmail(bad)
+		assert.EqualError(t, err, "invalid email", bad)
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	ctx := context.Background()
+	db := setupUserDB(t)
+	repo := user.NewMySQLRepo(db)
+	mail := mailer.NewMemoryMailer()
+
+	emails := user.NewEmailService(repo, user.NewMySQLVerificationRepo(db), mail, audit.Nop{})
+	emails.VerifyURL = "http://localhost/verify?token="
+
+	sessions := new(mockSession)
+	sessions.On("Create", mock.Anything, mock.Anything).Return("sessid", nil)
+	svc := user.NewService(repo, sessions)
+	svc.Email = emails
+
+	u, err := svc.Register(ctx, "alice", "password", "Alice@Example.com")
+	assert.NoError(t, err)
+
+	verified, err := emails.IsVerified(ctx, u.ID)
+	assert.NoError(t, err)
+	assert.False(t, verified)
+
+	assert.Len(t, mail.Sent(), 1)
+	assert.Equal(t, "alice@example.com", mail.Sent()[0].To)
+
+	_, err = svc.Register(ctx, "mallory", "password", "alice@example.com")
+	assert.EqualError(t, err, "email already in use")
+
+	assert.EqualError(t, emails.Verify(ctx, "bogus"), "invalid verification token")
+	assert.NoError(t, emails.Verify(ctx, tokenFrom(mail.Sent()[0].Body)))
+
+	verified, err = emails.IsVerified(ctx, u.ID)
+	assert.NoError(t, err)
+	assert.True(t, verified)
+
+	assert.EqualError(t, emails.Resend(ctx, u.ID), "email already verified")
+
+	// changing the address needs a new verification
+	assert.NoError(t, emails.SetEmail(ctx, u.ID, "alice@example.org"))
+	verified, err = emails.IsVerified(ctx, u.ID)
+	assert.NoError(t, err)
+	assert.False(t, verified)
+	assert.Len(t, mail.Sent(), 2)
+
+	// the token expires
+	now := time.Now().Add(25 * time.Hour)
+	emails.Now = func() time.Time { return now }
+	assert.EqualError(t, emails.Verify(ctx, tokenFrom(mail.Sent()[1].Body)), "invalid verification token")
+}
@@ -0,0 +1,28 @@
+package user
+
+import "redditclone/pkg/apperr"
+
+var (
+	ErrNotFound           = apperr.New(apperr.NotFound, "user_not_found", "user not found")
+	ErrExists             = apperr.New(apperr.Conflict, "user_exists", "user already exists")
+	ErrInvalidCredentials = apperr.New(apperr.Unauthorized, "invalid_credentials", "invalid credentials")
+	ErrPasswordTooShort   = apperr.New(apperr.Invalid, "password_too_short", "password too short")
+	ErrUnknownRole        = apperr.New(apperr.Invalid, "unknown_role", "unknown role")
+
+	ErrInvalidEmail             = apperr.New(apperr.Invalid, "invalid_email", "invalid email")
+	ErrEmailInUse               = apperr.New(apperr.Conflict, "email_in_use", "email already in use")
+	ErrNoEmail                  = apperr.New(apperr.Conflict, "no_email", "no email set")
+	ErrEmailVerified            = apperr.New(apperr.Conflict, "email_verified", "email already verified")
+	ErrInvalidVerificationToken = apperr.New(apperr.Invalid, "invalid_verification_token", "invalid verification token")
+	ErrInvalidResetToken        = apperr.New(apperr.Invalid, "invalid_reset_token", "invalid reset token")
+
+	ErrInvalidCode          = apperr.New(apperr.Unauthorized, "invalid_code", "invalid code")
+	ErrInvalidChallenge     = apperr.New(apperr.Unauthorized, "invalid_challenge", "invalid challenge")
+	ErrStepUsed             = apperr.New(apperr.Unauthorized, "step_used", "step already used")
+	ErrTwoFactorEnabled     = apperr.New(apperr.Conflict, "two_factor_enabled", "two factor already enabled")
+	ErrTwoFactorNotEnabled  = apperr.New(apperr.Conflict, "two_factor_not_enabled", "two factor not enabled")
+	ErrTwoFactorNotEnrolled = apperr.New(apperr.Conflict, "two_factor_not_enrolled", "two factor not enrolled")
+	ErrTwoFactorUnavailable = apperr.New(apperr.Internal, "two_factor_unavailable", "two factor unavailable")
+	ErrTwoFactorDisabled    = apperr.New(apperr.Conflict, "two_factor_disabled", "two factor is not configured on this server")
+	ErrIdentityNotFound     = apperr.New(apperr.NotFound, "identity_not_found", "identity not found")
+)
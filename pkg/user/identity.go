@@ -0,0 +1,252 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"redditclone/pkg/audit"
+	"redditclone/pkg/dbcall"
+	"redditclone/pkg/generator"
+	"redditclone/pkg/session"
+)
+
+const maxUsernameLen = 32
+
+// Identity links an account of an external provider to a local user.
+type Identity struct {
+	Provider string
+	Subject  string
+	UserID   string
+	Email    string
+}
+
+// ExternalProfile is what a provider asserted about the user signing in.
+type ExternalProfile struct {
+	Provider          string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+}
+
+type IdentityRepository interface {
+	Find(ctx context.Context, provider, subject string) (*Identity, error)
+	Create(ctx context.Context, identity *Identity) error
+}
+
+type IdentityServiceInterface interface {
+	LoginExternal(ctx context.Context, profile ExternalProfile) (*User, error)
+}
+
+type IdentityService struct {
+	Repo       Repository
+	Identities IdentityRepository
+	Session    session.Repository
+	TwoFactor  *TwoFactorService
+	Audit      audit.Logger
+}
+
+func NewIdentityService(repo Repository, identities IdentityRepository, session session.Repository, auditLog audit.Logger) *IdentityService {
+	return &IdentityService{
+		Repo:       repo,
+		Identities: identities,
+		Session:    session,
+		Audit:      auditLog,
+	}
+}
+
+// LoginExternal signs in the user linked to the external identity. Unknown
+// identities are linked to the local account with the same email when both
+// sides have verified it, otherwise a new account is created.
+func (s *IdentityService) LoginExternal(ctx context.Context, profile ExternalProfile) (_ *User, err error) {
+	defer func() { recordLogin("oidc", err) }()
+
+	user, err := s.linkedUser(ctx, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TOTPEnabled {
+		if s.TwoFactor == nil {
+			return nil, ErrTwoFactorUnavailable
+		}
+		challenge, err := s.TwoFactor.Challenge(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &SecondFactorRequiredError{Challenge: challenge}
+	}
+
+	if err := openSession(ctx, s.Session, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *IdentityService) linkedUser(ctx context.Context, profile ExternalProfile) (*User, error) {
+	identity, err := s.Identities.Find(ctx, profile.Provider, profile.Subject)
+	if err == nil {
+		return s.Repo.FindByID(ctx, identity.UserID)
+	}
+	if !errors.Is(err, ErrIdentityNotFound) {
+		return nil, err
+	}
+
+	email, err := NormalizeEmail(profile.Email)
+	if err != nil || !profile.EmailVerified {
+		email = ""
+	}
+
+	var user *User
+	if email != "" {
+		existing, err := s.Repo.FindByEmail(ctx, email)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		// an unverified local address proves nothing about who owns it
+		if existing != nil && existing.Verified {
+			user = existing
+		}
+		if existing != nil && !existing.Verified {
+			email = ""
+		}
+	}
+
+	if user == nil {
+		user, err = s.createUser(ctx, profile, email)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.Identities.Create(ctx, &Identity{
+		Provider: profile.Provider,
+		Subject:  profile.Subject,
+		UserID:   user.ID,
+		Email:    email,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.Audit.Record("identity_linked", "user", user.ID, "provider", profile.Provider)
+	return user, nil
+}
+
+// createUser registers an account without a usable password, the user can
+// set one through a password reset once the email is verified.
+func (s *IdentityService) createUser(ctx context.Context, profile ExternalProfile, email string) (*User, error) {
+	username, err := s.freeUsername(ctx, profile, email)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := generator.GenerateRandomID(40)
+	if err != nil {
+		return nil, fmt.Errorf("password gen error: %s", err)
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("hashing password error: %s", err)
+	}
+
+	userID, err := generator.GenerateRandomID(24)
+	if err != nil {
+		return nil, fmt.Errorf("UserID gen error: %s", err)
+	}
+
+	user := &User{
+		ID:       userID,
+		Username: username,
+		Password: string(hashedPassword),
+		Email:    email,
+		Verified: email != "",
+	}
+	if err := s.Repo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// freeUsername derives a username the routes accept from the profile and
+// adds a random suffix while it is taken.
+func (s *IdentityService) freeUsername(ctx context.Context, profile ExternalProfile, email string) (string, error) {
+	base := profile.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(email, "@")
+	}
+	base = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, base)
+	if base == "" {
+		base = "user"
+	}
+	if len(base) > maxUsernameLen-6 {
+		base = base[:maxUsernameLen-6]
+	}
+
+	candidate := base
+	for range 5 {
+		_, err := s.Repo.FindByUsername(ctx, candidate)
+		if err != nil && errors.Is(err, ErrNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		suffix, err := generator.GenerateRandomID(5)
+		if err != nil {
+			return "", fmt.Errorf("username gen error: %s", err)
+		}
+		candidate = base + suffix
+	}
+	return "", ErrExists
+}
+
+type MySQLIdentityRepo struct {
+	DB *sql.DB
+}
+
+func NewMySQLIdentityRepo(db *sql.DB) *MySQLIdentityRepo {
+	return &MySQLIdentityRepo{DB: db}
+}
+
+func (r *MySQLIdentityRepo) Find(ctx context.Context, provider, subject string) (*Identity, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "user_identities", "Find")
+	defer done()
+	identity := &Identity{Provider: provider, Subject: subject}
+	var email sql.NullString
+
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT user_id, email FROM user_identities WHERE provider = ? AND subject = ?",
+		provider, subject,
+	).Scan(&identity.UserID, &email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrIdentityNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	identity.Email = email.String
+	return identity, nil
+}
+
+func (r *MySQLIdentityRepo) Create(ctx context.Context, identity *Identity) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "user_identities", "Create")
+	defer done()
+	_, err := r.DB.ExecContext(ctx,
+		"INSERT INTO user_identities (provider, subject, user_id, email, created_at) VALUES (?, ?, ?, ?, ?)",
+		identity.Provider, identity.Subject, identity.UserID, nullString(identity.Email), time.Now().UTC(),
+	)
+	return err
+}
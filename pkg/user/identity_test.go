@@ -0,0 +1,81 @@
+package user_test
+
+import (
+	"context"
+	"testing"
+
+	"redditclone/pkg/audit"
+	"redditclone/pkg/user"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupIdentityService(t *testing.T) (*user.IdentityService, *user.MySQLRepo) {
+	db := setupUserDB(t)
+	repo := user.NewMySQLRepo(db)
+
+	sessions := new(mockSession)
+	sessions.On("Create", mock.Anything, mock.Anything).Return("sessid", nil)
+
+	return user.NewIdentityService(repo, user.NewMySQLIdentityRepo(db), sessions, audit.Nop{}), repo
+}
+
+func TestIdentityService_CreatesAndReuses(t *testing.T) {
+	ctx := context.Background()
+	svc, repo := setupIdentityService(t)
+
+	profile := user.ExternalProfile{
+		Provider:          "https://idp.test",
+		Subject:           "sub-1",
+		Email:             "Alice@Example.com",
+		EmailVerified:     true,
+		PreferredUsername: "alice.smith",
+	}
+
+	u, err := svc.LoginExternal(ctx, profile)
+	assert.NoError(t, err)
+	assert.Equal(t, "alicesmith", u.Username)
+	assert.Equal(t, "alice@example.com", u.Email)
+	assert.True(t, u.Verified)
+
+	again, err := svc.LoginExternal(ctx, profile)
+	assert.NoError(t, err)
+	assert.Equal(t, u.ID, again.ID)
+
+	// the same subject at another provider is another person
+	profile.Provider = "https://other.test"
+	profile.Email = ""
+	other, err := svc.LoginExternal(ctx, profile)
+	assert.NoError(t, err)
+	assert.NotEqual(t, u.ID, other.ID)
+	assert.NotEqual(t, u.Username, other.Username)
+	assert.Empty(t, other.Email)
+
+	stored, err := repo.FindByUsername(ctx, other.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, other.ID, stored.ID)
+}
+
+func TestIdentityService_LinksVerifiedEmail(t *testing.T) {
+	ctx := context.Background()
+	svc, repo := setupIdentityService(t)
+
+	assert.NoError(t, repo.Create(ctx, &user.User{ID: "verified", Username: "bob", Password: "x", Email: "bob@example.com", Verified: true}))
+	assert.NoError(t, repo.Create(ctx, &user.User{ID: "unverified", Username: "carol", Password: "x", Email: "carol@example.com"}))
+
+	u, err := svc.LoginExternal(ctx, user.ExternalProfile{Provider: "idp", Subject: "1", Email: "bob@example.com", EmailVerified: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "verified", u.ID)
+
+	// the provider does not vouch for the address
+	u, err = svc.LoginExternal(ctx, user.ExternalProfile{Provider: "idp", Subject: "2", Email: "bob@example.com"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, "verified", u.ID)
+
+	// the local address was never proven
+	u, err = svc.LoginExternal(ctx, user.ExternalProfile{Provider: "idp", Subject: "3", Email: "carol@example.com", EmailVerified: true})
+	assert.NoError(t, err)
+	assert.NotEqual(t, "unverified", u.ID)
+	assert.Empty(t, u.Email)
+}
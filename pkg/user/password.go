@@ -0,0 +1,267 @@
+package user
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"redditclone/pkg/audit"
+	"redditclone/pkg/dbcall"
+	"redditclone/pkg/generator"
+	"redditclone/pkg/mailer"
+	"redditclone/pkg/session"
+)
+
+// MinPasswordLen is the shortest password accepted on registration, change
+// and reset.
+const MinPasswordLen = 8
+
+const resetTokenLen = 40
+
+type PasswordReset struct {
+	TokenHash string
+	UserID    string
+	ExpiresAt time.Time
+	UsedAt    time.Time
+}
+
+type ResetRepository interface {
+	Create(ctx context.Context, reset *PasswordReset) error
+	FindByHash(ctx context.Context, tokenHash string) (*PasswordReset, error)
+	MarkUsed(ctx context.Context, tokenHash string, at time.Time) error
+	DeleteByUser(ctx context.Context, userID string) error
+}
+
+type PasswordServiceInterface interface {
+	ChangePassword(ctx context.Context, userID, current, next string) (*User, error)
+	RequestReset(ctx context.Context, username string) error
+	Reset(ctx context.Context, token, next string) error
+}
+
+type PasswordService struct {
+	Repo     Repository
+	Session  session.Repository
+	Resets   ResetRepository
+	Mailer   mailer.Mailer
+	Audit    audit.Logger
+	ResetTTL time.Duration
+	// ResetURL is prefixed to the token in reset emails, e.g. "https://host/reset?token=".
+	ResetURL string
+	Now      func() time.Time
+}
+
+func NewPasswordService(repo Repository, session session.Repository, resets ResetRepository, m mailer.Mailer, auditLog audit.Logger) *PasswordService {
+	return &PasswordService{
+		Repo:     repo,
+		Session:  session,
+		Resets:   resets,
+		Mailer:   m,
+		Audit:    auditLog,
+		ResetTTL: time.Hour,
+		Now:      time.Now,
+	}
+}
+
+// ChangePassword replaces the password after checking the current one, drops
+// every session of the user and opens a fresh one for the caller.
+func (s *PasswordService) ChangePassword(ctx context.Context, userID, current, next string) (*User, error) {
+	user, err := s.Repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(current)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	hashed, err := hashPassword(next)
+	if err != nil {
+		return nil, err
+	}
+	if err := setPassword(ctx, s.Repo, s.Session, user.ID, hashed); err != nil {
+		return nil, err
+	}
+
+	if err := openSession(ctx, s.Session, user); err != nil {
+		return nil, err
+	}
+
+	s.Audit.Record("password_changed", "user", user.ID)
+	return user, nil
+}
+
+// RequestReset mails a single use token to the user. Unknown usernames are
+// not reported so the endpoint cannot be used to probe for accounts.
+func (s *PasswordService) RequestReset(ctx context.Context, username string) error {
+	user, err := s.Repo.FindByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if user.Email == "" || !user.Verified {
+		s.Audit.Record("password_reset_undeliverable", "user", user.ID)
+		return nil
+	}
+
+	token, err := generator.GenerateRandomID(resetTokenLen)
+	if err != nil {
+		return fmt.Errorf("reset token gen error: %s", err)
+	}
+
+	if err := s.Resets.DeleteByUser(ctx, user.ID); err != nil {
+		return err
+	}
+	expires := s.Now().Add(s.ResetTTL)
+	if err := s.Resets.Create(ctx, &PasswordReset{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: expires,
+	}); err != nil {
+		return err
+	}
+
+	err = s.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf(
+			"Use this link to choose a new password, it expires at %s:\n\n%s%s\n\nIgnore this message if you did not ask for a reset.",
+			expires.Format(time.RFC1123), s.ResetURL, token,
+		),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send reset mail: %w", err)
+	}
+
+	s.Audit.Record("password_reset_requested", "user", user.ID)
+	return nil
+}
+
+func (s *PasswordService) Reset(ctx context.Context, token, next string) error {
+	reset, err := s.Resets.FindByHash(ctx, hashToken(token))
+	if err != nil {
+		return err
+	}
+	if !reset.UsedAt.IsZero() || !s.Now().Before(reset.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	// a rejected password must not use the token up
+	hashed, err := hashPassword(next)
+	if err != nil {
+		return err
+	}
+
+	// claim the token before touching the password so it cannot be replayed
+	if err := s.Resets.MarkUsed(ctx, reset.TokenHash, s.Now()); err != nil {
+		return err
+	}
+
+	if err := setPassword(ctx, s.Repo, s.Session, reset.UserID, hashed); err != nil {
+		return err
+	}
+
+	s.Audit.Record("password_reset", "user", reset.UserID)
+	return s.Resets.DeleteByUser(ctx, reset.UserID)
+}
+
+// hashPassword checks password against the policy and hashes it.
+func hashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLen {
+		return "", ErrPasswordTooShort
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("hashing password error: %s", err)
+	}
+	return string(hashed), nil
+}
+
+// setPassword stores the hashed password and drops every session of the user.
+func setPassword(ctx context.Context, repo Repository, sessions session.Repository, userID, hashed string) error {
+	if err := repo.UpdatePassword(ctx, userID, hashed); err != nil {
+		return err
+	}
+
+	return sessions.Invalidate(ctx, userID)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type MySQLResetRepo struct {
+	DB *sql.DB
+}
+
+func NewMySQLResetRepo(db *sql.DB) *MySQLResetRepo {
+	return &MySQLResetRepo{DB: db}
+}
+
+func (r *MySQLResetRepo) Create(ctx context.Context, reset *PasswordReset) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "password_resets", "Create")
+	defer done()
+	_, err := r.DB.ExecContext(ctx,
+		"INSERT INTO password_resets (token_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		reset.TokenHash, reset.UserID, time.Now().UTC(), reset.ExpiresAt.UTC(),
+	)
+	return err
+}
+
+func (r *MySQLResetRepo) FindByHash(ctx context.Context, tokenHash string) (*PasswordReset, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "password_resets", "FindByHash")
+	defer done()
+	reset := &PasswordReset{TokenHash: tokenHash}
+	var used sql.NullTime
+
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT user_id, expires_at, used_at FROM password_resets WHERE token_hash = ?",
+		tokenHash,
+	).Scan(&reset.UserID, &reset.ExpiresAt, &used)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidResetToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reset.UsedAt = used.Time
+	return reset, nil
+}
+
+func (r *MySQLResetRepo) MarkUsed(ctx context.Context, tokenHash string, at time.Time) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "password_resets", "MarkUsed")
+	defer done()
+	res, err := r.DB.ExecContext(ctx,
+		"UPDATE password_resets SET used_at = ? WHERE token_hash = ? AND used_at IS NULL",
+		at.UTC(), tokenHash,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidResetToken
+	}
+	return nil
+}
+
+func (r *MySQLResetRepo) DeleteByUser(ctx context.Context, userID string) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "password_resets", "DeleteByUser")
+	defer done()
+	_, err := r.DB.ExecContext(ctx, "DELETE FROM password_resets WHERE user_id = ?", userID)
+	return err
+}
@@ -0,0 +1,194 @@
+package user_test
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"redditclone/pkg/audit"
+	"redditclone/pkg/mailer"
+	"redditclone/pkg/user"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func setupUserDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+	CREATE TABLE users (
+		id TEXT PRIMARY KEY,
+		username TEXT NOT NULL UNIQUE,
+		password TEXT NOT NULL,
+		email TEXT NULL UNIQUE,
+		verified BOOLEAN NOT NULL DEFAULT FALSE,
+		totp_secret TEXT NULL,
+		totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+		totp_last_step INTEGER NOT NULL DEFAULT 0,
+		role TEXT NOT NULL DEFAULT 'user'
+	);
+	CREATE TABLE email_verifications (
+		token_hash TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		email TEXT NOT NULL,
+		created_at DATETIME,
+		expires_at DATETIME NOT NULL
+	);
+	CREATE TABLE recovery_codes (
+		code_hash TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		created_at DATETIME
+	);
+	CREATE TABLE login_challenges (
+		token_hash TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		expires_at DATETIME NOT NULL,
+		attempts INT NOT NULL DEFAULT 0
+	);
+	CREATE TABLE user_identities (
+		provider TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		email TEXT NULL,
+		created_at DATETIME,
+		PRIMARY KEY (provider, subject)
+	);
+	CREATE TABLE password_resets (
+		token_hash TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		created_at DATETIME,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME NULL
+	);
+	CREATE TABLE sessions (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL
+	);
+	CREATE TABLE api_tokens (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL
+	);
+	CREATE TABLE user_blocks (
+		blocker_id TEXT NOT NULL,
+		blocked_id TEXT NOT NULL,
+		PRIMARY KEY (blocker_id, blocked_id)
+	);`)
+	assert.NoError(t, err)
+
+	return db
+}
+
+// tokenFrom extracts the token from the link in a mail body.
+func tokenFrom(body string) string {
+	return strings.Fields(body[strings.Index(body, "token=")+len("token="):])[0]
+}
+
+func setupPasswordService(t *testing.T) (*user.PasswordService, *user.MySQLRepo, *mockSession, *mailer.MemoryMailer) {
+	db := setupUserDB(t)
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("oldpassword"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	repo := user.NewMySQLRepo(db)
+	assert.NoError(t, repo.Create(context.Background(), &user.User{
+		ID:       "uid",
+		Username: "bob",
+		Password: string(hashed),
+		Email:    "bob@example.com",
+		Verified: true,
+	}))
+
+	sessions := new(mockSession)
+	mail := mailer.NewMemoryMailer()
+	svc := user.NewPasswordService(repo, sessions, user.NewMySQLResetRepo(db), mail, audit.Nop{})
+	svc.ResetURL = "http://localhost/reset?token="
+
+	return svc, repo, sessions, mail
+}
+
+func passwordMatches(t *testing.T, repo *user.MySQLRepo, password string) bool {
+	u, err := repo.FindByID(context.Background(), "uid")
+	assert.NoError(t, err)
+	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)) == nil
+}
+
+func TestPasswordService_ChangePassword(t *testing.T) {
+	ctx := context.Background()
+	svc, repo, sessions, _ := setupPasswordService(t)
+
+	_, err := svc.ChangePassword(ctx, "uid", "wrong", "newpassword")
+	assert.EqualError(t, err, "invalid credentials")
+
+	_, err = svc.ChangePassword(ctx, "uid", "oldpassword", "short")
+	assert.EqualError(t, err, "password too short")
+
+	sessions.On("Invalidate", "uid").Return(nil)
+	sessions.On("Create", "uid", mock.Anything).Return("sessid", nil)
+
+	u, err := svc.ChangePassword(ctx, "uid", "oldpassword", "newpassword")
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", u.Username)
+	assert.True(t, passwordMatches(t, repo, "newpassword"))
+	sessions.AssertExpectations(t)
+}
+
+func TestPasswordService_Reset(t *testing.T) {
+	ctx := context.Background()
+	svc, repo, sessions, mail := setupPasswordService(t)
+	sessions.On("Invalidate", "uid").Return(nil)
+
+	// unknown users look exactly like known ones to the caller
+	assert.NoError(t, svc.RequestReset(ctx, "ghost"))
+	assert.Empty(t, mail.Sent())
+
+	assert.NoError(t, svc.RequestReset(ctx, "bob"))
+	assert.Len(t, mail.Sent(), 1)
+	assert.Equal(t, "bob@example.com", mail.Sent()[0].To)
+
+	token := tokenFrom(mail.Sent()[0].Body)
+
+	assert.EqualError(t, svc.Reset(ctx, "not-a-token", "newpassword"), "invalid reset token")
+
+	assert.NoError(t, svc.Reset(ctx, token, "newpassword"))
+	assert.True(t, passwordMatches(t, repo, "newpassword"))
+
+	// single use
+	assert.EqualError(t, svc.Reset(ctx, token, "otherpassword"), "invalid reset token")
+	assert.True(t, passwordMatches(t, repo, "newpassword"))
+}
+
+func TestPasswordService_ResetShortPasswordKeepsToken(t *testing.T) {
+	ctx := context.Background()
+	svc, repo, sessions, mail := setupPasswordService(t)
+	sessions.On("Invalidate", "uid").Return(nil)
+
+	assert.NoError(t, svc.RequestReset(ctx, "bob"))
+	token := tokenFrom(mail.Sent()[0].Body)
+
+	assert.ErrorIs(t, svc.Reset(ctx, token, "short"), user.ErrPasswordTooShort)
+	assert.True(t, passwordMatches(t, repo, "oldpassword"))
+
+	assert.NoError(t, svc.Reset(ctx, token, "newpassword"))
+	assert.True(t, passwordMatches(t, repo, "newpassword"))
+}
+
+func TestPasswordService_ResetExpired(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _, mail := setupPasswordService(t)
+
+	now := time.Now()
+	svc.Now = func() time.Time { return now }
+
+	assert.NoError(t, svc.RequestReset(ctx, "bob"))
+	token := tokenFrom(mail.Sent()[0].Body)
+
+	now = now.Add(2 * time.Hour)
+	assert.EqualError(t, svc.Reset(ctx, token, "newpassword"), "invalid reset token")
+}
@@ -1,8 +1,12 @@
 package user
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"strings"
+
+	"redditclone/pkg/dbcall"
 )
 
 type MySQLRepo struct {
@@ -13,10 +17,15 @@ func NewMySQLRepo(db *sql.DB) *MySQLRepo {
 	return &MySQLRepo{DB: db}
 }
 
-func (r *MySQLRepo) Create(user *User) error {
-	_, err := r.DB.Exec(
-		"INSERT INTO users (id, username, password) VALUES (?, ?, ?)",
-		user.ID, user.Username, user.Password,
+func (r *MySQLRepo) Create(ctx context.Context, user *User) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "users", "Create")
+	defer done()
+	if user.Role == "" {
+		user.Role = RoleUser
+	}
+	_, err := r.DB.ExecContext(ctx,
+		"INSERT INTO users (id, username, password, email, verified, role) VALUES (?, ?, ?, ?, ?, ?)",
+		user.ID, user.Username, user.Password, nullString(user.Email), user.Verified, user.Role,
 	)
 	if err != nil {
 		return err
@@ -24,19 +33,147 @@ func (r *MySQLRepo) Create(user *User) error {
 	return nil
 }
 
-func (r *MySQLRepo) FindByUsername(username string) (*User, error) {
+func (r *MySQLRepo) FindByUsername(ctx context.Context, username string) (*User, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "users", "FindByUsername")
+	defer done()
+	return r.findBy(ctx, "username", username)
+}
+
+func (r *MySQLRepo) FindByID(ctx context.Context, id string) (*User, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "users", "FindByID")
+	defer done()
+	return r.findBy(ctx, "id", id)
+}
+
+func (r *MySQLRepo) FindByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "users", "FindByEmail")
+	defer done()
+	return r.findBy(ctx, "email", email)
+}
+
+// findBy looks a user up by one of the unique columns, column is never user input.
+func (r *MySQLRepo) findBy(ctx context.Context, column, value string) (*User, error) {
 	var u User
-	err := r.DB.QueryRow(
-		"SELECT id, username, password FROM users WHERE username = ?",
-		username,
-	).Scan(&u.ID, &u.Username, &u.Password)
+	var email sql.NullString
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT id, username, password, email, verified, totp_enabled, role FROM users WHERE "+column+" = ?",
+		value,
+	).Scan(&u.ID, &u.Username, &u.Password, &email, &u.Verified, &u.TOTPEnabled, &u.Role)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("user not found")
+			return nil, ErrNotFound
 		}
 		return nil, err
 	}
 
+	u.Email = email.String
 	return &u, nil
 }
+
+func (r *MySQLRepo) UpdatePassword(ctx context.Context, id, password string) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "users", "UpdatePassword")
+	defer done()
+	res, err := r.DB.ExecContext(ctx, "UPDATE users SET password = ? WHERE id = ?", password, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// SetEmail replaces the address and drops the verified flag.
+func (r *MySQLRepo) SetEmail(ctx context.Context, id, email string) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "users", "SetEmail")
+	defer done()
+	_, err := r.DB.ExecContext(ctx,
+		"UPDATE users SET email = ?, verified = FALSE WHERE id = ?",
+		nullString(email), id,
+	)
+	return err
+}
+
+// MarkVerified only succeeds while the user still has the verified address.
+func (r *MySQLRepo) MarkVerified(ctx context.Context, id, email string) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "users", "MarkVerified")
+	defer done()
+	res, err := r.DB.ExecContext(ctx,
+		"UPDATE users SET verified = TRUE WHERE id = ? AND email = ?",
+		id, email,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrInvalidVerificationToken
+	}
+	return err
+}
+
+func (r *MySQLRepo) SetRole(ctx context.Context, id, role string) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "users", "SetRole")
+	defer done()
+	res, err := r.DB.ExecContext(ctx, "UPDATE users SET role = ? WHERE id = ?", role, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// dependents empty the tables referencing users, the foreign keys do not
+// cascade.
+var dependents = []string{
+	"DELETE FROM sessions WHERE user_id = ?",
+	"DELETE FROM api_tokens WHERE user_id = ?",
+	"DELETE FROM password_resets WHERE user_id = ?",
+	"DELETE FROM email_verifications WHERE user_id = ?",
+	"DELETE FROM recovery_codes WHERE user_id = ?",
+	"DELETE FROM login_challenges WHERE user_id = ?",
+	"DELETE FROM user_identities WHERE user_id = ?",
+	"DELETE FROM user_blocks WHERE blocker_id = ? OR blocked_id = ?",
+}
+
+// Delete removes the user with every row referencing it in one transaction.
+// Posts and comments live in Mongo and keep their author.
+func (r *MySQLRepo) Delete(ctx context.Context, id string) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "users", "Delete")
+	defer done()
+
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range dependents {
+		args := []any{id}
+		if strings.Count(query, "?") == 2 {
+			args = append(args, id)
+		}
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
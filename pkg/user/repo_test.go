@@ -1,6 +1,7 @@
 package user_test
 
 import (
+	"context"
 	"database/sql"
 	"testing"
 
@@ -18,7 +19,13 @@ func setupTestDB(t *testing.T) *sql.DB {
 	CREATE TABLE users (
 		id TEXT PRIMARY KEY,
 		username TEXT NOT NULL UNIQUE,
-		password TEXT NOT NULL
+		password TEXT NOT NULL,
+		email TEXT NULL UNIQUE,
+		verified BOOLEAN NOT NULL DEFAULT FALSE,
+		totp_secret TEXT NULL,
+		totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+		totp_last_step INTEGER NOT NULL DEFAULT 0,
+		role TEXT NOT NULL DEFAULT 'user'
 	);`
 
 	_, err = db.Exec(schema)
@@ -45,6 +52,7 @@ func setupTestBadDB(t *testing.T) *sql.DB {
 }
 
 func TestMySQLRepo_CreateAndFind(t *testing.T) {
+	ctx := context.Background()
 	db := setupTestDB(t)
 	repo := user.NewMySQLRepo(db)
 
@@ -53,7 +61,7 @@ func TestMySQLRepo_CreateAndFind(t *testing.T) {
 		Username: "sj379d0xmsdl028sfdy3",
 		Password: "hashed_pass",
 	}
-	err := repo.Create(_user_)
+	err := repo.Create(ctx, _user_)
 	assert.NoError(t, err)
 
 	_user2_ := &user.User{
@@ -61,11 +69,11 @@ func TestMySQLRepo_CreateAndFind(t *testing.T) {
 		Username: "sj379d0xmsdl028sfdy3",
 		Password: "hashed_pass",
 	}
-	err = repo.Create(_user2_)
+	err = repo.Create(ctx, _user2_)
 	assert.Error(t, err)
 
 	// Test FindByUsername
-	u, err := repo.FindByUsername(_user_.Username)
+	u, err := repo.FindByUsername(ctx, _user_.Username)
 	assert.NoError(t, err)
 	assert.NotNil(t, u)
 
@@ -74,7 +82,7 @@ func TestMySQLRepo_CreateAndFind(t *testing.T) {
 		Username: "sj379d0xm9sdl028sfdy3",
 		Password: "hashed_pass",
 	}
-	u2, err := repo.FindByUsername(_user3_.Username)
+	u2, err := repo.FindByUsername(ctx, _user3_.Username)
 	assert.Error(t, err)
 	assert.Nil(t, u2)
 	assert.Equal(t, "user not found", err.Error())
@@ -85,7 +93,7 @@ func TestMySQLRepo_CreateAndFind(t *testing.T) {
 	_, err = db2.Exec("INSERT INTO users (id, password) VALUES (?, ?)", "u123", "somepass")
 	assert.NoError(t, err)
 
-	_, err = repo2.FindByUsername("whoever")
+	_, err = repo2.FindByUsername(ctx, "whoever")
 	assert.Error(t, err)
 
 	assert.NotEqual(t, "user not found", err.Error())
@@ -1,36 +1,80 @@
 package user
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"golang.org/x/crypto/bcrypt"
 	"redditclone/pkg/generator"
+	"redditclone/pkg/metrics"
 	"redditclone/pkg/session"
+	"redditclone/pkg/tracing"
 )
 
 type ServiceInterface interface {
-	Register(username, password string) (*User, error)
-	Login(username, password string) (*User, error)
+	Register(ctx context.Context, username, password, email string) (*User, error)
+	Login(ctx context.Context, username, password, ip string) (*User, error)
 }
 
 type Service struct {
-	Repo    Repository
-	Session session.Repository
+	Repo      Repository
+	Session   session.Repository
+	Throttle  *Throttle
+	Email     *EmailService
+	TwoFactor *TwoFactorService
 }
 
+// dummyHash keeps the response time of unknown usernames close to the one of
+// wrong passwords.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
 func NewService(repo Repository, session session.Repository) *Service {
 	return &Service{Repo: repo, Session: session}
 }
 
-func (s *Service) Register(username, password string) (*User, error) {
-	exist, err := s.Repo.FindByUsername(username)
+// Register creates the account, email is optional and starts unverified.
+func (s *Service) Register(ctx context.Context, username, password, email string) (*User, error) {
+	ctx, span := tracing.Start(ctx, "user.Service.Register")
+	defer span.End()
+
+	user, err := create(ctx, s.Repo, username, password, email, RoleUser)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Email != nil && user.Email != "" {
+		// the account is usable anyway, a failed delivery can be retried
+		// through the resend endpoint
+		_ = s.Email.SendVerification(ctx, user)
+	}
+
+	if err := openSession(ctx, s.Session, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// create stores a new account, shared by Register and the operator commands.
+func create(ctx context.Context, repo Repository, username, password, email, role string) (*User, error) {
+	exist, err := repo.FindByUsername(ctx, username)
 	if exist != nil && err == nil {
-		return nil, errors.New("user already exists")
+		return nil, ErrExists
+	}
+
+	email, err = NormalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if email != "" {
+		if err := CheckAvailable(ctx, repo, email, ""); err != nil {
+			return nil, err
+		}
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := hashPassword(password)
 	if err != nil {
-		return nil, fmt.Errorf("hashing password error: %s", err)
+		return nil, err
 	}
 
 	userID, err := generator.GenerateRandomID(24)
@@ -41,45 +85,105 @@ func (s *Service) Register(username, password string) (*User, error) {
 	user := &User{
 		ID:       userID,
 		Username: username,
-		Password: string(hashedPassword),
+		Password: hashedPassword,
+		Email:    email,
+		Role:     role,
 	}
 
-	err = s.Repo.Create(user)
+	err = repo.Create(ctx, user)
 	if err != nil {
 		return nil, err
 	}
+	return user, nil
+}
 
-	sessionID, err := generator.GenerateRandomID(24)
-	if err != nil {
-		return nil, fmt.Errorf("SessionID gen error: %s", err)
+// Login never tells an unknown username apart from a wrong password, both
+// fail with "invalid credentials".
+func (s *Service) Login(ctx context.Context, username, password, ip string) (_ *User, err error) {
+	ctx, span := tracing.Start(ctx, "user.Service.Login")
+	defer span.End()
+
+	defer func() { recordLogin("password", err) }()
+
+	if s.Throttle != nil {
+		if err := s.Throttle.Check(ctx, username, ip); err != nil {
+			return nil, err
+		}
 	}
-	_, err = s.Session.Create(user.ID, sessionID)
-	if err != nil {
-		return nil, errors.New("failed to create session")
+
+	user, err := s.Repo.FindByUsername(ctx, username)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
 	}
 
-	return user, nil
-}
+	hash := dummyHash
+	if user != nil {
+		hash = []byte(user.Password)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || user == nil {
+		if s.Throttle != nil {
+			if err := s.Throttle.Fail(ctx, username, ip); err != nil {
+				return nil, err
+			}
+		}
+		return nil, ErrInvalidCredentials
+	}
 
-func (s *Service) Login(username, password string) (*User, error) {
-	user, err := s.Repo.FindByUsername(username)
-	if err != nil {
-		return nil, errors.New("user not found")
+	if user.TOTPEnabled {
+		if s.TwoFactor == nil {
+			return nil, ErrTwoFactorUnavailable
+		}
+		challenge, err := s.TwoFactor.Challenge(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &SecondFactorRequiredError{Challenge: challenge}
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
-	if err != nil {
-		return nil, errors.New("invalid credentials")
+	// With 2FA the counter is cleared by TwoFactorService.Complete instead,
+	// once the second factor has been checked too.
+	if s.Throttle != nil {
+		if err := s.Throttle.Succeed(ctx, username); err != nil {
+			return nil, err
+		}
 	}
 
+	if err := openSession(ctx, s.Session, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// recordLogin counts a finished login attempt. Asking for the second factor
+// is neither, the attempt is counted once the code comes in.
+func recordLogin(method string, err error) {
+	var secondFactor *SecondFactorRequiredError
+	var throttled *ThrottledError
+	result := "success"
+	switch {
+	case err == nil:
+	case errors.As(err, &secondFactor):
+		return
+	case errors.As(err, &throttled):
+		result = "failure"
+	case errors.Is(err, ErrInvalidCredentials), errors.Is(err, ErrInvalidCode), errors.Is(err, ErrInvalidChallenge):
+		result = "failure"
+	default:
+		result = "error"
+	}
+	metrics.Logins.WithLabelValues(method, result).Inc()
+}
+
+// openSession starts a session for user and sets its SessionID.
+func openSession(ctx context.Context, sessions session.Repository, user *User) error {
 	sessionID, err := generator.GenerateRandomID(24)
 	if err != nil {
-		return nil, fmt.Errorf("SessionID gen error: %s", err)
+		return fmt.Errorf("SessionID gen error: %s", err)
 	}
-	_, err = s.Session.Create(user.ID, sessionID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create session: %s", err)
+	if _, err := sessions.Create(ctx, user.ID, sessionID); err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
 	}
-
-	return user, nil
+	user.SessionID = sessionID
+	return nil
 }
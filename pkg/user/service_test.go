@@ -1,7 +1,7 @@
 package user_test
 
 import (
-	"errors"
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -18,7 +18,7 @@ type mockSession struct {
 	mock.Mock
 }
 
-func (m *mockRepo) FindByUsername(username string) (*user.User, error) {
+func (m *mockRepo) FindByUsername(ctx context.Context, username string) (*user.User, error) {
 	args := m.Called(username)
 	if u := args.Get(0); u != nil {
 		return u.(*user.User), args.Error(1)
@@ -26,25 +26,66 @@ func (m *mockRepo) FindByUsername(username string) (*user.User, error) {
 	return nil, args.Error(1)
 }
 
-func (m *mockRepo) Create(u *user.User) error {
+func (m *mockRepo) Create(ctx context.Context, u *user.User) error {
 	return m.Called(u).Error(0)
 }
 
-func (m *mockSession) Create(userID, sessionID string) (string, error) {
+func (m *mockRepo) FindByID(ctx context.Context, id string) (*user.User, error) {
+	args := m.Called(id)
+	if u := args.Get(0); u != nil {
+		return u.(*user.User), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockRepo) UpdatePassword(ctx context.Context, id, password string) error {
+	return m.Called(id, password).Error(0)
+}
+
+func (m *mockRepo) FindByEmail(ctx context.Context, email string) (*user.User, error) {
+	args := m.Called(email)
+	if u := args.Get(0); u != nil {
+		return u.(*user.User), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockRepo) SetEmail(ctx context.Context, id, email string) error {
+	return m.Called(id, email).Error(0)
+}
+
+func (m *mockRepo) MarkVerified(ctx context.Context, id, email string) error {
+	return m.Called(id, email).Error(0)
+}
+
+func (m *mockRepo) SetRole(ctx context.Context, id, role string) error {
+	return m.Called(id, role).Error(0)
+}
+
+func (m *mockRepo) Delete(ctx context.Context, id string) error {
+	return m.Called(id).Error(0)
+}
+
+func (m *mockSession) Create(ctx context.Context, userID, sessionID string) (string, error) {
 	args := m.Called(userID, sessionID)
 	return args.String(0), args.Error(1)
 }
 
-func (m *mockSession) IsValid(userID string) (bool, error) {
-	args := m.Called(userID)
+func (m *mockSession) IsValid(ctx context.Context, userID, sessionID string) (bool, error) {
+	args := m.Called(userID, sessionID)
 	return args.Bool(0), args.Error(1)
 }
 
-func (m *mockSession) Invalidate(userID string) error {
+func (m *mockSession) Invalidate(ctx context.Context, userID string) error {
 	return m.Called(userID).Error(0)
 }
 
+func (m *mockSession) Touch(ctx context.Context, userID, sessionID string) error {
+	return m.Called(userID, sessionID).Error(0)
+}
+
 func TestService_Register(t *testing.T) {
+	ctx := context.Background()
 	repo := new(mockRepo)
 	session := new(mockSession)
 	svc := user.NewService(repo, session)
@@ -54,7 +95,7 @@ func TestService_Register(t *testing.T) {
 		repo.On("Create", mock.AnythingOfType("*user.User")).Return(nil)
 		session.On("Create", mock.Anything, mock.Anything).Return("sessid", nil)
 
-		u, err := svc.Register("newuser", "securepass")
+		u, err := svc.Register(ctx, "newuser", "securepass", "")
 
 		assert.NoError(t, err)
 		assert.NotNil(t, u)
@@ -64,15 +105,25 @@ func TestService_Register(t *testing.T) {
 	t.Run("user already exists", func(t *testing.T) {
 		repo.On("FindByUsername", "existing").Return(&user.User{Username: "existing"}, nil)
 
-		u, err := svc.Register("existing", "pass")
+		u, err := svc.Register(ctx, "existing", "pass", "")
 
 		assert.Error(t, err)
 		assert.Nil(t, u)
 		assert.Equal(t, "user already exists", err.Error())
 	})
+
+	t.Run("password too short", func(t *testing.T) {
+		repo.On("FindByUsername", "shortpass").Return(nil, nil)
+
+		u, err := svc.Register(ctx, "shortpass", "1234567", "")
+
+		assert.ErrorIs(t, err, user.ErrPasswordTooShort)
+		assert.Nil(t, u)
+	})
 }
 
 func TestService_Login(t *testing.T) {
+	ctx := context.Background()
 	repo := new(mockRepo)
 	session := new(mockSession)
 	svc := user.NewService(repo, session)
@@ -88,20 +139,20 @@ func TestService_Login(t *testing.T) {
 		}, nil)
 		session.On("Create", "uid", mock.Anything).Return("sessid", nil)
 
-		u, err := svc.Login("valid", "correct")
+		u, err := svc.Login(ctx, "valid", "correct", "127.0.0.1")
 
 		assert.NoError(t, err)
 		assert.Equal(t, "valid", u.Username)
 	})
 
 	t.Run("not found", func(t *testing.T) {
-		repo.On("FindByUsername", "ghost").Return(nil, errors.New("not found"))
+		repo.On("FindByUsername", "ghost").Return(nil, user.ErrNotFound)
 
-		u, err := svc.Login("ghost", "any")
+		u, err := svc.Login(ctx, "ghost", "any", "127.0.0.1")
 
 		assert.Error(t, err)
 		assert.Nil(t, u)
-		assert.Equal(t, "user not found", err.Error())
+		assert.Equal(t, "invalid credentials", err.Error())
 	})
 
 	t.Run("wrong password", func(t *testing.T) {
@@ -111,7 +162,7 @@ func TestService_Login(t *testing.T) {
 			Password: string(hashed),
 		}, nil)
 
-		u, err := svc.Login("valid", "wrong")
+		u, err := svc.Login(ctx, "valid", "wrong", "127.0.0.1")
 
 		assert.Error(t, err)
 		assert.Nil(t, u)
@@ -125,7 +176,7 @@ func TestService_Login(t *testing.T) {
 			Password: "oops",
 		}, nil)
 
-		u, err := svc.Login("valid", "wrong")
+		u, err := svc.Login(ctx, "valid", "wrong", "127.0.0.1")
 
 		assert.Error(t, err)
 		assert.Nil(t, u)
@@ -0,0 +1,271 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"redditclone/pkg/apperr"
+	"redditclone/pkg/audit"
+	"redditclone/pkg/dbcall"
+)
+
+type Attempt struct {
+	Key         string
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+type AttemptRepository interface {
+	Get(ctx context.Context, key string) (*Attempt, error)
+	// AddFailure counts a failure at now in one step, starting over when the
+	// last one is older than window, and returns the counter after it.
+	AddFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*Attempt, error)
+	// Lock locks key until, unless it is locked already, and reports whether
+	// it did.
+	Lock(ctx context.Context, key string, now, until time.Time) (bool, error)
+	Reset(ctx context.Context, key string) error
+}
+
+// ThrottlePolicy describes how quickly failed logins slow down a key.
+// The first FreeFailures attempts are not delayed, then each failure doubles
+// the wait starting at BaseDelay up to MaxDelay. After LockAfter failures the
+// key is locked for LockFor. Failures older than Window are forgotten.
+type ThrottlePolicy struct {
+	FreeFailures int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	LockAfter    int
+	LockFor      time.Duration
+	Window       time.Duration
+}
+
+var (
+	DefaultAccountPolicy = ThrottlePolicy{
+		FreeFailures: 3,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockAfter:    10,
+		LockFor:      15 * time.Minute,
+		Window:       time.Hour,
+	}
+	// DefaultIPPolicy is looser, many users can share one address.
+	DefaultIPPolicy = ThrottlePolicy{
+		FreeFailures: 10,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockAfter:    50,
+		LockFor:      15 * time.Minute,
+		Window:       time.Hour,
+	}
+)
+
+// ThrottledError is returned while a key waits for its backoff or lockout to end.
+type ThrottledError struct {
+	Until time.Time
+}
+
+func (e *ThrottledError) Error() string {
+	return "too many failed attempts"
+}
+
+func (e *ThrottledError) Kind() apperr.Kind  { return apperr.TooManyRequests }
+func (e *ThrottledError) Code() string       { return "too_many_attempts" }
+func (e *ThrottledError) RetryAt() time.Time { return e.Until }
+
+type Throttle struct {
+	Repo    AttemptRepository
+	Account ThrottlePolicy
+	IP      ThrottlePolicy
+	Audit   audit.Logger
+	Now     func() time.Time
+}
+
+func NewThrottle(repo AttemptRepository, auditLog audit.Logger) *Throttle {
+	return &Throttle{
+		Repo:    repo,
+		Account: DefaultAccountPolicy,
+		IP:      DefaultIPPolicy,
+		Audit:   auditLog,
+		Now:     time.Now,
+	}
+}
+
+func accountKey(username string) string { return "user:" + username }
+func ipKey(ip string) string            { return "ip:" + ip }
+
+// Check fails with *ThrottledError when either the account or the IP must wait.
+func (t *Throttle) Check(ctx context.Context, username, ip string) error {
+	now := t.Now()
+	var until time.Time
+
+	for _, key := range []string{accountKey(username), ipKey(ip)} {
+		a, err := t.Repo.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if blocked := t.policy(key).blockedUntil(a); blocked.After(now) && blocked.After(until) {
+			until = blocked
+		}
+	}
+
+	if !until.IsZero() {
+		return &ThrottledError{Until: until}
+	}
+	return nil
+}
+
+func (t *Throttle) Fail(ctx context.Context, username, ip string) error {
+	now := t.Now()
+
+	for _, key := range []string{accountKey(username), ipKey(ip)} {
+		p := t.policy(key)
+
+		a, err := t.Repo.AddFailure(ctx, key, now, p.Window)
+		if err != nil {
+			return err
+		}
+		if a.Failures < p.LockAfter || a.LockedUntil.After(now) {
+			continue
+		}
+
+		until := now.Add(p.LockFor)
+		locked, err := t.Repo.Lock(ctx, key, now, until)
+		if err != nil {
+			return err
+		}
+		if locked {
+			t.Audit.Record("login_lockout", "key", key, "failures", a.Failures, "until", until)
+		}
+	}
+	return nil
+}
+
+// Succeed clears the account counter. The IP counter is left to expire so one
+// valid account cannot be used to reset an address guessing other passwords.
+func (t *Throttle) Succeed(ctx context.Context, username string) error {
+	return t.Repo.Reset(ctx, accountKey(username))
+}
+
+func (t *Throttle) policy(key string) ThrottlePolicy {
+	if strings.HasPrefix(key, "ip:") {
+		return t.IP
+	}
+	return t.Account
+}
+
+func (p ThrottlePolicy) blockedUntil(a *Attempt) time.Time {
+	if a.LockedUntil.After(a.LastFailure) {
+		return a.LockedUntil
+	}
+	over := a.Failures - p.FreeFailures
+	if over <= 0 {
+		return time.Time{}
+	}
+
+	delay := p.BaseDelay << (over - 1)
+	if delay > p.MaxDelay || delay <= 0 {
+		delay = p.MaxDelay
+	}
+	return a.LastFailure.Add(delay)
+}
+
+type MySQLAttemptRepo struct {
+	DB *sql.DB
+}
+
+func NewMySQLAttemptRepo(db *sql.DB) *MySQLAttemptRepo {
+	return &MySQLAttemptRepo{DB: db}
+}
+
+// Get returns an empty attempt for keys that never failed.
+func (r *MySQLAttemptRepo) Get(ctx context.Context, key string) (*Attempt, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "login_attempts", "Get")
+	defer done()
+	a := &Attempt{Key: key}
+	var last, locked sql.NullTime
+
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT failures, last_failure, locked_until FROM login_attempts WHERE attempt_key = ?",
+		key,
+	).Scan(&a.Failures, &last, &locked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a.LastFailure = last.Time
+	a.LockedUntil = locked.Time
+	return a, nil
+}
+
+// AddFailure updates the row in a single statement so parallel failures are
+// all counted. The first failure of a key inserts the row, a key inserted by
+// a parallel failure meanwhile is updated instead.
+func (r *MySQLAttemptRepo) AddFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*Attempt, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "login_attempts", "AddFailure")
+	defer done()
+
+	now = now.UTC()
+	expired := now.Add(-window)
+	// last_failure goes last, MySQL assigns left to right
+	update := func() (bool, error) {
+		res, err := r.DB.ExecContext(ctx,
+			`UPDATE login_attempts SET
+				failures = CASE WHEN last_failure < ? THEN 1 ELSE failures + 1 END,
+				locked_until = CASE WHEN last_failure < ? THEN NULL ELSE locked_until END,
+				last_failure = ?
+			WHERE attempt_key = ?`,
+			expired, expired, now, key,
+		)
+		if err != nil {
+			return false, err
+		}
+		n, err := res.RowsAffected()
+		return n > 0, err
+	}
+
+	updated, err := update()
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		_, err := r.DB.ExecContext(ctx,
+			"INSERT INTO login_attempts (attempt_key, failures, last_failure) VALUES (?, 1, ?)",
+			key, now,
+		)
+		if err != nil {
+			if updated, _ = update(); !updated {
+				return nil, err
+			}
+		}
+	}
+
+	return r.Get(ctx, key)
+}
+
+func (r *MySQLAttemptRepo) Lock(ctx context.Context, key string, now, until time.Time) (bool, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "login_attempts", "Lock")
+	defer done()
+	res, err := r.DB.ExecContext(ctx,
+		"UPDATE login_attempts SET locked_until = ? WHERE attempt_key = ? AND (locked_until IS NULL OR locked_until <= ?)",
+		until.UTC(), key, now.UTC(),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *MySQLAttemptRepo) Reset(ctx context.Context, key string) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "login_attempts", "Reset")
+	defer done()
+	_, err := r.DB.ExecContext(ctx, "DELETE FROM login_attempts WHERE attempt_key = ?", key)
+	return err
+}
@@ -0,0 +1,146 @@
+package user_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"redditclone/pkg/user"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordedAudit struct {
+	events []string
+}
+
+func (a *recordedAudit) Record(event string, attrs ...any) {
+	a.events = append(a.events, event)
+}
+
+func setupThrottle(t *testing.T) (*user.Throttle, *recordedAudit, *time.Time) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+	CREATE TABLE login_attempts (
+		attempt_key TEXT PRIMARY KEY,
+		failures INT NOT NULL DEFAULT 0,
+		last_failure DATETIME,
+		locked_until DATETIME NULL
+	);`)
+	assert.NoError(t, err)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	auditLog := &recordedAudit{}
+
+	throttle := user.NewThrottle(user.NewMySQLAttemptRepo(db), auditLog)
+	throttle.Account = user.ThrottlePolicy{
+		FreeFailures: 2,
+		BaseDelay:    time.Second,
+		MaxDelay:     4 * time.Second,
+		LockAfter:    5,
+		LockFor:      time.Minute,
+		Window:       time.Hour,
+	}
+	throttle.Now = func() time.Time { return now }
+
+	return throttle, auditLog, &now
+}
+
+func TestThrottle_Backoff(t *testing.T) {
+	ctx := context.Background()
+	throttle, _, now := setupThrottle(t)
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, throttle.Check(ctx, "bob", "10.0.0.1"))
+		assert.NoError(t, throttle.Fail(ctx, "bob", "10.0.0.1"))
+	}
+	assert.NoError(t, throttle.Check(ctx, "bob", "10.0.0.1"))
+
+	// third failure starts the backoff at one second
+	assert.NoError(t, throttle.Fail(ctx, "bob", "10.0.0.1"))
+	err := throttle.Check(ctx, "bob", "10.0.0.1")
+
+	var throttled *user.ThrottledError
+	assert.True(t, errors.As(err, &throttled))
+	assert.Equal(t, now.Add(time.Second), throttled.Until)
+
+	*now = now.Add(time.Second)
+	assert.NoError(t, throttle.Check(ctx, "bob", "10.0.0.1"))
+
+	// fourth failure doubles it
+	assert.NoError(t, throttle.Fail(ctx, "bob", "10.0.0.1"))
+	assert.True(t, errors.As(throttle.Check(ctx, "bob", "10.0.0.1"), &throttled))
+	assert.Equal(t, now.Add(2*time.Second), throttled.Until)
+
+	// other accounts from another address are unaffected
+	assert.NoError(t, throttle.Check(ctx, "alice", "10.0.0.2"))
+}
+
+func TestThrottle_LockoutAndReset(t *testing.T) {
+	ctx := context.Background()
+	throttle, auditLog, now := setupThrottle(t)
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, throttle.Fail(ctx, "bob", "10.0.0.1"))
+	}
+	assert.Equal(t, []string{"login_lockout"}, auditLog.events)
+
+	var throttled *user.ThrottledError
+	assert.True(t, errors.As(throttle.Check(ctx, "bob", "10.0.0.1"), &throttled))
+	assert.Equal(t, now.Add(time.Minute), throttled.Until)
+
+	*now = now.Add(time.Minute)
+	assert.NoError(t, throttle.Check(ctx, "bob", "10.0.0.1"))
+
+	assert.NoError(t, throttle.Succeed(ctx, "bob"))
+	assert.NoError(t, throttle.Fail(ctx, "bob", "10.0.0.1"))
+	assert.NoError(t, throttle.Check(ctx, "bob", "10.0.0.1"))
+}
+
+// slowGets widens the gap between reading and writing a counter.
+type slowGets struct {
+	*user.MySQLAttemptRepo
+}
+
+func (r slowGets) Get(ctx context.Context, key string) (*user.Attempt, error) {
+	a, err := r.MySQLAttemptRepo.Get(ctx, key)
+	time.Sleep(5 * time.Millisecond)
+	return a, err
+}
+
+func TestThrottle_ParallelFailuresAreAllCounted(t *testing.T) {
+	ctx := context.Background()
+	throttle, auditLog, now := setupThrottle(t)
+	throttle.Repo = slowGets{throttle.Repo.(*user.MySQLAttemptRepo)}
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, throttle.Fail(ctx, "bob", fmt.Sprintf("10.0.0.%d", i)))
+		}()
+	}
+	wg.Wait()
+
+	a, err := throttle.Repo.Get(ctx, "user:bob")
+	assert.NoError(t, err)
+	assert.Equal(t, 20, a.Failures)
+	assert.Equal(t, []string{"login_lockout"}, auditLog.events)
+
+	// a failure after the window starts over and drops the lock
+	*now = now.Add(2 * time.Hour)
+	assert.NoError(t, throttle.Fail(ctx, "bob", "10.0.0.1"))
+	a, err = throttle.Repo.Get(ctx, "user:bob")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, a.Failures)
+	assert.True(t, a.LockedUntil.IsZero())
+}
@@ -0,0 +1,510 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"redditclone/pkg/audit"
+	"redditclone/pkg/dbcall"
+	"redditclone/pkg/generator"
+	"redditclone/pkg/secretbox"
+	"redditclone/pkg/session"
+	"redditclone/pkg/totp"
+)
+
+const (
+	challengeTokenLen   = 40
+	maxChallengeTries   = 5
+	recoveryCodeCount   = 10
+	recoveryCodeHalfLen = 5
+)
+
+// SecondFactorRequiredError is returned by Login for accounts with 2FA, the
+// challenge has to be completed through TwoFactorService.Complete.
+type SecondFactorRequiredError struct {
+	Challenge string
+}
+
+func (e *SecondFactorRequiredError) Error() string {
+	return "second factor required"
+}
+
+type TOTPState struct {
+	SealedSecret string
+	Enabled      bool
+	LastStep     int64
+}
+
+type Challenge struct {
+	TokenHash string
+	UserID    string
+	ExpiresAt time.Time
+	Attempts  int
+}
+
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type TwoFactorRepository interface {
+	GetTOTP(ctx context.Context, userID string) (*TOTPState, error)
+	SetTOTPSecret(ctx context.Context, userID, sealedSecret string) error
+	EnableTOTP(ctx context.Context, userID string) error
+	ClearTOTP(ctx context.Context, userID string) error
+	// AdvanceStep stores the last accepted step, failing with ErrStepUsed when
+	// it is not newer.
+	AdvanceStep(ctx context.Context, userID string, step int64) error
+
+	ReplaceRecoveryCodes(ctx context.Context, userID string, hashes []string) error
+	UseRecoveryCode(ctx context.Context, userID, hash string) (bool, error)
+
+	CreateChallenge(ctx context.Context, c *Challenge) error
+	FindChallenge(ctx context.Context, tokenHash string) (*Challenge, error)
+	FailChallenge(ctx context.Context, tokenHash string) error
+	// DeleteChallenge claims the challenge, failing with ErrInvalidChallenge
+	// when it is already gone.
+	DeleteChallenge(ctx context.Context, tokenHash string) error
+}
+
+type TwoFactorServiceInterface interface {
+	Enroll(ctx context.Context, userID string) (*Enrollment, error)
+	Confirm(ctx context.Context, userID, code string) ([]string, error)
+	Disable(ctx context.Context, userID, password, code string) error
+	Complete(ctx context.Context, challenge, code, ip string) (*User, error)
+}
+
+type TwoFactorService struct {
+	Repo         TwoFactorRepository
+	Users        Repository
+	Session      session.Repository
+	Box          *secretbox.Box
+	Audit        audit.Logger
+	Throttle     *Throttle
+	Issuer       string
+	ChallengeTTL time.Duration
+	Now          func() time.Time
+}
+
+func NewTwoFactorService(repo TwoFactorRepository, users Repository, session session.Repository, box *secretbox.Box, auditLog audit.Logger) *TwoFactorService {
+	return &TwoFactorService{
+		Repo:         repo,
+		Users:        users,
+		Session:      session,
+		Box:          box,
+		Audit:        auditLog,
+		Issuer:       "redditclone",
+		ChallengeTTL: 5 * time.Minute,
+		Now:          time.Now,
+	}
+}
+
+// Enroll stores a new, not yet enabled secret. Calling it again before
+// Confirm replaces the pending secret.
+func (s *TwoFactorService) Enroll(ctx context.Context, userID string) (*Enrollment, error) {
+	if s.Box == nil {
+		return nil, ErrTwoFactorDisabled
+	}
+
+	user, err := s.Users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("totp secret gen error: %s", err)
+	}
+	sealed, err := s.Box.Seal(secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Repo.SetTOTPSecret(ctx, userID, sealed); err != nil {
+		return nil, err
+	}
+
+	return &Enrollment{Secret: secret, URI: totp.URI(s.Issuer, user.Username, secret)}, nil
+}
+
+// Confirm enables 2FA once the user proves the authenticator works and
+// returns the recovery codes, they are shown only this once.
+func (s *TwoFactorService) Confirm(ctx context.Context, userID, code string) ([]string, error) {
+	if s.Box == nil {
+		return nil, ErrTwoFactorDisabled
+	}
+
+	state, err := s.Repo.GetTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if state.Enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	if state.SealedSecret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	if err := s.checkTOTP(ctx, userID, state, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	if err := s.Repo.EnableTOTP(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	s.Audit.Record("2fa_enabled", "user", userID)
+	return codes, nil
+}
+
+func (s *TwoFactorService) Disable(ctx context.Context, userID, password, code string) error {
+	user, err := s.Users.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return ErrInvalidCredentials
+	}
+	if err := s.checkCode(ctx, userID, code); err != nil {
+		return err
+	}
+
+	if err := s.Repo.ClearTOTP(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.Repo.ReplaceRecoveryCodes(ctx, userID, nil); err != nil {
+		return err
+	}
+
+	s.Audit.Record("2fa_disabled", "user", userID)
+	return nil
+}
+
+// Challenge starts the second step of a login for a user whose password was
+// already checked.
+func (s *TwoFactorService) Challenge(ctx context.Context, userID string) (string, error) {
+	token, err := generator.GenerateRandomID(challengeTokenLen)
+	if err != nil {
+		return "", fmt.Errorf("challenge gen error: %s", err)
+	}
+
+	err = s.Repo.CreateChallenge(ctx, &Challenge{
+		TokenHash: hashToken(token),
+		UserID:    userID,
+		ExpiresAt: s.Now().Add(s.ChallengeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Complete finishes a login started by Login with a TOTP or recovery code
+// and opens the session.
+func (s *TwoFactorService) Complete(ctx context.Context, challenge, code, ip string) (_ *User, err error) {
+	defer func() { recordLogin("2fa", err) }()
+
+	hash := hashToken(challenge)
+
+	c, err := s.Repo.FindChallenge(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if !s.Now().Before(c.ExpiresAt) || c.Attempts >= maxChallengeTries {
+		if err := s.Repo.DeleteChallenge(ctx, hash); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidChallenge
+	}
+
+	user, err := s.Users.FindByID(ctx, c.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bad codes count against the same throttle as bad passwords, so asking
+	// for a fresh challenge does not buy another round of guesses.
+	if s.Throttle != nil {
+		if err := s.Throttle.Check(ctx, user.Username, ip); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.checkCode(ctx, c.UserID, code); err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			if err := s.Repo.FailChallenge(ctx, hash); err != nil {
+				return nil, err
+			}
+			if s.Throttle != nil {
+				if err := s.Throttle.Fail(ctx, user.Username, ip); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return nil, err
+	}
+
+	if err := s.Repo.DeleteChallenge(ctx, hash); err != nil {
+		return nil, err
+	}
+
+	if s.Throttle != nil {
+		if err := s.Throttle.Succeed(ctx, user.Username); err != nil {
+			return nil, err
+		}
+	}
+	if err := openSession(ctx, s.Session, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// checkCode accepts either a current TOTP code or an unused recovery code.
+func (s *TwoFactorService) checkCode(ctx context.Context, userID, code string) error {
+	code = strings.TrimSpace(code)
+
+	if len(code) == totp.Digits {
+		state, err := s.Repo.GetTOTP(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if !state.Enabled {
+			return ErrTwoFactorNotEnabled
+		}
+		return s.checkTOTP(ctx, userID, state, code)
+	}
+
+	used, err := s.Repo.UseRecoveryCode(ctx, userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidCode
+	}
+
+	s.Audit.Record("2fa_recovery_code_used", "user", userID)
+	return nil
+}
+
+func (s *TwoFactorService) checkTOTP(ctx context.Context, userID string, state *TOTPState, code string) error {
+	if s.Box == nil {
+		return ErrTwoFactorDisabled
+	}
+	secret, err := s.Box.Open(state.SealedSecret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := totp.Validate(secret, code, s.Now(), 1)
+	if !ok || step <= state.LastStep {
+		return ErrInvalidCode
+	}
+
+	// a code is accepted only once, even inside its validity window
+	if err := s.Repo.AdvanceStep(ctx, userID, step); err != nil {
+		if errors.Is(err, ErrStepUsed) {
+			return ErrInvalidCode
+		}
+		return err
+	}
+	return nil
+}
+
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := generator.GenerateRandomID(2 * recoveryCodeHalfLen)
+		if err != nil {
+			return nil, nil, fmt.Errorf("recovery code gen error: %s", err)
+		}
+		codes = append(codes, raw[:recoveryCodeHalfLen]+"-"+raw[recoveryCodeHalfLen:])
+		hashes = append(hashes, hashToken(raw))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(code, "-", ""), " ", "")
+}
+
+type MySQLTwoFactorRepo struct {
+	DB *sql.DB
+}
+
+func NewMySQLTwoFactorRepo(db *sql.DB) *MySQLTwoFactorRepo {
+	return &MySQLTwoFactorRepo{DB: db}
+}
+
+func (r *MySQLTwoFactorRepo) GetTOTP(ctx context.Context, userID string) (*TOTPState, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "two_factor", "GetTOTP")
+	defer done()
+	state := &TOTPState{}
+	var secret sql.NullString
+
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE id = ?",
+		userID,
+	).Scan(&secret, &state.Enabled, &state.LastStep)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state.SealedSecret = secret.String
+	return state, nil
+}
+
+func (r *MySQLTwoFactorRepo) SetTOTPSecret(ctx context.Context, userID, sealedSecret string) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "two_factor", "SetTOTPSecret")
+	defer done()
+	_, err := r.DB.ExecContext(ctx,
+		"UPDATE users SET totp_secret = ?, totp_enabled = FALSE, totp_last_step = 0 WHERE id = ?",
+		sealedSecret, userID,
+	)
+	return err
+}
+
+func (r *MySQLTwoFactorRepo) EnableTOTP(ctx context.Context, userID string) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "two_factor", "EnableTOTP")
+	defer done()
+	_, err := r.DB.ExecContext(ctx, "UPDATE users SET totp_enabled = TRUE WHERE id = ?", userID)
+	return err
+}
+
+func (r *MySQLTwoFactorRepo) ClearTOTP(ctx context.Context, userID string) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "two_factor", "ClearTOTP")
+	defer done()
+	_, err := r.DB.ExecContext(ctx,
+		"UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0 WHERE id = ?",
+		userID,
+	)
+	return err
+}
+
+func (r *MySQLTwoFactorRepo) AdvanceStep(ctx context.Context, userID string, step int64) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "two_factor", "AdvanceStep")
+	defer done()
+	res, err := r.DB.ExecContext(ctx,
+		"UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?",
+		step, userID, step,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrStepUsed
+	}
+	return err
+}
+
+func (r *MySQLTwoFactorRepo) ReplaceRecoveryCodes(ctx context.Context, userID string, hashes []string) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "two_factor", "ReplaceRecoveryCodes")
+	defer done()
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	for _, h := range hashes {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO recovery_codes (code_hash, user_id, created_at) VALUES (?, ?, ?)",
+			h, userID, time.Now().UTC(),
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UseRecoveryCode deletes the code and reports whether it existed.
+func (r *MySQLTwoFactorRepo) UseRecoveryCode(ctx context.Context, userID, hash string) (bool, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "two_factor", "UseRecoveryCode")
+	defer done()
+	res, err := r.DB.ExecContext(ctx,
+		"DELETE FROM recovery_codes WHERE user_id = ? AND code_hash = ?",
+		userID, hash,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *MySQLTwoFactorRepo) CreateChallenge(ctx context.Context, c *Challenge) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "two_factor", "CreateChallenge")
+	defer done()
+	_, err := r.DB.ExecContext(ctx,
+		"INSERT INTO login_challenges (token_hash, user_id, expires_at, attempts) VALUES (?, ?, ?, 0)",
+		c.TokenHash, c.UserID, c.ExpiresAt.UTC(),
+	)
+	return err
+}
+
+func (r *MySQLTwoFactorRepo) FindChallenge(ctx context.Context, tokenHash string) (*Challenge, error) {
+	ctx, done := dbcall.Start(ctx, "mysql", "two_factor", "FindChallenge")
+	defer done()
+	c := &Challenge{TokenHash: tokenHash}
+
+	err := r.DB.QueryRowContext(ctx,
+		"SELECT user_id, expires_at, attempts FROM login_challenges WHERE token_hash = ?",
+		tokenHash,
+	).Scan(&c.UserID, &c.ExpiresAt, &c.Attempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *MySQLTwoFactorRepo) FailChallenge(ctx context.Context, tokenHash string) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "two_factor", "FailChallenge")
+	defer done()
+	_, err := r.DB.ExecContext(ctx,
+		"UPDATE login_challenges SET attempts = attempts + 1 WHERE token_hash = ?",
+		tokenHash,
+	)
+	return err
+}
+
+func (r *MySQLTwoFactorRepo) DeleteChallenge(ctx context.Context, tokenHash string) error {
+	ctx, done := dbcall.Start(ctx, "mysql", "two_factor", "DeleteChallenge")
+	defer done()
+	res, err := r.DB.ExecContext(ctx, "DELETE FROM login_challenges WHERE token_hash = ?", tokenHash)
+	if err != nil {
+		return err
+	}
+	// of two parallel completions only the one deleting the row logs in
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvalidChallenge
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package user_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"redditclone/pkg/audit"
+	"redditclone/pkg/secretbox"
+	"redditclone/pkg/totp"
+	"redditclone/pkg/user"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testBoxKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+func TestTwoFactorFlow(t *testing.T) {
+	ctx := context.Background()
+	db := setupUserDB(t)
+	repo := user.NewMySQLRepo(db)
+
+	sessions := new(mockSession)
+	sessions.On("Create", mock.Anything, mock.Anything).Return("sessid", nil)
+
+	box, err := secretbox.New(testBoxKey)
+	assert.NoError(t, err)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	twoFactor := user.NewTwoFactorService(user.NewMySQLTwoFactorRepo(db), repo, sessions, box, audit.Nop{})
+	twoFactor.Now = func() time.Time { return now }
+
+	svc := user.NewService(repo, sessions)
+	svc.TwoFactor = twoFactor
+
+	u, err := svc.Register(ctx, "alice", "password", "")
+	assert.NoError(t, err)
+
+	enrollment, err := twoFactor.Enroll(ctx, u.ID)
+	assert.NoError(t, err)
+	assert.Contains(t, enrollment.URI, "otpauth://totp/redditclone:alice")
+
+	// the secret is not stored in clear
+	var stored string
+	assert.NoError(t, db.QueryRow("SELECT totp_secret FROM users WHERE id = ?", u.ID).Scan(&stored))
+	assert.NotContains(t, stored, enrollment.Secret)
+
+	code := func() string {
+		c, err := totp.Code(enrollment.Secret, totp.Step(now))
+		assert.NoError(t, err)
+		return c
+	}
+
+	_, err = twoFactor.Confirm(ctx, u.ID, "000000")
+	assert.EqualError(t, err, "invalid code")
+
+	recovery, err := twoFactor.Confirm(ctx, u.ID, code())
+	assert.NoError(t, err)
+	assert.Len(t, recovery, 10)
+
+	// password alone is not enough any more
+	_, err = svc.Login(ctx, "alice", "password", "127.0.0.1")
+	var required *user.SecondFactorRequiredError
+	assert.True(t, errors.As(err, &required))
+
+	// the code used for confirmation cannot be replayed
+	_, err = twoFactor.Complete(ctx, required.Challenge, code(), "127.0.0.1")
+	assert.EqualError(t, err, "invalid code")
+
+	now = now.Add(30 * time.Second)
+	logged, err := twoFactor.Complete(ctx, required.Challenge, code(), "127.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", logged.Username)
+
+	// challenges are single use
+	_, err = twoFactor.Complete(ctx, required.Challenge, code(), "127.0.0.1")
+	assert.EqualError(t, err, "invalid challenge")
+
+	// recovery codes work once
+	_, err = svc.Login(ctx, "alice", "password", "127.0.0.1")
+	assert.True(t, errors.As(err, &required))
+	_, err = twoFactor.Complete(ctx, required.Challenge, recovery[0], "127.0.0.1")
+	assert.NoError(t, err)
+
+	_, err = svc.Login(ctx, "alice", "password", "127.0.0.1")
+	assert.True(t, errors.As(err, &required))
+	_, err = twoFactor.Complete(ctx, required.Challenge, recovery[0], "127.0.0.1")
+	assert.EqualError(t, err, "invalid code")
+
+	assert.EqualError(t, twoFactor.Disable(ctx, u.ID, "wrong", recovery[1]), "invalid credentials")
+	assert.NoError(t, twoFactor.Disable(ctx, u.ID, "password", recovery[1]))
+
+	logged, err = svc.Login(ctx, "alice", "password", "127.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", logged.Username)
+}
+
+func TestTwoFactorChallengeExpires(t *testing.T) {
+	ctx := context.Background()
+	db := setupUserDB(t)
+	repo := user.NewMySQLRepo(db)
+	sessions := new(mockSession)
+
+	box, err := secretbox.New(testBoxKey)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	twoFactor := user.NewTwoFactorService(user.NewMySQLTwoFactorRepo(db), repo, sessions, box, audit.Nop{})
+	twoFactor.Now = func() time.Time { return now }
+
+	assert.NoError(t, repo.Create(ctx, &user.User{ID: "uid", Username: "bob", Password: "x"}))
+
+	challenge, err := twoFactor.Challenge(ctx, "uid")
+	assert.NoError(t, err)
+
+	now = now.Add(6 * time.Minute)
+	_, err = twoFactor.Complete(ctx, challenge, "123456", "127.0.0.1")
+	assert.EqualError(t, err, "invalid challenge")
+}
+
+func TestTwoFactorBadCodesLockTheAccount(t *testing.T) {
+	ctx := context.Background()
+	db := setupUserDB(t)
+	repo := user.NewMySQLRepo(db)
+
+	sessions := new(mockSession)
+	sessions.On("Create", mock.Anything, mock.Anything).Return("sessid", nil)
+
+	box, err := secretbox.New(testBoxKey)
+	assert.NoError(t, err)
+
+	throttle, auditLog, throttleNow := setupThrottle(t)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	twoFactor := user.NewTwoFactorService(user.NewMySQLTwoFactorRepo(db), repo, sessions, box, audit.Nop{})
+	twoFactor.Now = func() time.Time { return now }
+	twoFactor.Throttle = throttle
+
+	svc := user.NewService(repo, sessions)
+	svc.TwoFactor = twoFactor
+	svc.Throttle = throttle
+
+	u, err := svc.Register(ctx, "alice", "password", "")
+	assert.NoError(t, err)
+	enrollment, err := twoFactor.Enroll(ctx, u.ID)
+	assert.NoError(t, err)
+	code, err := totp.Code(enrollment.Secret, totp.Step(now))
+	assert.NoError(t, err)
+	_, err = twoFactor.Confirm(ctx, u.ID, code)
+	assert.NoError(t, err)
+
+	// one bad code per challenge, each challenge opened with the right password
+	for i := 0; i < 5; i++ {
+		*throttleNow = throttleNow.Add(5 * time.Second)
+
+		_, err := svc.Login(ctx, "alice", "password", "127.0.0.1")
+		var required *user.SecondFactorRequiredError
+		if !assert.True(t, errors.As(err, &required), "round %d: %v", i, err) {
+			return
+		}
+		_, err = twoFactor.Complete(ctx, required.Challenge, "000000", "127.0.0.1")
+		assert.EqualError(t, err, "invalid code")
+	}
+	assert.Equal(t, []string{"login_lockout"}, auditLog.events)
+
+	var throttled *user.ThrottledError
+	_, err = svc.Login(ctx, "alice", "password", "127.0.0.1")
+	if assert.True(t, errors.As(err, &throttled)) {
+		assert.Equal(t, throttleNow.Add(time.Minute), throttled.Until)
+	}
+}
+
+func TestTwoFactorWithoutKey(t *testing.T) {
+	ctx := context.Background()
+	db := setupUserDB(t)
+	repo := user.NewMySQLRepo(db)
+
+	twoFactor := user.NewTwoFactorService(user.NewMySQLTwoFactorRepo(db), repo, new(mockSession), nil, audit.Nop{})
+	assert.NoError(t, repo.Create(ctx, &user.User{ID: "uid", Username: "bob", Password: "x"}))
+
+	_, err := twoFactor.Enroll(ctx, "uid")
+	assert.ErrorIs(t, err, user.ErrTwoFactorDisabled)
+	_, err = twoFactor.Confirm(ctx, "uid", "123456")
+	assert.ErrorIs(t, err, user.ErrTwoFactorDisabled)
+}
+
+// brokenSteps fails every AdvanceStep with err.
+type brokenSteps struct {
+	user.TwoFactorRepository
+	err error
+}
+
+func (r brokenSteps) AdvanceStep(ctx context.Context, userID string, step int64) error {
+	return r.err
+}
+
+func TestTwoFactorStoreErrorsAreNotBadCodes(t *testing.T) {
+	ctx := context.Background()
+	db := setupUserDB(t)
+	repo := user.NewMySQLRepo(db)
+
+	box, err := secretbox.New(testBoxKey)
+	assert.NoError(t, err)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	twoFactorRepo := user.NewMySQLTwoFactorRepo(db)
+	twoFactor := user.NewTwoFactorService(twoFactorRepo, repo, new(mockSession), box, audit.Nop{})
+	twoFactor.Now = func() time.Time { return now }
+
+	assert.NoError(t, repo.Create(ctx, &user.User{ID: "uid", Username: "bob", Password: "x"}))
+	enrollment, err := twoFactor.Enroll(ctx, "uid")
+	assert.NoError(t, err)
+
+	twoFactor.Repo = brokenSteps{TwoFactorRepository: twoFactorRepo, err: errors.New("connection refused")}
+	code, err := totp.Code(enrollment.Secret, totp.Step(now))
+	assert.NoError(t, err)
+	_, err = twoFactor.Confirm(ctx, "uid", code)
+	assert.EqualError(t, err, "connection refused")
+}
+
+func TestTwoFactorRepo_DeleteChallengeClaimsOnce(t *testing.T) {
+	ctx := context.Background()
+	repo := user.NewMySQLTwoFactorRepo(setupUserDB(t))
+
+	assert.NoError(t, repo.CreateChallenge(ctx, &user.Challenge{
+		TokenHash: "hash",
+		UserID:    "uid",
+		ExpiresAt: time.Now().Add(time.Minute),
+	}))
+
+	assert.NoError(t, repo.DeleteChallenge(ctx, "hash"))
+	assert.ErrorIs(t, repo.DeleteChallenge(ctx, "hash"), user.ErrInvalidChallenge)
+}
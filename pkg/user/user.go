@@ -1,12 +1,43 @@
 package user
 
+import "context"
+
+// Roles are stored and shown to operators, but no handler checks them yet:
+// an admin can do exactly what a user can.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// ValidRole reports whether role is one of the Role constants.
+func ValidRole(role string) bool {
+	return role == RoleUser || role == RoleModerator || role == RoleAdmin
+}
+
 type User struct {
 	Username string `json:"username"`
 	ID       string `json:"id"`
 	Password string `json:"-" bson:"-"`
+	Email    string `json:"-" bson:"-"`
+	Verified bool   `json:"-" bson:"-"`
+	// TOTPEnabled means Login stops at the second factor.
+	TOTPEnabled bool `json:"-" bson:"-"`
+	// Role is RoleUser unless an operator changed it.
+	Role string `json:"-" bson:"-"`
+	// SessionID is the session opened by the login that returned the user,
+	// its tokens carry it.
+	SessionID string `json:"-" bson:"-"`
 }
 
 type Repository interface {
-	Create(user *User) error
-	FindByUsername(username string) (*User, error)
+	Create(ctx context.Context, user *User) error
+	FindByUsername(ctx context.Context, username string) (*User, error)
+	FindByID(ctx context.Context, id string) (*User, error)
+	FindByEmail(ctx context.Context, email string) (*User, error)
+	UpdatePassword(ctx context.Context, id, password string) error
+	SetEmail(ctx context.Context, id, email string) error
+	MarkVerified(ctx context.Context, id, email string) error
+	SetRole(ctx context.Context, id, role string) error
+	Delete(ctx context.Context, id string) error
 }